@@ -1,11 +1,17 @@
 package app
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"slices"
 	"time"
 
+	"archive/archiverepo"
+	"archive/mpegts"
 	"archive/playlist"
+	"archive/streamrepo"
 )
 
 // ArchiveRepository defines the interface for archive storage operations
@@ -32,104 +38,284 @@ type StreamRepository interface {
 type ArchiveApp struct {
 	streamRepo  StreamRepository
 	archiveRepo ArchiveRepository
+	now         func() time.Time
+	logger      *slog.Logger
+	stall       *stallDetector
+	// inspect and rejectCorrupt are set by WithSegmentInspection
+	inspect       bool
+	rejectCorrupt bool
+	continuity    continuity
+	// fillGaps and slate are set by WithGapFilling
+	fillGaps bool
+	slate    *Slate
 }
 
 // NewArchiveApp creates a new ArchiveApp
-func NewArchiveApp(streamRepo StreamRepository, archiveRepo ArchiveRepository) *ArchiveApp {
-	return &ArchiveApp{
+func NewArchiveApp(streamRepo StreamRepository, archiveRepo ArchiveRepository, options ...Option) *ArchiveApp {
+	app := &ArchiveApp{
 		streamRepo:  streamRepo,
 		archiveRepo: archiveRepo,
+		now:         time.Now,
+		logger:      slog.Default(),
+	}
+	for _, option := range options {
+		option(app)
+	}
+	return app
+}
+
+// Option configures an ArchiveApp
+type Option func(*ArchiveApp)
+
+// WithLogger makes the app log to logger instead of the default logger
+func WithLogger(logger *slog.Logger) Option {
+	return func(app *ArchiveApp) {
+		app.logger = logger
+	}
+}
+
+// WithClock makes the app tell the time with now instead of time.Now
+func WithClock(now func() time.Time) Option {
+	return func(app *ArchiveApp) {
+		app.now = now
 	}
 }
 
+// Kinds of failed operations counted in ArchiveResult.Failures
+const (
+	FailureReadRecorderPlaylist = "read_recorder_playlist"
+	FailureReadArchivePlaylist  = "read_archive_playlist"
+	FailureReadSegment          = "read_segment"
+	FailureWriteSegment         = "write_segment"
+	FailureWritePlaylist        = "write_playlist"
+	FailureCorruptSegment       = "corrupt_segment"
+	FailureSegmentReplaced      = "segment_replaced"
+	FailureFillGap              = "fill_gap"
+)
+
 // ArchiveResult represents the result of an archive operation
 type ArchiveResult struct {
+	// Error joins the errors of every failed operation, so errors.Is finds
+	// each of them
 	Error            error
 	ArchivedSegments int
+	// BytesWritten is the size of the segments archived
+	BytesWritten int64
+	// Failures counts failed operations by kind
+	Failures map[string]int
+	// LiveEnd is when the newest segment in the recorder playlist ends
+	LiveEnd time.Time
+	// ArchivedEnd is when the newest segment of the recorder playlist that
+	// is in the archive ends
+	ArchivedEnd time.Time
+	// Started is when the run started and Elapsed how long it took
+	Started time.Time
+	Elapsed time.Duration
+	// Segments reports on every segment of the recorder playlist, in
+	// playlist order
+	Segments []SegmentReport
+}
+
+// Lag returns how far the archive is behind the recorder playlist
+func (r ArchiveResult) Lag() time.Duration {
+	if r.LiveEnd.IsZero() || r.ArchivedEnd.IsZero() {
+		return 0
+	}
+	return r.LiveEnd.Sub(r.ArchivedEnd)
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.ReadCloser
+	count int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// segmentEnd returns when a segment's footage ends
+func segmentEnd(segment playlist.Segment) time.Time {
+	return segment.DateTime.Add(time.Duration(segment.Duration * float64(time.Second)))
 }
 
 // Archive performs the archive operation
-func (app *ArchiveApp) Archive() ArchiveResult {
+func (app *ArchiveApp) Archive() (result ArchiveResult) {
+	result = ArchiveResult{Failures: make(map[string]int), Started: app.now()}
+	var errs []error
+	defer func() {
+		result.Error = errors.Join(errs...)
+		result.Elapsed = app.now().Sub(result.Started)
+	}()
+
 	recorderPlaylist, err := app.streamRepo.GetPlaylist()
 	if err != nil {
-		return ArchiveResult{Error: fmt.Errorf("failed to get recorder playlist: %w", err)}
+		app.checkStall(time.Time{})
+		result.Failures[FailureReadRecorderPlaylist]++
+		errs = append(errs, fmt.Errorf("failed to get recorder playlist: %w", err))
+		return result
 	}
-
-	backedUp := 0
-	var archiveError error
-
+	var newest time.Time
 	for _, segment := range recorderPlaylist.Segments {
-		// Get archive playlist for this segment's time
-		archivePlaylist, err := app.archiveRepo.ReadPlaylist(segment.DateTime)
-		if err != nil {
-			fmt.Printf("Failed to read archive playlist for segment %s: %v\n", segment.Filename, err)
-			archiveError = fmt.Errorf("failed to read archive playlist: %w", err)
-			continue
+		if segment.DateTime.After(newest) {
+			newest = segment.DateTime
 		}
-
-		if archivePlaylist == nil {
-			fmt.Printf("Creating new archive playlist for time %s\n", segment.DateTime.Format("2006-01-02T15:04:05Z"))
-			archivePlaylist = &playlist.Playlist{
-				Version:        recorderPlaylist.Version,
-				TargetDuration: recorderPlaylist.TargetDuration,
-				MediaSequence:  recorderPlaylist.MediaSequence,
-				Segments:       []playlist.Segment{},
-			}
+		if end := segmentEnd(segment); end.After(result.LiveEnd) {
+			result.LiveEnd = end
 		}
+	}
+	app.checkStall(newest)
+	app.continuity.observePlaylist(recorderPlaylist)
 
-		// Check if segment already exists in archive playlist based on DateTime
-		segmentExists := false
-		for _, existingSegment := range archivePlaylist.Segments {
-			if existingSegment.DateTime.Equal(segment.DateTime) {
-				segmentExists = true
-				break
-			}
-		}
+	for _, segment := range recorderPlaylist.Segments {
+		started := app.now()
+		report, err := app.archiveSegment(recorderPlaylist, segment)
+		report.Elapsed = app.now().Sub(started)
+		result.Segments = append(result.Segments, report)
 
-		if segmentExists {
-			fmt.Printf("Segment with DateTime %s already exists in archive, skipping\n", segment.DateTime.Format("2006-01-02T15:04:05Z"))
+		switch report.Status {
+		case SegmentFailed:
+			result.Failures[report.Failure]++
+			errs = append(errs, fmt.Errorf("segment %s: %w", segment.Filename, err))
 			continue
+		case SegmentArchived:
+			result.ArchivedSegments++
+			result.BytesWritten += report.Bytes
 		}
+		if end := segmentEnd(segment); end.After(result.ArchivedEnd) {
+			result.ArchivedEnd = end
+		}
+	}
+	return result
+}
 
-		// Get segment content from recorder
-		content, err := app.streamRepo.GetSegment(segment.Filename)
-		if err != nil {
-			fmt.Printf("Failed to get segment %s: %v\n", segment.Filename, err)
-			continue
+// nextSegmentName returns the file name for the next segment of an archive
+// playlist, numbered after the highest one it lists. Reindexing drops
+// entries, so counting them could name a file that is still listed.
+func nextSegmentName(archivePlaylist *playlist.Playlist) string {
+	next := 0
+	for _, segment := range archivePlaylist.Segments {
+		var index int
+		if _, err := fmt.Sscanf(segment.Filename, "segment_%d.ts", &index); err == nil && index >= next {
+			next = index + 1
 		}
+	}
+	return fmt.Sprintf("segment_%03d.ts", next)
+}
 
-		// Write segment to archive
-		newFilename := fmt.Sprintf("segment_%03d.ts", len(archivePlaylist.Segments))
-		if err := app.archiveRepo.WriteSegment(segment.DateTime, newFilename, content); err != nil {
-			fmt.Printf("Failed to write segment %s: %v\n", newFilename, err)
-			archiveError = fmt.Errorf("failed to write segment: %w", err)
-			continue
+// archiveSegment copies a segment of the recorder playlist into the archive
+// unless it is already there. The error says why a failed segment failed.
+func (app *ArchiveApp) archiveSegment(recorderPlaylist *playlist.Playlist, segment playlist.Segment) (SegmentReport, error) {
+	report := newSegmentReport(segment.Filename, segment.DateTime, segment.Duration)
+	fail := func(kind string, err error) (SegmentReport, error) {
+		report.Status = SegmentFailed
+		report.Failure = kind
+		report.Reason = err.Error()
+		return report, err
+	}
+
+	// Get archive playlist for this segment's time
+	archivePlaylist, err := app.archiveRepo.ReadPlaylist(segment.DateTime)
+	if err != nil {
+		app.logger.Error("Failed to read archive playlist", "segment", segment.Filename, "segment_time", segment.DateTime, "error", err)
+		return fail(FailureReadArchivePlaylist, fmt.Errorf("failed to read archive playlist: %w", err))
+	}
+
+	if archivePlaylist == nil {
+		app.logger.Info("Starting a new archive playlist", "segment_time", segment.DateTime)
+		archivePlaylist = &playlist.Playlist{
+			Version:        recorderPlaylist.Version,
+			TargetDuration: recorderPlaylist.TargetDuration,
+			MediaSequence:  recorderPlaylist.MediaSequence,
+			Segments:       []playlist.Segment{},
 		}
+	}
 
-		// Create new segment with updated filename
-		newSegment := playlist.Segment{
-			Filename:        newFilename,
-			Duration:        segment.Duration,
-			DateTime:        segment.DateTime,
-			ProgramDateTime: segment.ProgramDateTime, // Preserve the ProgramDateTime tag
+	// Check if segment already exists in archive playlist based on DateTime
+	for _, existingSegment := range archivePlaylist.Segments {
+		if existingSegment.DateTime.Equal(segment.DateTime) && !archiverepo.IsPlaceholder(existingSegment) {
+			app.logger.Debug("Segment is already archived", "segment", segment.Filename, "segment_time", segment.DateTime)
+			report.Reason = "already archived"
+			report.ArchivedAs = existingSegment.Filename
+			return report, nil
 		}
+	}
 
-		// Add segment to archive playlist
-		archivePlaylist = playlist.Concat(archivePlaylist, newSegment)
+	// Get segment content from recorder
+	content, err := app.streamRepo.GetSegment(segment.Filename)
+	if errors.Is(err, streamrepo.ErrSegmentReplaced) {
+		// The footage is gone; the recorder playlist will list the new file
+		// under its own time
+		app.logger.Warn("Segment was replaced before it could be archived", "segment", segment.Filename,
+			"segment_time", segment.DateTime, "error", err)
+		return fail(FailureSegmentReplaced, fmt.Errorf("failed to read segment: %w", err))
+	}
+	if err != nil {
+		app.logger.Error("Failed to read segment", "segment", segment.Filename, "error", err)
+		return fail(FailureReadSegment, fmt.Errorf("failed to read segment: %w", err))
+	}
+	var info *mpegts.Info
+	var inspected func() (mpegts.Info, error)
+	switch {
+	case app.inspect && app.rejectCorrupt:
+		if content, info, err = app.inspectSegment(segment, content, &report); err != nil {
+			kind := FailureCorruptSegment
+			if report.Problems == nil {
+				kind = FailureReadSegment
+			}
+			return fail(kind, err)
+		}
+	case app.inspect:
+		content, inspected = inspectWhileArchiving(content)
+	}
 
-		// Write updated playlist
-		if err := app.archiveRepo.WritePlaylist(segment.DateTime, archivePlaylist); err != nil {
-			fmt.Printf("Failed to write archive playlist for segment %s: %v\n", newFilename, err)
-			archiveError = fmt.Errorf("failed to write archive playlist: %w", err)
-			continue
+	// Write segment to archive
+	newFilename := nextSegmentName(archivePlaylist)
+	report.ArchivedAs = newFilename
+	counted := &countingReader{ReadCloser: content}
+	err = app.archiveRepo.WriteSegment(segment.DateTime, newFilename, counted)
+	report.Bytes = counted.count
+	if inspected != nil {
+		// Wait for the inspection even if the write failed, so it ends
+		inspectedInfo, inspectErr := inspected()
+		if err == nil {
+			info, _ = app.noteProblems(segment, inspectedInfo, inspectErr, &report)
 		}
+	}
+	if err != nil {
+		app.logger.Error("Failed to write segment", "segment", segment.Filename, "archived_as", newFilename, "error", err)
+		return fail(FailureWriteSegment, fmt.Errorf("failed to write segment: %w", err))
+	}
 
-		backedUp++
+	// Create new segment with updated filename
+	newSegment := playlist.Segment{
+		Filename:        newFilename,
+		Duration:        segment.Duration,
+		DateTime:        segment.DateTime,
+		ProgramDateTime: segment.ProgramDateTime, // Preserve the ProgramDateTime tag
 	}
+	previousEnd := app.previousEnd(archivePlaylist, segment)
+	report.Discontinuity = app.markDiscontinuity(previousEnd, segment, info, &newSegment)
+	if app.fillGaps && slices.Contains(report.Discontinuity, RestartClockGap) {
+		archivePlaylist, report.GapFilled, err = app.fillGap(archivePlaylist, previousEnd, segment)
+		if err != nil {
+			app.logger.Error("Failed to fill gap", "segment", segment.Filename, "gap_start", previousEnd, "error", err)
+			return fail(FailureFillGap, fmt.Errorf("failed to fill gap: %w", err))
+		}
+	}
+
+	// Add segment to archive playlist
+	archivePlaylist = playlist.Concat(archivePlaylist, newSegment)
 
-	fmt.Printf("Archive complete. Archived %d segments.\n", backedUp)
-	return ArchiveResult{
-		ArchivedSegments: backedUp,
-		Error:            archiveError,
+	// Write updated playlist
+	if err := app.archiveRepo.WritePlaylist(segment.DateTime, archivePlaylist); err != nil {
+		app.logger.Error("Failed to write archive playlist", "segment", segment.Filename, "archived_as", newFilename, "error", err)
+		return fail(FailureWritePlaylist, fmt.Errorf("failed to write archive playlist: %w", err))
 	}
+
+	app.continuity.archived(segment, info)
+	report.Status = SegmentArchived
+	return report, nil
 }
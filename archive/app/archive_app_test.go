@@ -2,11 +2,18 @@ package app_test
 
 import (
 	"archive/app"
+	"archive/archiverepo"
+	"archive/objectstore"
 	"archive/playlist"
+	"archive/streamrepo"
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"strings"
 	"testing"
 	"time"
 )
@@ -203,12 +210,67 @@ func TestArchiveApp_Archive_ArchiveRepoError(t *testing.T) {
 	}
 }
 
+func TestArchiveApp_Archive_ReportsBytesAndLag(t *testing.T) {
+	// Setup
+	start := time.Date(2025, 4, 11, 18, 0, 0, 0, time.UTC)
+	streamRepo := &mockStreamRepo{
+		playlist: &playlist.Playlist{
+			Version:        3,
+			TargetDuration: 10,
+			Segments: []playlist.Segment{
+				{Filename: "segment_00.ts", Duration: 10, DateTime: start},
+				{Filename: "segment_01.ts", Duration: 10, DateTime: start.Add(10 * time.Second)},
+			},
+		},
+		segment: []byte("test segment"),
+	}
+	archiveRepo := &mockArchiveRepo{}
+
+	// Execute
+	result := app.NewArchiveApp(streamRepo, archiveRepo).Archive()
+
+	// Assert
+	if result.BytesWritten != int64(2*len("test segment")) {
+		t.Errorf("BytesWritten = %d, want %d", result.BytesWritten, 2*len("test segment"))
+	}
+	if want := start.Add(20 * time.Second); !result.LiveEnd.Equal(want) || !result.ArchivedEnd.Equal(want) {
+		t.Errorf("LiveEnd, ArchivedEnd = %v, %v, want both %v", result.LiveEnd, result.ArchivedEnd, want)
+	}
+	if result.Lag() != 0 {
+		t.Errorf("Lag = %v, want 0", result.Lag())
+	}
+}
+
+func TestArchiveApp_Archive_CountsFailures(t *testing.T) {
+	// Setup
+	archiveRepo := &mockArchiveRepo{err: errors.New("repository error")}
+	streamRepo := &mockStreamRepo{
+		playlist: &playlist.Playlist{
+			Segments: []playlist.Segment{{Filename: "segment_00.ts", Duration: 10, DateTime: time.Now()}},
+		},
+	}
+
+	// Execute
+	result := app.NewArchiveApp(streamRepo, archiveRepo).Archive()
+
+	// Assert
+	if result.Failures[app.FailureReadArchivePlaylist] != 1 {
+		t.Errorf("Failures = %v, want one %s", result.Failures, app.FailureReadArchivePlaylist)
+	}
+	if !result.ArchivedEnd.IsZero() {
+		t.Errorf("ArchivedEnd = %v, want zero", result.ArchivedEnd)
+	}
+}
+
 // Mock implementations
 
 type mockStreamRepo struct {
 	playlist *playlist.Playlist
 	segment  []byte
-	err      error
+	// segments are the contents of particular segments, instead of segment
+	segments   map[string][]byte
+	err        error
+	segmentErr error
 }
 
 func (m *mockStreamRepo) GetPlaylist() (*playlist.Playlist, error) {
@@ -222,6 +284,12 @@ func (m *mockStreamRepo) GetSegment(filename string) (io.ReadCloser, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
+	if m.segmentErr != nil {
+		return nil, m.segmentErr
+	}
+	if content, found := m.segments[filename]; found {
+		return io.NopCloser(bytes.NewReader(content)), nil
+	}
 	return io.NopCloser(bytes.NewReader(m.segment)), nil
 }
 
@@ -249,5 +317,143 @@ func (m *mockArchiveRepo) WriteSegment(time time.Time, filename string, content
 	if m.err != nil {
 		return m.err
 	}
-	return nil
+	defer content.Close()
+	_, err := io.Copy(io.Discard, content)
+	return err
+}
+
+func TestArchiveApp_Archive_ReportsEachSegment(t *testing.T) {
+	// Setup
+	start := time.Date(2025, 4, 11, 18, 59, 50, 0, time.UTC)
+	streamRepo := &mockStreamRepo{
+		playlist: &playlist.Playlist{
+			Segments: []playlist.Segment{
+				{Filename: "segment_00.ts", Duration: 10, DateTime: start},
+				{Filename: "segment_01.ts", Duration: 10, DateTime: start.Add(10 * time.Second)},
+			},
+		},
+		segment: []byte("test segment"),
+	}
+	archiveRepo := &mockArchiveRepo{}
+	archiveApp := app.NewArchiveApp(streamRepo, archiveRepo)
+
+	// Execute
+	first := archiveApp.Archive()
+	streamRepo.segmentErr = errors.New("segment vanished")
+	streamRepo.playlist.Segments = append(streamRepo.playlist.Segments,
+		playlist.Segment{Filename: "segment_02.ts", Duration: 10, DateTime: start.Add(20 * time.Second)})
+	second := archiveApp.Archive()
+
+	// Assert
+	if got, want := first.Hours(), []string{"2025/04/11/18", "2025/04/11/19"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Hours = %v, want %v", got, want)
+	}
+	if first.Segments[1].Status != app.SegmentArchived || first.Segments[1].Bytes != int64(len("test segment")) {
+		t.Errorf("first run segment 1 = %+v, want archived with its bytes", first.Segments[1])
+	}
+
+	statuses := make([]string, len(second.Segments))
+	for i, segment := range second.Segments {
+		statuses[i] = segment.Status
+	}
+	if got, want := fmt.Sprint(statuses), "[skipped skipped failed]"; got != want {
+		t.Errorf("second run statuses = %v, want %v", got, want)
+	}
+	if failed := second.Segments[2]; failed.Failure != app.FailureReadSegment || failed.Reason == "" {
+		t.Errorf("failed segment = %+v, want a read_segment failure with a reason", failed)
+	}
+	if !errors.Is(second.Error, streamRepo.segmentErr) {
+		t.Errorf("Error = %v, want it to wrap %v", second.Error, streamRepo.segmentErr)
+	}
+	if len(second.Hours()) != 0 {
+		t.Errorf("Hours = %v, want none", second.Hours())
+	}
+
+	report, err := json.Marshal(second)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	for _, want := range []string{`"failed_segments":1`, `"skipped_segments":2`, `"error":"segment segment_02.ts: failed to read segment: segment vanished"`} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("Report %s does not contain %s", report, want)
+		}
+	}
+}
+
+func TestArchiveApp_Archive_ReplacedSegment(t *testing.T) {
+	// Setup
+	streamRepo := &mockStreamRepo{
+		playlist: &playlist.Playlist{
+			Segments: []playlist.Segment{{Filename: "segment_00.ts", Duration: 10, DateTime: time.Now()}},
+		},
+		segmentErr: fmt.Errorf("%w: segment_00.ts was written after the playlist", streamrepo.ErrSegmentReplaced),
+	}
+
+	// Execute
+	result := app.NewArchiveApp(streamRepo, &mockArchiveRepo{}).Archive()
+
+	// Assert
+	if result.Failures[app.FailureSegmentReplaced] != 1 {
+		t.Errorf("Failures = %v, want one %s", result.Failures, app.FailureSegmentReplaced)
+	}
+	if !errors.Is(result.Error, streamrepo.ErrSegmentReplaced) {
+		t.Errorf("Error = %v, want it to wrap %v", result.Error, streamrepo.ErrSegmentReplaced)
+	}
+}
+
+func TestArchiveApp_Archive_AfterReindex(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	store := objectstore.NewMemory()
+	archiveRepo := archiverepo.New(store, playlist.NewParser(slog.Default()), slog.Default())
+	start := time.Date(2025, 4, 11, 18, 0, 0, 0, time.UTC)
+	segment := func(i int) playlist.Segment {
+		t := start.Add(time.Duration(i) * 10 * time.Second)
+		return playlist.Segment{Filename: fmt.Sprintf("rec_%02d.ts", i), Duration: 10, DateTime: t, ProgramDateTime: t.Format(time.RFC3339)}
+	}
+	streamRepo := &mockStreamRepo{
+		playlist: &playlist.Playlist{Version: 3, TargetDuration: 10, Segments: []playlist.Segment{segment(0), segment(1), segment(2)}},
+		segments: map[string][]byte{"rec_00.ts": []byte("0"), "rec_01.ts": []byte("1"), "rec_02.ts": []byte("2"), "rec_03.ts": []byte("3")},
+	}
+	if result := app.NewArchiveApp(streamRepo, archiveRepo).Archive(); result.Error != nil {
+		t.Fatalf("Archive failed: %v", result.Error)
+	}
+	// The middle segment is lost, so reindexing leaves two entries
+	if err := store.Delete(ctx, "2025/04/11/18/segment_001.ts"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archiveRepo.Reindex(ctx, "2025/04/11/18", false); err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+
+	// Execute
+	streamRepo.playlist.Segments = []playlist.Segment{segment(2), segment(3)}
+	result := app.NewArchiveApp(streamRepo, archiveRepo).Archive()
+
+	// Assert
+	if result.Error != nil || result.ArchivedSegments != 1 {
+		t.Fatalf("Archive = %d segments, %v, want 1 without errors", result.ArchivedSegments, result.Error)
+	}
+	archived, err := archiveRepo.ReadPlaylist(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range archived.Segments {
+		names = append(names, entry.Filename)
+	}
+	if got, want := strings.Join(names, " "), "segment_000.ts segment_002.ts segment_003.ts"; got != want {
+		t.Errorf("segments = %s, want %s", got, want)
+	}
+	for name, want := range map[string]string{"segment_000.ts": "0", "segment_002.ts": "2", "segment_003.ts": "3"} {
+		file, err := store.Get(ctx, "2025/04/11/18/"+name)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", name, err)
+		}
+		content, _ := io.ReadAll(file)
+		file.Close()
+		if string(content) != want {
+			t.Errorf("%s = %q, want %q", name, content, want)
+		}
+	}
 }
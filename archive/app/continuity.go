@@ -0,0 +1,153 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"archive/mpegts"
+	"archive/playlist"
+)
+
+// GapClass is the CLASS of the EXT-X-DATERANGE tags that record where the
+// recording was interrupted
+const GapClass = "recording-gap"
+
+// What gave away that a segment does not continue the one before it
+const (
+	// RestartMarked segments were marked as discontinuous by the recorder
+	RestartMarked = "recorder_marked"
+	// RestartSequenceReset segments start a recorder playlist whose media
+	// sequence went back, as ffmpeg's does when it starts again
+	RestartSequenceReset = "media_sequence_reset"
+	// RestartPTSJump segments have timestamps that don't follow on from the
+	// previous segment's
+	RestartPTSJump = "pts_jump"
+	// RestartClockGap segments start later than the previous segment ended
+	RestartClockGap = "clock_gap"
+)
+
+const (
+	// clockGapTolerance is how far apart the end of a segment and the start
+	// of the next may be before the footage between them counts as missing
+	clockGapTolerance = time.Second
+	// ptsJumpTolerance is how far, in 90kHz ticks, a segment's first
+	// timestamp may be from where the previous segment's video ended
+	ptsJumpTolerance = mpegts.ClockRate
+)
+
+// continuity remembers enough of the previous runs to tell when the
+// recorder restarted between them. It is only kept in memory, so an app
+// that archives once, like the once command, can only tell restarts the
+// recorder marked and gaps in the segments' clock times; a reset media
+// sequence or a jump in timestamps needs an app that keeps running.
+type continuity struct {
+	// mediaSequence is the recorder playlist's media sequence on the last
+	// run that read it, if sequenced is set
+	mediaSequence int
+	sequenced     bool
+	// restarted is set when the media sequence went back, until the next
+	// archived segment has been marked
+	restarted bool
+	// ptsEnd is where the video of the last archived segment ended, if it
+	// was inspected, and ptsSegmentEnd when that segment ended
+	ptsEnd        int64
+	ptsSegmentEnd time.Time
+}
+
+// observePlaylist notes the media sequence of the recorder playlist
+func (c *continuity) observePlaylist(recorderPlaylist *playlist.Playlist) {
+	if c.sequenced && recorderPlaylist.MediaSequence < c.mediaSequence {
+		c.restarted = true
+	}
+	c.mediaSequence, c.sequenced = recorderPlaylist.MediaSequence, true
+}
+
+// archived notes that a segment made it into the archive, so the reset
+// media sequence has been dealt with and the next segment's timestamps
+// should follow on from this one's
+func (c *continuity) archived(segment playlist.Segment, info *mpegts.Info) {
+	c.restarted = false
+	if info == nil {
+		return
+	}
+	if video, hasVideo := info.VideoStream(); hasVideo {
+		c.ptsEnd, c.ptsSegmentEnd = video.EndPTS(), segmentEnd(segment)
+	}
+}
+
+// previousEnd returns when the archived segment before segment ends: the
+// latest one in its hour that starts before it or, for the first segment
+// of an hour, the last one of the hour before. It is zero if there is no
+// footage before segment.
+func (app *ArchiveApp) previousEnd(archivePlaylist *playlist.Playlist, segment playlist.Segment) time.Time {
+	var previous *playlist.Segment
+	for i, existing := range archivePlaylist.Segments {
+		if existing.DateTime.Before(segment.DateTime) && (previous == nil || existing.DateTime.After(previous.DateTime)) {
+			previous = &archivePlaylist.Segments[i]
+		}
+	}
+	if previous == nil && len(archivePlaylist.Segments) == 0 {
+		hourBefore, err := app.archiveRepo.ReadPlaylist(segment.DateTime.Add(-time.Hour))
+		if err != nil {
+			app.logger.Warn("Failed to read the previous hour's archive playlist", "segment_time", segment.DateTime, "error", err)
+			return time.Time{}
+		}
+		if hourBefore != nil && len(hourBefore.Segments) > 0 {
+			previous = &hourBefore.Segments[len(hourBefore.Segments)-1]
+		}
+	}
+	if previous == nil {
+		return time.Time{}
+	}
+	return segmentEnd(*previous)
+}
+
+// markDiscontinuity works out whether a segment about to be archived
+// continues the footage before it, which ended at previousEnd. If it
+// doesn't, it marks the archive segment as a discontinuity, records the gap
+// before it as a date range, and returns what gave the restart away. info
+// is nil if the segment wasn't inspected. It doesn't change what the app
+// remembers, since the segment may still fail to be archived.
+func (app *ArchiveApp) markDiscontinuity(previousEnd time.Time, segment playlist.Segment, info *mpegts.Info, archived *playlist.Segment) []string {
+	var reasons []string
+	if segment.Discontinuity {
+		reasons = append(reasons, RestartMarked)
+	}
+	if app.continuity.restarted {
+		reasons = append(reasons, RestartSequenceReset)
+	}
+	if !previousEnd.IsZero() && segment.DateTime.Sub(previousEnd) > clockGapTolerance {
+		reasons = append(reasons, RestartClockGap)
+	}
+	video, hasVideo := mpegts.StreamInfo{}, false
+	if info != nil {
+		video, hasVideo = info.VideoStream()
+	}
+	if hasVideo && !previousEnd.IsZero() && previousEnd.Equal(app.continuity.ptsSegmentEnd) {
+		expected := app.continuity.ptsEnd
+		if jump := mpegts.Unwrap(video.FirstPTS, expected) - expected; jump > ptsJumpTolerance || -jump > ptsJumpTolerance {
+			reasons = append(reasons, RestartPTSJump)
+		}
+	}
+
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	start := previousEnd
+	if start.IsZero() || start.After(segment.DateTime) {
+		start = segment.DateTime
+	}
+	archived.Discontinuity = true
+	archived.DateRanges = append(archived.DateRanges, playlist.DateRange{
+		ID:         fmt.Sprintf("gap-%d", segment.DateTime.UnixMilli()),
+		Class:      GapClass,
+		Start:      start,
+		End:        segment.DateTime,
+		Attributes: map[string]string{"X-REASON": strings.Join(reasons, ",")},
+	})
+	app.logger.Warn("Marking a discontinuity", "segment", segment.Filename, "segment_time", segment.DateTime,
+		"reasons", reasons, "gap_seconds", segment.DateTime.Sub(start).Seconds())
+	return reasons
+}
@@ -0,0 +1,82 @@
+package app_test
+
+import (
+	"archive/app"
+	"archive/mpegts/mpegtstest"
+	"archive/playlist"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchiveApp_Archive_MarksDiscontinuities(t *testing.T) {
+	// Setup
+	start := time.Date(2025, 4, 11, 18, 0, 0, 0, time.UTC)
+	video := func(startPTS int64) []byte {
+		return mpegtstest.Stream{Frames: 250, FrameRate: 25, GOP: 50, StartPTS: startPTS}.Bytes()
+	}
+	streamRepo := &mockStreamRepo{
+		playlist: &playlist.Playlist{
+			MediaSequence: 7,
+			Segments: []playlist.Segment{
+				{Filename: "segment_007.ts", Duration: 10, DateTime: start},
+				{Filename: "segment_008.ts", Duration: 10, DateTime: start.Add(10 * time.Second)},
+				{Filename: "segment_009.ts", Duration: 10, DateTime: start.Add(20 * time.Second)},
+			},
+		},
+		segments: map[string][]byte{
+			"segment_007.ts": video(90000),
+			"segment_008.ts": video(90000 + 10*90000),
+			// The encoder's timestamps jump while the clock carries on
+			"segment_009.ts": video(5000000),
+		},
+	}
+	archiveRepo := &mockArchiveRepo{}
+	archiveApp := app.NewArchiveApp(streamRepo, archiveRepo, app.WithSegmentInspection(false))
+
+	// Execute
+	first := archiveApp.Archive()
+	// The recorder restarts half a minute later, back at the first segment
+	streamRepo.playlist = &playlist.Playlist{
+		Segments: []playlist.Segment{
+			{Filename: "segment_000.ts", Duration: 10, DateTime: start.Add(60 * time.Second)},
+			{Filename: "segment_001.ts", Duration: 10, DateTime: start.Add(70 * time.Second)},
+		},
+	}
+	streamRepo.segments = map[string][]byte{
+		"segment_000.ts": video(90000),
+		"segment_001.ts": video(90000 + 10*90000),
+	}
+	second := archiveApp.Archive()
+
+	// Assert
+	if first.Error != nil || second.Error != nil {
+		t.Fatalf("Errors = %v, %v, want none", first.Error, second.Error)
+	}
+	var reasons []string
+	for _, result := range []app.ArchiveResult{first, second} {
+		for _, segment := range result.Segments {
+			reasons = append(reasons, strings.Join(segment.Discontinuity, "+"))
+		}
+	}
+	want := fmt.Sprint([]string{"", "", app.RestartPTSJump, app.RestartSequenceReset + "+" + app.RestartClockGap + "+" + app.RestartPTSJump, ""})
+	if got := fmt.Sprint(reasons); got != want {
+		t.Errorf("discontinuities = %v, want %v", got, want)
+	}
+	if second.Discontinuities() != 1 {
+		t.Errorf("Discontinuities = %d, want 1", second.Discontinuities())
+	}
+
+	archived := archiveRepo.playlist.Segments
+	if len(archived) != 5 || !archived[3].Discontinuity || archived[4].Discontinuity {
+		t.Fatalf("archive playlist = %+v, want the fourth of five segments to be a discontinuity", archived)
+	}
+	gap := archived[3].DateRanges[0]
+	if gap.Class != app.GapClass || !gap.Start.Equal(start.Add(30*time.Second)) || !gap.End.Equal(start.Add(60*time.Second)) {
+		t.Errorf("gap = %+v, want %s from 30s to 60s", gap, app.GapClass)
+	}
+	if content := archiveRepo.playlist.String(); !strings.Contains(content, "#EXT-X-DISCONTINUITY\n#EXTINF:10.000000,\nsegment_003.ts") {
+		t.Errorf("archive playlist does not mark the discontinuity:\n%s", content)
+	}
+}
@@ -0,0 +1,126 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+
+	"archive/archiverepo"
+	"archive/mpegts"
+	"archive/playlist"
+)
+
+// Slate is a short clip shown in place of missing footage
+type Slate struct {
+	content  []byte
+	duration float64
+}
+
+// NewSlate returns a slate of a transport stream, such as a few seconds of
+// a "no signal" card encoded like the recording
+func NewSlate(content []byte) (*Slate, error) {
+	info, err := mpegts.Inspect(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("slate is not a transport stream: %w", err)
+	}
+	if _, hasVideo := info.VideoStream(); !hasVideo {
+		return nil, errors.New("slate has no video")
+	}
+	if info.Duration < time.Second {
+		return nil, fmt.Errorf("slate lasts %s, less than a second", info.Duration)
+	}
+	return &Slate{content: content, duration: info.Duration.Seconds()}, nil
+}
+
+// WithGapFilling makes the app cover the time between a segment and the
+// footage before it with placeholders, so that the archive playlists keep
+// to the wall clock and players show the outage for what it is. Gaps are
+// covered with EXT-X-GAP entries, or with repeats of slate if it is set and
+// EXT-X-GAP entries for the rest. Only the hour of the segment and the hour
+// before it are filled: hours without any footage stay out of the archive.
+func WithGapFilling(slate *Slate) Option {
+	return func(app *ArchiveApp) {
+		app.fillGaps = true
+		app.slate = slate
+	}
+}
+
+// fillGap covers the gap between previousEnd and a segment that is about
+// to be appended to archivePlaylist, the playlist of its hour, and returns
+// the playlist with the placeholders added and how many seconds they
+// cover. The part of the gap in the
+// hour before is added to that hour's playlist straight away. Gaps are only
+// filled at the end of the footage, so segments archived out of order are
+// left alone.
+func (app *ArchiveApp) fillGap(archivePlaylist *playlist.Playlist, previousEnd time.Time, segment playlist.Segment) (*playlist.Playlist, float64, error) {
+	if n := len(archivePlaylist.Segments); n > 0 && !segmentEnd(archivePlaylist.Segments[n-1]).Equal(previousEnd) {
+		return archivePlaylist, 0, nil
+	}
+
+	from := previousEnd
+	hourStart := segment.DateTime.UTC().Truncate(time.Hour)
+	if previousEnd.Before(hourStart) {
+		// The gap starts after the last segment of the hour before, which
+		// ended at previousEnd
+		hourBefore, err := app.archiveRepo.ReadPlaylist(previousEnd)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read the archive playlist of the hour before: %w", err)
+		}
+		if hourBefore == nil {
+			return archivePlaylist, 0, nil
+		}
+		if hourBefore, err = app.placeholders(hourBefore, previousEnd, hourStart); err != nil {
+			return nil, 0, err
+		}
+		if err := app.archiveRepo.WritePlaylist(previousEnd, hourBefore); err != nil {
+			return nil, 0, fmt.Errorf("failed to write the archive playlist of the hour before: %w", err)
+		}
+		from = hourStart
+	}
+	filled, err := app.placeholders(archivePlaylist, from, segment.DateTime)
+	if err != nil {
+		return nil, 0, err
+	}
+	return filled, segment.DateTime.Sub(previousEnd).Seconds(), nil
+}
+
+// placeholders returns an hour's playlist with placeholders covering from
+// to to appended, writing the slate into the hour's directory if the
+// playlist doesn't use it yet
+func (app *ArchiveApp) placeholders(hourPlaylist *playlist.Playlist, from, to time.Time) (*playlist.Playlist, error) {
+	filled := *hourPlaylist
+	filled.Segments = slices.Clone(hourPlaylist.Segments)
+	gapLength := float64(filled.TargetDuration)
+	if gapLength <= 0 {
+		gapLength = 10
+	}
+
+	if app.slate != nil && to.Sub(from).Seconds() >= app.slate.duration &&
+		!slices.ContainsFunc(filled.Segments, func(s playlist.Segment) bool { return s.Filename == archiverepo.SlateName }) {
+		if err := app.archiveRepo.WriteSegment(from, archiverepo.SlateName, io.NopCloser(bytes.NewReader(app.slate.content))); err != nil {
+			return nil, fmt.Errorf("failed to write slate: %w", err)
+		}
+	}
+
+	for t := from; to.Sub(t) >= time.Millisecond; {
+		placeholder := playlist.Segment{DateTime: t, ProgramDateTime: t.UTC().Format(playlist.DateTimeLayout)}
+		remaining := to.Sub(t).Seconds()
+		if app.slate != nil && remaining >= app.slate.duration {
+			// Every repeat of the slate starts its timestamps again
+			placeholder.Filename, placeholder.Duration, placeholder.Discontinuity = archiverepo.SlateName, app.slate.duration, true
+			filled.TargetDuration = max(filled.TargetDuration, int(math.Ceil(app.slate.duration)))
+		} else {
+			placeholder.Filename, placeholder.Duration, placeholder.Gap = archiverepo.GapName, min(remaining, gapLength), true
+			filled.Version = max(filled.Version, playlist.GapVersion)
+		}
+		filled.Segments = append(filled.Segments, placeholder)
+		t = t.Add(time.Duration(placeholder.Duration * float64(time.Second)))
+	}
+	app.logger.Info("Filled gap in the archive", "hour", archiverepo.HourPath(from), "from", from, "to", to,
+		"slate", app.slate != nil)
+	return &filled, nil
+}
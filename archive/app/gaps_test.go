@@ -0,0 +1,132 @@
+package app_test
+
+import (
+	"archive/app"
+	"archive/archiverepo"
+	"archive/mpegts/mpegtstest"
+	"archive/playlist"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchiveApp_Archive_FillsGaps(t *testing.T) {
+	// Setup
+	start := time.Date(2025, 4, 11, 17, 59, 30, 0, time.UTC)
+	streamRepo := &mockStreamRepo{
+		playlist: &playlist.Playlist{
+			Version:        3,
+			TargetDuration: 10,
+			Segments:       []playlist.Segment{{Filename: "segment_000.ts", Duration: 10, DateTime: start}},
+		},
+		segment: []byte("test segment"),
+	}
+	archiveRepo := newHourlyArchiveRepo()
+	archiveApp := app.NewArchiveApp(streamRepo, archiveRepo, app.WithGapFilling(nil))
+
+	// Execute
+	archiveApp.Archive()
+	// The camera drops out for 45 seconds, across the hour
+	streamRepo.playlist.Segments = append(streamRepo.playlist.Segments,
+		playlist.Segment{Filename: "segment_001.ts", Duration: 10, DateTime: start.Add(55 * time.Second)})
+	result := archiveApp.Archive()
+
+	// Assert
+	if result.Error != nil {
+		t.Fatalf("Error = %v, want none", result.Error)
+	}
+	if filled := result.Segments[1].GapFilled; filled != 45 {
+		t.Errorf("GapFilled = %v, want 45", filled)
+	}
+	if got, want := archiveRepo.describe("2025/04/11/17"), "segment_000.ts@17:59:30+10 gap@17:59:40+10 gap@17:59:50+10"; got != want {
+		t.Errorf("hour 17 = %s, want %s", got, want)
+	}
+	if got, want := archiveRepo.describe("2025/04/11/18"), "gap@18:00:00+10 gap@18:00:10+10 gap@18:00:20+5 segment_000.ts@18:00:25+10"; got != want {
+		t.Errorf("hour 18 = %s, want %s", got, want)
+	}
+	content := archiveRepo.playlists["2025/04/11/18"].String()
+	for _, want := range []string{"#EXT-X-VERSION:8\n", "#EXT-X-GAP\n#EXTINF:5.000000,\n#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:20.000Z\ngap.ts\n"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("hour 18 playlist does not contain %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestArchiveApp_Archive_FillsGapsWithSlate(t *testing.T) {
+	// Setup
+	slate, err := app.NewSlate(mpegtstest.Stream{Frames: 50, FrameRate: 25, GOP: 25}.Bytes())
+	if err != nil {
+		t.Fatalf("NewSlate failed: %v", err)
+	}
+	start := time.Date(2025, 4, 11, 18, 0, 0, 0, time.UTC)
+	streamRepo := &mockStreamRepo{
+		playlist: &playlist.Playlist{
+			TargetDuration: 10,
+			Segments: []playlist.Segment{
+				{Filename: "segment_000.ts", Duration: 10, DateTime: start},
+				{Filename: "segment_001.ts", Duration: 10, DateTime: start.Add(15 * time.Second)},
+				{Filename: "segment_002.ts", Duration: 10, DateTime: start.Add(30 * time.Second)},
+			},
+		},
+		segment: []byte("test segment"),
+	}
+	archiveRepo := newHourlyArchiveRepo()
+
+	// Execute
+	result := app.NewArchiveApp(streamRepo, archiveRepo, app.WithGapFilling(slate)).Archive()
+
+	// Assert
+	if result.Error != nil {
+		t.Fatalf("Error = %v, want none", result.Error)
+	}
+	want := "segment_000.ts@18:00:00+10 slate.ts@18:00:10+2 slate.ts@18:00:12+2 gap@18:00:14+1 segment_001.ts@18:00:15+10 " +
+		"slate.ts@18:00:25+2 slate.ts@18:00:27+2 gap@18:00:29+1 segment_002.ts@18:00:30+10"
+	if got := archiveRepo.describe("2025/04/11/18"); got != want {
+		t.Errorf("hour 18 = %s, want %s", got, want)
+	}
+	if archiveRepo.writes["2025/04/11/18/slate.ts"] != 1 {
+		t.Errorf("slate written %d times, want once", archiveRepo.writes["2025/04/11/18/slate.ts"])
+	}
+}
+
+// hourlyArchiveRepo keeps a playlist for every hour
+type hourlyArchiveRepo struct {
+	playlists map[string]*playlist.Playlist
+	writes    map[string]int
+}
+
+func newHourlyArchiveRepo() *hourlyArchiveRepo {
+	return &hourlyArchiveRepo{playlists: make(map[string]*playlist.Playlist), writes: make(map[string]int)}
+}
+
+func (m *hourlyArchiveRepo) ReadPlaylist(time time.Time) (*playlist.Playlist, error) {
+	return m.playlists[archiverepo.HourPath(time)], nil
+}
+
+func (m *hourlyArchiveRepo) WritePlaylist(time time.Time, playlist *playlist.Playlist) error {
+	m.playlists[archiverepo.HourPath(time)] = playlist
+	return nil
+}
+
+func (m *hourlyArchiveRepo) WriteSegment(time time.Time, filename string, content io.ReadCloser) error {
+	defer content.Close()
+	m.writes[archiverepo.HourPath(time)+"/"+filename]++
+	_, err := io.Copy(io.Discard, content)
+	return err
+}
+
+// describe lists an hour's segments as name@time+duration, with
+// placeholders named gap
+func (m *hourlyArchiveRepo) describe(hour string) string {
+	var entries []string
+	for _, segment := range m.playlists[hour].Segments {
+		name := segment.Filename
+		if segment.Gap {
+			name = "gap"
+		}
+		entries = append(entries, fmt.Sprintf("%s@%s+%g", name, segment.DateTime.UTC().Format("15:04:05"), segment.Duration))
+	}
+	return strings.Join(entries, " ")
+}
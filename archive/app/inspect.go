@@ -0,0 +1,107 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"archive/mpegts"
+	"archive/playlist"
+)
+
+// WithSegmentInspection makes the app inspect every segment as it is
+// archived, noting problems such as lost packets or a duration that does
+// not match the playlist in the segment's report. With reject, segments
+// that aren't playable video are not archived at all, which means each
+// segment is read into memory to inspect it before it is written.
+func WithSegmentInspection(reject bool) Option {
+	return func(app *ArchiveApp) {
+		app.inspect = true
+		app.rejectCorrupt = reject
+	}
+}
+
+// inspectSegment reads a segment into memory to inspect it, and returns a
+// reader over it for archiving with what inspecting it found, which is nil
+// if it isn't a transport stream. The error is set when the segment is
+// rejected.
+func (app *ArchiveApp) inspectSegment(segment playlist.Segment, content io.ReadCloser, report *SegmentReport) (io.ReadCloser, *mpegts.Info, error) {
+	data, err := io.ReadAll(content)
+	content.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read segment: %w", err)
+	}
+
+	info, err := mpegts.Inspect(bytes.NewReader(data))
+	inspected, corrupt := app.noteProblems(segment, info, err, report)
+	if corrupt && app.rejectCorrupt {
+		return nil, nil, fmt.Errorf("corrupt segment: %s", strings.Join(report.Problems, "; "))
+	}
+	return io.NopCloser(bytes.NewReader(data)), inspected, nil
+}
+
+// inspection is what inspecting a segment found
+type inspection struct {
+	info mpegts.Info
+	err  error
+}
+
+// inspectingReader passes a segment on to be archived while it is
+// inspected
+type inspectingReader struct {
+	io.Reader
+	content io.Closer
+	pipe    *io.PipeWriter
+}
+
+// Close ends the inspection and closes the segment
+func (r *inspectingReader) Close() error {
+	r.pipe.Close()
+	return r.content.Close()
+}
+
+// inspectWhileArchiving returns a reader over a segment that inspects it as
+// it is read, without holding it in memory, and a function that waits for
+// what inspecting it found once it has been read
+func inspectWhileArchiving(content io.ReadCloser) (io.ReadCloser, func() (mpegts.Info, error)) {
+	pipeReader, pipeWriter := io.Pipe()
+	done := make(chan inspection, 1)
+	go func() {
+		info, err := mpegts.Inspect(pipeReader)
+		// Keep reading if inspecting gave up early, so archiving the
+		// segment isn't held up
+		io.Copy(io.Discard, pipeReader)
+		done <- inspection{info: info, err: err}
+	}()
+
+	reader := &inspectingReader{Reader: io.TeeReader(content, pipeWriter), content: content, pipe: pipeWriter}
+	return reader, func() (mpegts.Info, error) {
+		pipeWriter.Close()
+		result := <-done
+		return result.info, result.err
+	}
+}
+
+// noteProblems puts the problems inspecting a segment found in its report,
+// and returns what was found, which is nil if it isn't a transport stream,
+// and whether it is not playable video
+func (app *ArchiveApp) noteProblems(segment playlist.Segment, info mpegts.Info, err error, report *SegmentReport) (*mpegts.Info, bool) {
+	corrupt := false
+	if err != nil {
+		corrupt = true
+		report.Problems = []string{fmt.Sprintf("not a transport stream: %v", err)}
+	} else {
+		_, hasVideo := info.VideoStream()
+		corrupt = !hasVideo
+		report.Problems = info.Problems(time.Duration(segment.Duration * float64(time.Second)))
+	}
+	if len(report.Problems) > 0 {
+		app.logger.Warn("Segment has problems", "segment", segment.Filename, "problems", report.Problems)
+	}
+	if err != nil {
+		return nil, corrupt
+	}
+	return &info, corrupt
+}
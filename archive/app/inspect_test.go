@@ -0,0 +1,57 @@
+package app_test
+
+import (
+	"archive/app"
+	"archive/mpegts/mpegtstest"
+	"archive/playlist"
+	"testing"
+	"time"
+)
+
+func TestArchiveApp_Archive_InspectsSegments(t *testing.T) {
+	start := time.Date(2025, 4, 11, 18, 0, 0, 0, time.UTC)
+	video := mpegtstest.Stream{Frames: 250, FrameRate: 25, GOP: 50}.Bytes()
+	tests := []struct {
+		name         string
+		segment      []byte
+		reject       bool
+		wantStatus   string
+		wantProblems bool
+	}{
+		{"intact", video, true, app.SegmentArchived, false},
+		{"intact flagged", video, false, app.SegmentArchived, false},
+		{"garbage flagged", []byte("test segment"), false, app.SegmentArchived, true},
+		{"garbage rejected", []byte("test segment"), true, app.SegmentFailed, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			streamRepo := &mockStreamRepo{
+				playlist: &playlist.Playlist{
+					Segments: []playlist.Segment{{Filename: "segment_00.ts", Duration: 10, DateTime: start}},
+				},
+				segment: tt.segment,
+			}
+			archiveApp := app.NewArchiveApp(streamRepo, &mockArchiveRepo{}, app.WithSegmentInspection(tt.reject))
+
+			// Execute
+			result := archiveApp.Archive()
+
+			// Assert
+			report := result.Segments[0]
+			if report.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s", report.Status, tt.wantStatus)
+			}
+			if (len(report.Problems) > 0) != tt.wantProblems {
+				t.Errorf("Problems = %v, want problems: %v", report.Problems, tt.wantProblems)
+			}
+			if tt.wantStatus == app.SegmentFailed && result.Failures[app.FailureCorruptSegment] != 1 {
+				t.Errorf("Failures = %v, want one %s", result.Failures, app.FailureCorruptSegment)
+			}
+			if tt.wantStatus == app.SegmentArchived && report.Bytes != int64(len(tt.segment)) {
+				t.Errorf("Bytes = %d, want %d", report.Bytes, len(tt.segment))
+			}
+		})
+	}
+}
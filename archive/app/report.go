@@ -0,0 +1,173 @@
+package app
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"archive/archiverepo"
+)
+
+// What happened to a segment of the recorder playlist
+const (
+	// SegmentArchived segments were copied into the archive
+	SegmentArchived = "archived"
+	// SegmentSkipped segments were already in the archive
+	SegmentSkipped = "skipped"
+	// SegmentFailed segments could not be archived and will be retried on
+	// the next run
+	SegmentFailed = "failed"
+)
+
+// SegmentReport says what an archive run did with one segment of the
+// recorder playlist
+type SegmentReport struct {
+	// Filename is the segment's name in the recorder playlist
+	Filename string `json:"filename"`
+	// Time is the segment's PROGRAM-DATE-TIME
+	Time time.Time `json:"time"`
+	// Duration is how long the segment's footage is
+	Duration float64 `json:"duration_seconds"`
+	// Hour is the archive hour directory the segment belongs in
+	Hour   string `json:"hour"`
+	Status string `json:"status"`
+	// Reason explains skipped and failed segments
+	Reason string `json:"reason,omitempty"`
+	// Failure is the kind of operation that failed, one of the Failure
+	// constants
+	Failure string `json:"failure,omitempty"`
+	// ArchivedAs is the segment's name in the archive hour directory
+	ArchivedAs string `json:"archived_as,omitempty"`
+	// Bytes is how much of the segment was written
+	Bytes int64 `json:"bytes,omitempty"`
+	// Problems found inspecting the segment, if it was inspected
+	Problems []string `json:"problems,omitempty"`
+	// Discontinuity says what showed the segment doesn't continue the
+	// footage before it, one of the Restart constants, if it doesn't
+	Discontinuity []string `json:"discontinuity,omitempty"`
+	// GapFilled is how much missing footage before the segment was covered
+	// with placeholders
+	GapFilled float64 `json:"gap_filled_seconds,omitempty"`
+	// Elapsed is how long archiving the segment took
+	Elapsed time.Duration `json:"-"`
+}
+
+// MarshalJSON adds the elapsed time in seconds
+func (r SegmentReport) MarshalJSON() ([]byte, error) {
+	type report SegmentReport
+	return json.Marshal(struct {
+		report
+		Elapsed float64 `json:"elapsed_seconds"`
+	}{report(r), r.Elapsed.Seconds()})
+}
+
+// newSegmentReport starts the report of a segment, as skipped until it is
+// known otherwise
+func newSegmentReport(filename string, dateTime time.Time, duration float64) SegmentReport {
+	return SegmentReport{
+		Filename: filename,
+		Time:     dateTime,
+		Duration: duration,
+		Hour:     archiverepo.HourPath(dateTime),
+		Status:   SegmentSkipped,
+	}
+}
+
+// Hours returns the archive hour directories the run wrote to, in order
+func (r ArchiveResult) Hours() []string {
+	seen := make(map[string]bool)
+	var hours []string
+	for _, segment := range r.Segments {
+		if segment.Status == SegmentArchived && !seen[segment.Hour] {
+			seen[segment.Hour] = true
+			hours = append(hours, segment.Hour)
+		}
+	}
+	sort.Strings(hours)
+	return hours
+}
+
+// Count returns how many segments ended up with a status
+func (r ArchiveResult) Count(status string) int {
+	count := 0
+	for _, segment := range r.Segments {
+		if segment.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Flagged returns how many segments were archived despite problems found
+// inspecting them
+func (r ArchiveResult) Flagged() int {
+	count := 0
+	for _, segment := range r.Segments {
+		if segment.Status == SegmentArchived && len(segment.Problems) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// Discontinuities returns how many archived segments were marked as not
+// continuing the footage before them
+func (r ArchiveResult) Discontinuities() int {
+	count := 0
+	for _, segment := range r.Segments {
+		if segment.Status == SegmentArchived && len(segment.Discontinuity) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// MarshalJSON reports the run for operators, with the error as a message
+// and the lag and hours touched worked out
+func (r ArchiveResult) MarshalJSON() ([]byte, error) {
+	var message string
+	if r.Error != nil {
+		message = r.Error.Error()
+	}
+	segments := r.Segments
+	if segments == nil {
+		segments = []SegmentReport{}
+	}
+	hours := r.Hours()
+	if hours == nil {
+		hours = []string{}
+	}
+	return json.Marshal(struct {
+		Started          time.Time       `json:"started"`
+		Elapsed          float64         `json:"elapsed_seconds"`
+		Error            string          `json:"error,omitempty"`
+		ArchivedSegments int             `json:"archived_segments"`
+		SkippedSegments  int             `json:"skipped_segments"`
+		FailedSegments   int             `json:"failed_segments"`
+		FlaggedSegments  int             `json:"flagged_segments"`
+		Discontinuities  int             `json:"discontinuities"`
+		BytesWritten     int64           `json:"bytes_written"`
+		Failures         map[string]int  `json:"failures"`
+		LiveEnd          time.Time       `json:"live_end"`
+		ArchivedEnd      time.Time       `json:"archived_end"`
+		Lag              float64         `json:"lag_seconds"`
+		Hours            []string        `json:"hours"`
+		Segments         []SegmentReport `json:"segments"`
+	}{
+		Started:          r.Started,
+		Elapsed:          r.Elapsed.Seconds(),
+		Error:            message,
+		ArchivedSegments: r.ArchivedSegments,
+		SkippedSegments:  r.Count(SegmentSkipped),
+		FailedSegments:   r.Count(SegmentFailed),
+		FlaggedSegments:  r.Flagged(),
+		Discontinuities:  r.Discontinuities(),
+		BytesWritten:     r.BytesWritten,
+		Failures:         r.Failures,
+		LiveEnd:          r.LiveEnd,
+		ArchivedEnd:      r.ArchivedEnd,
+		Lag:              r.Lag().Seconds(),
+		Hours:            hours,
+		Segments:         segments,
+	})
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"archive/notify"
+)
+
+// notifyTimeout bounds how long notifiers may take to deliver an event
+const notifyTimeout = 10 * time.Second
+
+// WithStallDetection raises a stalled event through notifier once the
+// newest PROGRAM-DATE-TIME in the recorder playlist is older than
+// threshold, and a recovered event once it has stayed newer than threshold
+// for recoveryHold. Holding off recovery keeps a recorder that keeps
+// dropping out from raising an event every tick.
+func WithStallDetection(threshold, recoveryHold time.Duration, notifier notify.Notifier) Option {
+	return func(app *ArchiveApp) {
+		app.stall = &stallDetector{
+			threshold:    threshold,
+			recoveryHold: recoveryHold,
+			notifier:     notifier,
+		}
+	}
+}
+
+// stallDetector tracks the age of the recorder's newest segment
+type stallDetector struct {
+	threshold    time.Duration
+	recoveryHold time.Duration
+	notifier     notify.Notifier
+
+	started    time.Time
+	newest     time.Time
+	stalled    bool
+	freshSince time.Time
+}
+
+// observe records the newest segment time seen at now, which is zero if
+// the playlist could not be read or was empty, and returns the event to
+// raise, if any
+func (d *stallDetector) observe(now, newest time.Time) *notify.Event {
+	if d.started.IsZero() {
+		d.started = now
+	}
+	if newest.After(d.newest) {
+		d.newest = newest
+	}
+	// Before any segment was seen, the recorder has been stalled since the
+	// app started
+	reference := d.newest
+	if reference.IsZero() {
+		reference = d.started
+	}
+	age := now.Sub(reference)
+	fresh := age <= d.threshold
+
+	switch {
+	case !d.stalled && !fresh:
+		d.stalled = true
+		d.freshSince = time.Time{}
+		return &notify.Event{Kind: notify.Stalled, Time: now, Newest: d.newest, Age: age}
+	case d.stalled && fresh:
+		if d.freshSince.IsZero() {
+			d.freshSince = now
+		}
+		if now.Sub(d.freshSince) >= d.recoveryHold {
+			d.stalled = false
+			return &notify.Event{Kind: notify.Recovered, Time: now, Newest: d.newest, Age: age}
+		}
+	case d.stalled && !fresh:
+		d.freshSince = time.Time{}
+	}
+	return nil
+}
+
+// checkStall raises stalled and recovered events for the newest segment
+// time in the recorder playlist
+func (app *ArchiveApp) checkStall(newest time.Time) {
+	if app.stall == nil {
+		return
+	}
+	event := app.stall.observe(app.now(), newest)
+	if event == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	if err := app.stall.notifier.Notify(ctx, *event); err != nil {
+		app.logger.Error("Failed to notify", "event", event.Kind, "error", err)
+	}
+}
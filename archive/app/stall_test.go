@@ -0,0 +1,125 @@
+package app_test
+
+import (
+	"archive/app"
+	"archive/notify"
+	"archive/playlist"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestArchiveApp_Archive_DetectsStallAndRecovery(t *testing.T) {
+	// Setup
+	start := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	now := start
+	streamRepo := &mockStreamRepo{playlist: livePlaylist(start), segment: []byte("test segment")}
+	notifier := &recordingNotifier{}
+	archiveApp := app.NewArchiveApp(streamRepo, &mockArchiveRepo{},
+		app.WithClock(func() time.Time { return now }),
+		app.WithStallDetection(2*time.Minute, 2*time.Minute, notifier))
+
+	// Execute
+	tick := func(advance time.Duration) {
+		now = now.Add(advance)
+		archiveApp.Archive()
+	}
+	tick(0)
+	tick(time.Minute)
+	tick(2 * time.Minute)
+	stalledAfter := len(notifier.events)
+	tick(time.Minute)
+	streamRepo.playlist = livePlaylist(now)
+	tick(0)
+	tick(time.Minute)
+	recoveringAfter := len(notifier.events)
+	streamRepo.playlist = livePlaylist(now)
+	tick(time.Minute)
+
+	// Assert
+	if stalledAfter != 1 || notifier.events[0].Kind != notify.Stalled {
+		t.Fatalf("events after stall = %v, want one stalled event", notifier.events[:stalledAfter])
+	}
+	if got, want := notifier.events[0].Newest, start.Add(10*time.Second); !got.Equal(want) {
+		t.Errorf("stalled Newest = %v, want %v", got, want)
+	}
+	if recoveringAfter != 1 {
+		t.Errorf("events before the recovery hold = %d, want 1", recoveringAfter)
+	}
+	if len(notifier.events) != 2 || notifier.events[1].Kind != notify.Recovered {
+		t.Errorf("events = %v, want stalled then recovered", notifier.events)
+	}
+}
+
+func TestArchiveApp_Archive_SuppressesFlapping(t *testing.T) {
+	// Setup
+	now := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	streamRepo := &mockStreamRepo{playlist: livePlaylist(now), segment: []byte("test segment")}
+	notifier := &recordingNotifier{}
+	archiveApp := app.NewArchiveApp(streamRepo, &mockArchiveRepo{},
+		app.WithClock(func() time.Time { return now }),
+		app.WithStallDetection(2*time.Minute, 5*time.Minute, notifier))
+
+	// Execute: the recorder keeps dropping out for a few minutes at a time
+	for i := 0; i < 5; i++ {
+		now = now.Add(4 * time.Minute)
+		archiveApp.Archive()
+		streamRepo.playlist = livePlaylist(now)
+		archiveApp.Archive()
+	}
+
+	// Assert
+	if len(notifier.events) != 1 || notifier.events[0].Kind != notify.Stalled {
+		t.Errorf("events = %v, want a single stalled event", notifier.events)
+	}
+}
+
+func TestArchiveApp_Archive_StalledWhenPlaylistUnreadable(t *testing.T) {
+	// Setup
+	now := time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)
+	streamRepo := &mockStreamRepo{err: errors.New("no such file")}
+	notifier := &recordingNotifier{}
+	archiveApp := app.NewArchiveApp(streamRepo, &mockArchiveRepo{},
+		app.WithClock(func() time.Time { return now }),
+		app.WithStallDetection(2*time.Minute, 2*time.Minute, notifier))
+
+	// Execute
+	archiveApp.Archive()
+	now = now.Add(3 * time.Minute)
+	archiveApp.Archive()
+
+	// Assert
+	if len(notifier.events) != 1 || notifier.events[0].Kind != notify.Stalled {
+		t.Fatalf("events = %v, want one stalled event", notifier.events)
+	}
+	if !notifier.events[0].Newest.IsZero() {
+		t.Errorf("Newest = %v, want zero", notifier.events[0].Newest)
+	}
+	if got, want := notifier.events[0].Age, 3*time.Minute; got != want {
+		t.Errorf("Age = %v, want %v", got, want)
+	}
+}
+
+// livePlaylist returns a recorder playlist with two segments starting at
+// start
+func livePlaylist(start time.Time) *playlist.Playlist {
+	return &playlist.Playlist{
+		Version:        3,
+		TargetDuration: 10,
+		Segments: []playlist.Segment{
+			{Filename: "segment_00.ts", Duration: 10, DateTime: start},
+			{Filename: "segment_01.ts", Duration: 10, DateTime: start.Add(10 * time.Second)},
+		},
+	}
+}
+
+// recordingNotifier remembers the events it was given
+type recordingNotifier struct {
+	events []notify.Event
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, event notify.Event) error {
+	n.events = append(n.events, event)
+	return nil
+}
@@ -1,121 +1,94 @@
 package archiverepo
 
 import (
+	"archive/objectstore"
 	"archive/playlist"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"log/slog"
+	"path"
+	"strings"
 	"time"
 )
 
-// ArchiveRepository stores video playlists and segments on the filesystem
+// PlaylistName is the name of the playlist in every archived hour directory
+const PlaylistName = "playlist.m3u8"
+
+const (
+	// GapName is the URI of EXT-X-GAP placeholders, which has no file
+	GapName = "gap.ts"
+	// SlateName is the segment shown in place of missing footage when gaps
+	// are filled with a slate
+	SlateName = "slate.ts"
+)
+
+// IsPlaceholder reports whether a playlist entry stands in for missing
+// footage rather than holding recorded footage
+func IsPlaceholder(segment playlist.Segment) bool {
+	return segment.Gap || segment.Filename == SlateName
+}
+
+// ArchiveRepository stores video playlists and segments in an object store
 type ArchiveRepository struct {
-	basePath string
+	store  objectstore.Store
+	parser *playlist.Parser
+	logger *slog.Logger
 }
 
-// NewArchiveRepository creates a new ArchiveRepository
-func New(basePath string) *ArchiveRepository {
+// New creates a new ArchiveRepository
+func New(store objectstore.Store, parser *playlist.Parser, logger *slog.Logger) *ArchiveRepository {
 	return &ArchiveRepository{
-		basePath: basePath,
+		store:  store,
+		parser: parser,
+		logger: logger,
 	}
 }
 
-// getBackupPath returns the path for a specific time
-func (r *ArchiveRepository) getBackupPath(segmentTime time.Time) (string, error) {
-	path := filepath.Join(r.basePath,
+// HourPath returns the slash-separated path, relative to the archive root, of
+// the hour directory holding segments recorded at a specific time, such as
+// 2025/04/11/00. Paths are always laid out in UTC so that a recorder
+// changing its offset, or a DST change, cannot split or merge hours. This is
+// the layout the videoserver relies on to find archived footage.
+func HourPath(segmentTime time.Time) string {
+	segmentTime = segmentTime.UTC()
+	return path.Join(
 		fmt.Sprintf("%d", segmentTime.Year()),
 		fmt.Sprintf("%02d", segmentTime.Month()),
 		fmt.Sprintf("%02d", segmentTime.Day()),
 		fmt.Sprintf("%02d", segmentTime.Hour()))
-	return path, nil
 }
 
-// ReadPlaylist reads the archive playlist from the filesystem for a specific time
+// ReadPlaylist reads the archive playlist for a specific time
 func (r *ArchiveRepository) ReadPlaylist(segmentTime time.Time) (*playlist.Playlist, error) {
-	// Ensure backup directory exists before reading
-	if err := r.ensureBackupDirectory(segmentTime); err != nil {
-		return nil, err
-	}
-
-	path, err := r.getBackupPath(segmentTime)
-	if err != nil {
-		return nil, err
-	}
-
-	playlistPath := filepath.Join(path, "playlist.m3u8")
-	file, err := os.Open(playlistPath)
+	key := path.Join(HourPath(segmentTime), PlaylistName)
+	file, err := r.store.Get(context.Background(), key)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, objectstore.ErrNotExist) {
 			// If the playlist doesn't exist, return nil
+			r.logger.Debug("No archive playlist yet", "key", key)
 			return nil, nil
 		}
 		return nil, err
 	}
 	defer file.Close()
 
-	return playlist.Parse(file)
+	return r.parser.Parse(file)
 }
 
-// WritePlaylist writes the playlist to the filesystem for a specific time
+// WritePlaylist writes the archive playlist for a specific time
 func (r *ArchiveRepository) WritePlaylist(segmentTime time.Time, playlist *playlist.Playlist) error {
-	// Ensure backup directory exists before writing
-	if err := r.ensureBackupDirectory(segmentTime); err != nil {
-		return err
-	}
-
-	path, err := r.getBackupPath(segmentTime)
-	if err != nil {
-		return err
-	}
-
-	playlistPath := filepath.Join(path, "playlist.m3u8")
-	file, err := os.Create(playlistPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	_, err = file.WriteString(playlist.String())
-	return err
+	key := path.Join(HourPath(segmentTime), PlaylistName)
+	r.logger.Debug("Writing archive playlist", "key", key, "segments", len(playlist.Segments))
+	return r.store.Put(context.Background(), key, strings.NewReader(playlist.String()))
 }
 
-// WriteSegment writes a segment to the filesystem for a specific time
+// WriteSegment writes a segment for a specific time and closes its content
 func (r *ArchiveRepository) WriteSegment(segmentTime time.Time, filename string, content io.ReadCloser) error {
-	// Ensure backup directory exists before writing
-	if err := r.ensureBackupDirectory(segmentTime); err != nil {
-		return err
-	}
+	defer content.Close()
 
-	path, err := r.getBackupPath(segmentTime)
-	if err != nil {
-		return err
-	}
-
-	segmentPath := filepath.Join(path, filename)
-	file, err := os.Create(segmentPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	_, err = io.Copy(file, content)
-	return err
-}
-
-// ensureBackupDirectory creates the backup directory if it doesn't exist
-// This is now a private method used internally by the repository
-func (r *ArchiveRepository) ensureBackupDirectory(segmentTime time.Time) error {
-	path, err := r.getBackupPath(segmentTime)
-	if err != nil {
-		return err
-	}
-	fmt.Printf("Ensuring backup directory exists: %s\n", path)
-	err = os.MkdirAll(path, 0755)
-	if err != nil {
-		fmt.Printf("Error creating directory %s: %v\n", path, err)
-		return err
-	}
-	fmt.Printf("Successfully created/verified directory: %s\n", path)
-	return nil
+	key := path.Join(HourPath(segmentTime), filename)
+	r.logger.Debug("Writing segment", "key", key)
+	return r.store.Put(context.Background(), key, content)
 }
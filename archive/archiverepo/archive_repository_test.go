@@ -0,0 +1,126 @@
+package archiverepo
+
+import (
+	"archive/objectstore"
+	"archive/playlist"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHourPath_NormalizesToUTC(t *testing.T) {
+	tests := []struct {
+		name     string
+		time     time.Time
+		expected string
+	}{
+		{
+			name:     "utc",
+			time:     time.Date(2025, 4, 11, 0, 27, 48, 0, time.UTC),
+			expected: "2025/04/11/00",
+		},
+		{
+			name:     "positive offset on the previous utc day",
+			time:     time.Date(2025, 4, 11, 1, 27, 48, 0, time.FixedZone("CEST", 2*60*60)),
+			expected: "2025/04/10/23",
+		},
+		{
+			name:     "negative offset on the next utc day",
+			time:     time.Date(2025, 12, 31, 20, 5, 0, 0, time.FixedZone("EST", -5*60*60)),
+			expected: "2026/01/01/01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HourPath(tt.time); got != tt.expected {
+				t.Errorf("HourPath = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHourPath_DSTChangeKeepsHoursApart(t *testing.T) {
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	// When the clocks go back, 00:30 and 01:30 UTC are both 02:30 in Berlin
+	beforeChange := time.Date(2025, 10, 26, 0, 30, 0, 0, time.UTC).In(location)
+	afterChange := time.Date(2025, 10, 26, 1, 30, 0, 0, time.UTC).In(location)
+	if beforeChange.Hour() != afterChange.Hour() {
+		t.Fatalf("expected the same local hour, got %v and %v", beforeChange, afterChange)
+	}
+
+	if HourPath(beforeChange) == HourPath(afterChange) {
+		t.Errorf("expected separate hours across the DST change, both were %s", HourPath(beforeChange))
+	}
+}
+
+func TestHourPath(t *testing.T) {
+	// Months, days and hours are one-based where that applies and always
+	// zero-padded to two digits
+	tests := []struct {
+		time     time.Time
+		expected string
+	}{
+		{time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), "2025/01/02/03"},
+		{time.Date(2025, 12, 31, 23, 59, 59, 0, time.UTC), "2025/12/31/23"},
+		{time.Date(2025, 4, 11, 0, 0, 0, 0, time.FixedZone("", 30*60)), "2025/04/10/23"},
+	}
+
+	for _, tt := range tests {
+		if got := HourPath(tt.time); got != tt.expected {
+			t.Errorf("HourPath(%v) = %s, want %s", tt.time, got, tt.expected)
+		}
+	}
+}
+
+func TestArchiveRepository_RoundTrip(t *testing.T) {
+	// Setup
+	store := objectstore.NewMemory()
+	repo := New(store, playlist.NewParser(slog.Default()), slog.Default())
+	segmentTime := time.Date(2025, 4, 11, 0, 27, 48, 0, time.UTC)
+
+	// A missing playlist reads as nil
+	missing, err := repo.ReadPlaylist(segmentTime)
+	if err != nil || missing != nil {
+		t.Fatalf("ReadPlaylist = %v, %v, want nil, nil", missing, err)
+	}
+
+	// Execute
+	err = repo.WriteSegment(segmentTime, "segment_000.ts", io.NopCloser(strings.NewReader("segment")))
+	if err != nil {
+		t.Fatalf("WriteSegment failed: %v", err)
+	}
+	written := &playlist.Playlist{
+		Version:        3,
+		TargetDuration: 10,
+		Segments: []playlist.Segment{{
+			Filename:        "segment_000.ts",
+			Duration:        10,
+			DateTime:        segmentTime,
+			ProgramDateTime: "2025-04-11T00:27:48.000+0000",
+		}},
+	}
+	if err := repo.WritePlaylist(segmentTime, written); err != nil {
+		t.Fatalf("WritePlaylist failed: %v", err)
+	}
+	read, err := repo.ReadPlaylist(segmentTime)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("ReadPlaylist failed: %v", err)
+	}
+	if read.String() != written.String() {
+		t.Errorf("ReadPlaylist =\n%s\nwant\n%s", read.String(), written.String())
+	}
+	info, err := store.Stat(context.Background(), "2025/04/11/00/segment_000.ts")
+	if err != nil || info.Size != int64(len("segment")) {
+		t.Errorf("Stat = %+v, %v, want the written segment", info, err)
+	}
+}
@@ -0,0 +1,168 @@
+package archiverepo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"strings"
+	"time"
+
+	"archive/mpegts"
+	"archive/playlist"
+)
+
+const (
+	// IFramesName is the name of the I-frame playlist in an hour directory
+	IFramesName = "iframes.m3u8"
+	// MasterName is the name of the master playlist in an hour directory,
+	// which lists the hour's playlist and its I-frame playlist
+	MasterName = "master.m3u8"
+)
+
+// IFramesReport says what indexing the keyframes of an hour found
+type IFramesReport struct {
+	Hour     string `json:"hour"`
+	Segments int    `json:"segments"`
+	IFrames  int    `json:"iframes"`
+	// Skipped are segments whose keyframes could not be found, which the
+	// I-frame playlist covers with gaps
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// IndexIFrames writes an I-frame playlist for an hour, with the byte range
+// of every keyframe in its segments, and a master playlist listing it with
+// the hour's playlist. Entries span the time to the next keyframe, so the
+// I-frame playlist keeps to the same timeline as the hour's playlist;
+// placeholders and segments without keyframes are covered with gaps.
+func (r *ArchiveRepository) IndexIFrames(ctx context.Context, hour string) (IFramesReport, error) {
+	report := IFramesReport{Hour: hour}
+	hourPlaylist, err := r.readHourPlaylist(ctx, hour)
+	if err != nil {
+		return report, err
+	}
+
+	iframes := &playlist.Playlist{
+		Version:        max(hourPlaylist.Version, playlist.IFramesVersion),
+		TargetDuration: 1,
+		MediaSequence:  hourPlaylist.MediaSequence,
+		IFramesOnly:    true,
+	}
+	variant := playlist.Variant{URI: PlaylistName}
+	iframeVariant := playlist.Variant{URI: IFramesName}
+	for _, segment := range hourPlaylist.Segments {
+		var entries []playlist.Segment
+		if !IsPlaceholder(segment) {
+			report.Segments++
+			info, err := r.inspect(ctx, path.Join(hour, segment.Filename))
+			if err != nil {
+				r.logger.Warn("Failed to index keyframes", "hour", hour, "segment", segment.Filename, "error", err)
+			} else {
+				entries = keyframeEntries(segment, info)
+				variant.Bandwidth = max(variant.Bandwidth, bitRate(info.Size, segment.Duration))
+				if video, found := info.VideoStream(); found && variant.Width == 0 {
+					variant.Width, variant.Height = video.Width, video.Height
+				}
+			}
+			if len(entries) == 0 {
+				report.Skipped = append(report.Skipped, segment.Filename)
+			}
+		}
+		if len(entries) == 0 {
+			entries = []playlist.Segment{{
+				Filename:        GapName,
+				DateTime:        segment.DateTime,
+				ProgramDateTime: segment.ProgramDateTime,
+				Duration:        segment.Duration,
+				Gap:             true,
+			}}
+			iframes.Version = max(iframes.Version, playlist.GapVersion)
+		}
+		entries[0].Discontinuity, entries[0].DateRanges = segment.Discontinuity, segment.DateRanges
+
+		for _, entry := range entries {
+			iframes.TargetDuration = max(iframes.TargetDuration, int(math.Ceil(entry.Duration)))
+			if entry.Map != nil {
+				iframes.Version = max(iframes.Version, playlist.IFramesMapVersion)
+			}
+			if entry.ByteRange != nil {
+				report.IFrames++
+				iframeVariant.Bandwidth = max(iframeVariant.Bandwidth, bitRate(entry.ByteRange.Length, entry.Duration))
+			}
+		}
+		iframes.Segments = append(iframes.Segments, entries...)
+	}
+	iframeVariant.Width, iframeVariant.Height = variant.Width, variant.Height
+
+	master := &playlist.Master{
+		Version:        playlist.IFramesVersion,
+		Variants:       []playlist.Variant{variant},
+		IFrameVariants: []playlist.Variant{iframeVariant},
+	}
+	// The master playlist is written last, so it never lists an I-frame
+	// playlist that doesn't exist yet
+	for _, file := range []struct{ name, content string }{
+		{IFramesName, iframes.String()},
+		{MasterName, master.String()},
+	} {
+		key := path.Join(hour, file.name)
+		if err := r.store.Put(ctx, key, strings.NewReader(file.content)); err != nil {
+			return report, fmt.Errorf("failed to write %s: %w", key, err)
+		}
+	}
+	r.logger.Info("Indexed keyframes", "hour", hour, "segments", report.Segments, "iframes", report.IFrames,
+		"skipped_segments", len(report.Skipped))
+	return report, nil
+}
+
+// inspect reads and inspects an archived segment
+func (r *ArchiveRepository) inspect(ctx context.Context, key string) (mpegts.Info, error) {
+	file, err := r.store.Get(ctx, key)
+	if err != nil {
+		return mpegts.Info{}, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	defer file.Close()
+	return mpegts.Inspect(file)
+}
+
+// keyframeEntries returns the I-frame playlist entries of a segment's
+// keyframes. Each lasts until the next keyframe or the end of the segment,
+// and the first also covers any frames before it, so that together they
+// last as long as the segment. The segment's program tables are mapped
+// ahead of its keyframes, which can't be decoded without them.
+func keyframeEntries(segment playlist.Segment, info mpegts.Info) []playlist.Segment {
+	var entries []playlist.Segment
+	var tables *playlist.Map
+	if info.Tables > 0 {
+		tables = &playlist.Map{URI: segment.Filename, ByteRange: &playlist.ByteRange{Length: info.Tables}}
+	}
+	covered := 0.0
+	for i, keyframe := range info.Keyframes {
+		end := segment.Duration
+		if i+1 < len(info.Keyframes) {
+			end = min(info.Keyframes[i+1].Time.Seconds(), end)
+		}
+		if end <= covered {
+			continue
+		}
+		at := segment.DateTime.Add(time.Duration(covered * float64(time.Second)))
+		entries = append(entries, playlist.Segment{
+			Filename:        segment.Filename,
+			DateTime:        at,
+			ProgramDateTime: at.UTC().Format(playlist.DateTimeLayout),
+			Duration:        end - covered,
+			ByteRange:       &playlist.ByteRange{Length: keyframe.Size, Offset: keyframe.Offset},
+			Map:             tables,
+		})
+		covered = end
+	}
+	return entries
+}
+
+// bitRate returns the bits per second of size bytes played over seconds
+func bitRate(size int64, seconds float64) int64 {
+	if seconds <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(float64(size) * 8 / seconds))
+}
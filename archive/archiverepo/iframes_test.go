@@ -0,0 +1,117 @@
+package archiverepo
+
+import (
+	"archive/mpegts"
+	"archive/mpegts/mpegtstest"
+	"archive/objectstore"
+	"archive/playlist"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestArchiveRepository_IndexIFrames(t *testing.T) {
+	// Setup: 10s of video with keyframes every 2s, a filled gap and a
+	// segment that isn't video
+	ctx := context.Background()
+	store := objectstore.NewMemory()
+	repo := New(store, playlist.NewParser(slog.Default()), slog.Default())
+	hour := "2025/04/11/18"
+	video := mpegtstest.Stream{Frames: 250, FrameRate: 25, GOP: 50, Width: 1280, Height: 720}.Bytes()
+	for key, content := range map[string]string{
+		hour + "/playlist.m3u8": `#EXTM3U
+#EXT-X-VERSION:8
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:00Z
+segment_000.ts
+#EXT-X-GAP
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:10Z
+gap.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:20Z
+segment_002.ts
+`,
+		hour + "/segment_000.ts": string(video),
+		hour + "/segment_002.ts": "garbage",
+	} {
+		if err := store.Put(ctx, key, strings.NewReader(content)); err != nil {
+			t.Fatalf("Put(%s) failed: %v", key, err)
+		}
+	}
+
+	// Execute
+	report, err := repo.IndexIFrames(ctx, hour)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("IndexIFrames failed: %v", err)
+	}
+	if report.Segments != 2 || report.IFrames != 5 || fmt.Sprint(report.Skipped) != "[segment_002.ts]" {
+		t.Errorf("report = %+v, want 5 I-frames from 2 segments with segment_002.ts skipped", report)
+	}
+	iframes := readPlaylistNamed(t, store, hour, IFramesName)
+	if !iframes.IFramesOnly || len(iframes.Segments) != 7 {
+		t.Fatalf("I-frame playlist has %d entries, want 7 I-frames only", len(iframes.Segments))
+	}
+	total := 0.0
+	for i, entry := range iframes.Segments {
+		total += entry.Duration
+		if i >= 5 {
+			if !entry.Gap {
+				t.Errorf("entry %d = %+v, want a gap", i, entry)
+			}
+			continue
+		}
+		if entry.Duration != 2 || entry.ByteRange == nil || entry.ByteRange.Offset+entry.ByteRange.Length > int64(len(video)) {
+			t.Errorf("entry %d = %+v, want 2s of a keyframe inside the segment", i, entry)
+		}
+	}
+	if iframes.Version < playlist.IFramesMapVersion {
+		t.Errorf("Version = %d, want at least %d for EXT-X-MAP", iframes.Version, playlist.IFramesMapVersion)
+	}
+	// Every keyframe comes with the program tables at the start of its
+	// segment, which players need to decode it
+	if tables := iframes.Segments[0].Map; tables == nil || tables.URI != "segment_000.ts" ||
+		tables.ByteRange == nil || *tables.ByteRange != (playlist.ByteRange{Length: 2 * mpegts.PacketSize}) {
+		t.Errorf("first entry map = %+v, want the PAT and PMT of segment_000.ts", tables)
+	}
+	if total != 30 {
+		t.Errorf("I-frame playlist lasts %vs, want the hour playlist's 30s", total)
+	}
+	if !iframes.Segments[6].Discontinuity {
+		t.Errorf("last entry = %+v, want the segment's discontinuity", iframes.Segments[6])
+	}
+
+	file, err := store.Get(ctx, hour+"/"+MasterName)
+	if err != nil {
+		t.Fatalf("Get master playlist failed: %v", err)
+	}
+	defer file.Close()
+	master, _ := io.ReadAll(file)
+	for _, want := range []string{"RESOLUTION=1280x720\nplaylist.m3u8\n", `URI="iframes.m3u8"`} {
+		if !strings.Contains(string(master), want) {
+			t.Errorf("master playlist =\n%s\nwant it to contain %q", master, want)
+		}
+	}
+}
+
+func readPlaylistNamed(t *testing.T, store objectstore.Store, hour, name string) *playlist.Playlist {
+	t.Helper()
+	file, err := store.Get(context.Background(), hour+"/"+name)
+	if err != nil {
+		t.Fatalf("Get %s failed: %v", name, err)
+	}
+	defer file.Close()
+	parsed, err := playlist.Parse(file)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return parsed
+}
@@ -0,0 +1,180 @@
+package archiverepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"archive/objectstore"
+	"archive/playlist"
+)
+
+// ReindexReport says what rebuilding an hour's playlist changed
+type ReindexReport struct {
+	// Hour is the hour directory, such as 2025/04/11/18
+	Hour string `json:"hour"`
+	// Segments is how many segments the rebuilt playlist lists
+	Segments int `json:"segments"`
+	// Missing are segments the playlist listed whose files are gone
+	Missing []string `json:"missing,omitempty"`
+	// Duplicates are segments dropped for starting at the same time as an
+	// earlier one
+	Duplicates []string `json:"duplicates,omitempty"`
+	// Unlisted are segment files the playlist does not list. Their times
+	// are unknown, so they are left alone.
+	Unlisted []string `json:"unlisted,omitempty"`
+	// Superseded counts placeholders dropped because footage for their time
+	// has since been archived
+	Superseded int `json:"superseded_placeholders,omitempty"`
+	// Reordered is set when segments were out of time order
+	Reordered bool `json:"reordered,omitempty"`
+	// Changed is set when the playlist needed rewriting
+	Changed bool `json:"changed"`
+}
+
+// Hours returns every hour directory in the archive, oldest first
+func (r *ArchiveRepository) Hours(ctx context.Context) ([]string, error) {
+	// Hours are four levels down: year, month, day and hour
+	dirs := []string{""}
+	for level := 0; level < 4; level++ {
+		var next []string
+		for _, dir := range dirs {
+			infos, err := r.store.List(ctx, dir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %q: %w", dir, err)
+			}
+			for _, info := range infos {
+				if info.IsDir {
+					next = append(next, info.Key)
+				}
+			}
+		}
+		dirs = next
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// readHourPlaylist reads the playlist of an hour directory, which must
+// exist
+func (r *ArchiveRepository) readHourPlaylist(ctx context.Context, hour string) (*playlist.Playlist, error) {
+	key := path.Join(hour, PlaylistName)
+	file, err := r.store.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, objectstore.ErrNotExist) {
+			return nil, fmt.Errorf("hour %s has no playlist", hour)
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	defer file.Close()
+
+	hourPlaylist, err := r.parser.Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", key, err)
+	}
+	return hourPlaylist, nil
+}
+
+// dropSuperseded drops the placeholders that overlap recorded footage,
+// such as footage backfilled into a gap after it was filled
+func (r *ArchiveRepository) dropSuperseded(segments []playlist.Segment, report *ReindexReport) []playlist.Segment {
+	end := func(segment playlist.Segment) time.Time {
+		return segment.DateTime.Add(time.Duration(segment.Duration * float64(time.Second)))
+	}
+	var kept []playlist.Segment
+	for _, segment := range segments {
+		superseded := false
+		for _, recorded := range segments {
+			if IsPlaceholder(segment) && !IsPlaceholder(recorded) &&
+				segment.DateTime.Before(end(recorded)) && recorded.DateTime.Before(end(segment)) {
+				superseded = true
+				break
+			}
+		}
+		if superseded {
+			report.Superseded++
+			continue
+		}
+		kept = append(kept, segment)
+	}
+	return kept
+}
+
+// Reindex rebuilds an hour's playlist from the segments that are really in
+// the hour directory: it drops entries whose files are gone, placeholders
+// for time that now has footage and entries that repeat a start time,
+// sorts the rest by time and raises the target duration to fit the longest
+// segment. With dryRun the playlist is only checked.
+func (r *ArchiveRepository) Reindex(ctx context.Context, hour string, dryRun bool) (ReindexReport, error) {
+	report := ReindexReport{Hour: hour}
+	key := path.Join(hour, PlaylistName)
+	hourPlaylist, err := r.readHourPlaylist(ctx, hour)
+	if err != nil {
+		return report, err
+	}
+
+	infos, err := r.store.List(ctx, hour)
+	if err != nil {
+		return report, fmt.Errorf("failed to list %s: %w", hour, err)
+	}
+	files := make(map[string]bool)
+	for _, info := range infos {
+		if !info.IsDir && strings.HasSuffix(info.Name(), ".ts") {
+			files[info.Name()] = true
+		}
+	}
+
+	listed := make(map[string]bool)
+	var segments []playlist.Segment
+	for _, segment := range hourPlaylist.Segments {
+		listed[segment.Filename] = true
+		if !files[segment.Filename] && !segment.Gap {
+			report.Missing = append(report.Missing, segment.Filename)
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	for name := range files {
+		if !listed[name] {
+			report.Unlisted = append(report.Unlisted, name)
+		}
+	}
+	sort.Strings(report.Unlisted)
+
+	if !sort.SliceIsSorted(segments, func(i, j int) bool { return segments[i].DateTime.Before(segments[j].DateTime) }) {
+		report.Reordered = true
+		sort.SliceStable(segments, func(i, j int) bool { return segments[i].DateTime.Before(segments[j].DateTime) })
+	}
+	segments = r.dropSuperseded(segments, &report)
+	deduplicated := segments[:0]
+	for _, segment := range segments {
+		if n := len(deduplicated); n > 0 && deduplicated[n-1].DateTime.Equal(segment.DateTime) {
+			report.Duplicates = append(report.Duplicates, segment.Filename)
+			continue
+		}
+		deduplicated = append(deduplicated, segment)
+	}
+
+	rebuilt := *hourPlaylist
+	rebuilt.Segments = deduplicated
+	for _, segment := range deduplicated {
+		rebuilt.TargetDuration = max(rebuilt.TargetDuration, int(math.Ceil(segment.Duration)))
+	}
+	report.Segments = len(deduplicated)
+	report.Changed = rebuilt.String() != hourPlaylist.String()
+	if !report.Changed || dryRun {
+		return report, nil
+	}
+
+	r.logger.Info("Rewriting archive playlist", "key", key, "missing", len(report.Missing),
+		"duplicates", len(report.Duplicates), "reordered", report.Reordered)
+	if err := r.store.Put(ctx, key, strings.NewReader(rebuilt.String())); err != nil {
+		return report, fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	return report, nil
+}
@@ -0,0 +1,198 @@
+package archiverepo
+
+import (
+	"archive/mpegts/mpegtstest"
+	"archive/objectstore"
+	"archive/playlist"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestArchiveRepository_Reindex(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	store := objectstore.NewMemory()
+	repo := New(store, playlist.NewParser(slog.Default()), slog.Default())
+	hour := "2025/04/11/18"
+	put := func(key, content string) {
+		if err := store.Put(ctx, key, strings.NewReader(content)); err != nil {
+			t.Fatalf("Put(%s) failed: %v", key, err)
+		}
+	}
+	put(hour+"/playlist.m3u8", `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:10Z
+segment_001.ts
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:00Z
+segment_000.ts
+#EXTINF:12.5,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:10Z
+segment_002.ts
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:20Z
+segment_003.ts
+`)
+	put(hour+"/segment_000.ts", "0")
+	put(hour+"/segment_001.ts", "1")
+	put(hour+"/segment_002.ts", "2")
+	put(hour+"/segment_004.ts", "4")
+	put("2025/04/11/19/playlist.m3u8", "#EXTM3U\n")
+
+	// Execute
+	hours, err := repo.Hours(ctx)
+	if err != nil {
+		t.Fatalf("Hours failed: %v", err)
+	}
+	dryRun, err := repo.Reindex(ctx, hour, true)
+	if err != nil {
+		t.Fatalf("Reindex dry run failed: %v", err)
+	}
+	unchanged := readPlaylist(t, store, hour)
+	report, err := repo.Reindex(ctx, hour, false)
+	if err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	again, err := repo.Reindex(ctx, hour, false)
+	if err != nil {
+		t.Fatalf("second Reindex failed: %v", err)
+	}
+
+	// Assert
+	if got, want := fmt.Sprint(hours), "[2025/04/11/18 2025/04/11/19]"; got != want {
+		t.Errorf("Hours = %s, want %s", got, want)
+	}
+	if !dryRun.Changed || len(unchanged.Segments) != 4 {
+		t.Errorf("dry run Changed = %v with %d segments left in place, want true with 4", dryRun.Changed, len(unchanged.Segments))
+	}
+	if got, want := fmt.Sprint(report.Missing, report.Duplicates, report.Unlisted, report.Reordered), "[segment_003.ts] [segment_002.ts] [segment_004.ts] true"; got != want {
+		t.Errorf("Missing, Duplicates, Unlisted, Reordered = %s, want %s", got, want)
+	}
+	rebuilt := readPlaylist(t, store, hour)
+	var names []string
+	for _, segment := range rebuilt.Segments {
+		names = append(names, segment.Filename)
+	}
+	if got, want := fmt.Sprint(names), "[segment_000.ts segment_001.ts]"; got != want {
+		t.Errorf("rebuilt segments = %s, want %s", got, want)
+	}
+	if again.Changed {
+		t.Errorf("second Reindex Changed = true, want false")
+	}
+}
+
+func readPlaylist(t *testing.T, store objectstore.Store, hour string) *playlist.Playlist {
+	t.Helper()
+	return readPlaylistNamed(t, store, hour, PlaylistName)
+}
+
+func TestArchiveRepository_Reindex_DropsSupersededPlaceholders(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	store := objectstore.NewMemory()
+	repo := New(store, playlist.NewParser(slog.Default()), slog.Default())
+	hour := "2025/04/11/18"
+	for key, content := range map[string]string{
+		// Footage for the start of a filled gap was backfilled later
+		hour + "/playlist.m3u8": `#EXTM3U
+#EXT-X-VERSION:8
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:00Z
+segment_000.ts
+#EXT-X-GAP
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:10Z
+gap.ts
+#EXT-X-GAP
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:20Z
+gap.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:30Z
+segment_003.ts
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:10Z
+segment_004.ts
+`,
+		hour + "/segment_000.ts": "0",
+		hour + "/segment_003.ts": "3",
+		hour + "/segment_004.ts": "4",
+	} {
+		if err := store.Put(ctx, key, strings.NewReader(content)); err != nil {
+			t.Fatalf("Put(%s) failed: %v", key, err)
+		}
+	}
+
+	// Execute
+	report, err := repo.Reindex(ctx, hour, false)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Reindex failed: %v", err)
+	}
+	if report.Superseded != 1 || len(report.Missing) != 0 {
+		t.Errorf("Superseded, Missing = %d, %v, want 1, none", report.Superseded, report.Missing)
+	}
+	var entries []string
+	for _, segment := range readPlaylist(t, store, hour).Segments {
+		entries = append(entries, fmt.Sprintf("%s %t", segment.Filename, segment.Gap))
+	}
+	if got, want := strings.Join(entries, ", "), "segment_000.ts false, segment_004.ts false, gap.ts true, segment_003.ts false"; got != want {
+		t.Errorf("rebuilt playlist = %s, want %s", got, want)
+	}
+}
+
+func TestArchiveRepository_Verify(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	store := objectstore.NewMemory()
+	repo := New(store, playlist.NewParser(slog.Default()), slog.Default())
+	hour := "2025/04/11/18"
+	video := string(mpegtstest.Stream{Frames: 250, FrameRate: 25, GOP: 50}.Bytes())
+	for key, content := range map[string]string{
+		hour + "/playlist.m3u8": `#EXTM3U
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:00Z
+segment_000.ts
+#EXTINF:6.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:10Z
+segment_001.ts
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:20Z
+segment_002.ts
+`,
+		hour + "/segment_000.ts": video,
+		hour + "/segment_001.ts": video,
+		hour + "/segment_002.ts": "garbage",
+	} {
+		if err := store.Put(ctx, key, strings.NewReader(content)); err != nil {
+			t.Fatalf("Put(%s) failed: %v", key, err)
+		}
+	}
+
+	// Execute
+	report, err := repo.Verify(ctx, hour)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.Segments != 3 || len(report.Problems) != 2 {
+		t.Fatalf("report = %+v, want 2 of 3 segments with problems", report)
+	}
+	if check := report.Problems[0]; check.Filename != "segment_001.ts" || check.Duration != 10 || check.Codec != "h264" {
+		t.Errorf("first problem = %+v, want segment_001.ts lasting 10s", check)
+	}
+	if check := report.Problems[1]; check.Filename != "segment_002.ts" || !strings.HasPrefix(check.Problems[0], "not a transport stream") {
+		t.Errorf("second problem = %+v, want segment_002.ts not being a transport stream", check)
+	}
+}
@@ -0,0 +1,78 @@
+package archiverepo
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"archive/mpegts"
+)
+
+// SegmentCheck describes an archived segment with problems
+type SegmentCheck struct {
+	Filename string    `json:"filename"`
+	Time     time.Time `json:"time"`
+	// Extinf is the segment's duration in the playlist, and Duration the
+	// duration of its video
+	Extinf   float64  `json:"extinf_seconds"`
+	Duration float64  `json:"duration_seconds"`
+	Codec    string   `json:"codec,omitempty"`
+	Width    int      `json:"width,omitempty"`
+	Height   int      `json:"height,omitempty"`
+	Problems []string `json:"problems"`
+}
+
+// VerifyReport says which segments of an hour have problems
+type VerifyReport struct {
+	Hour     string `json:"hour"`
+	Segments int    `json:"segments"`
+	// Placeholders counts the entries standing in for missing footage,
+	// which aren't inspected
+	Placeholders int            `json:"placeholders,omitempty"`
+	Problems     []SegmentCheck `json:"problems,omitempty"`
+}
+
+// Verify inspects every segment of an hour, checking that it is intact
+// video that lasts as long as its playlist says
+func (r *ArchiveRepository) Verify(ctx context.Context, hour string) (VerifyReport, error) {
+	report := VerifyReport{Hour: hour}
+	hourPlaylist, err := r.readHourPlaylist(ctx, hour)
+	if err != nil {
+		return report, err
+	}
+
+	for _, segment := range hourPlaylist.Segments {
+		if IsPlaceholder(segment) {
+			report.Placeholders++
+			continue
+		}
+		report.Segments++
+		check := SegmentCheck{Filename: segment.Filename, Time: segment.DateTime, Extinf: segment.Duration}
+		key := path.Join(hour, segment.Filename)
+		file, err := r.store.Get(ctx, key)
+		if err != nil {
+			check.Problems = []string{fmt.Sprintf("unreadable: %v", err)}
+			report.Problems = append(report.Problems, check)
+			continue
+		}
+		info, err := mpegts.Inspect(file)
+		file.Close()
+		if err != nil {
+			check.Problems = []string{fmt.Sprintf("not a transport stream: %v", err)}
+			report.Problems = append(report.Problems, check)
+			continue
+		}
+
+		check.Duration = info.Duration.Seconds()
+		if video, found := info.VideoStream(); found {
+			check.Codec, check.Width, check.Height = video.Codec, video.Width, video.Height
+		}
+		check.Problems = info.Problems(time.Duration(segment.Duration * float64(time.Second)))
+		if len(check.Problems) > 0 {
+			r.logger.Debug("Segment has problems", "key", key, "problems", check.Problems)
+			report.Problems = append(report.Problems, check)
+		}
+	}
+	return report, nil
+}
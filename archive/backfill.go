@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"archive/app"
+	"archive/logging"
+)
+
+// runBackfill imports an old ffmpeg output directory into the archive.
+// Every playlist in the directory is archived like the live one, so
+// segments that are already in the archive are skipped and the import can
+// be run again after a failure. It prints a JSON report for each playlist
+// and exits with an error status if anything failed.
+func runBackfill(loggers *logging.Loggers, args []string) {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	fromDir := flags.String("from-dir", "", "old ffmpeg output directory to import")
+	pattern := flags.String("playlists", "*.m3u8", "pattern of the playlists to import from the directory")
+	output := flags.String("output", os.Getenv("OUTPUT_DIR"), "archive directory or object store location")
+	flags.Parse(args)
+
+	if *fromDir == "" {
+		log.Fatalln("Error: -from-dir is required")
+	}
+	playlists, err := filepath.Glob(filepath.Join(*fromDir, *pattern))
+	if err != nil {
+		log.Fatalf("Error: invalid -playlists pattern: %v\n", err)
+	}
+	if len(playlists) == 0 {
+		log.Fatalf("Error: no playlists match %s in %s\n", *pattern, *fromDir)
+	}
+	sort.Strings(playlists)
+
+	repos := newRepositories(loggers)
+	archiveRepo := repos.archive(*output)
+	// The old directory is no longer being written, and copying it may
+	// not have kept the times its segments were written at
+	streamRepo := repos.stream(*fromDir).Settled()
+	logger := loggers.For("main")
+
+	results := make(map[string]app.ArchiveResult, len(playlists))
+	failed := false
+	for _, path := range playlists {
+		name := filepath.Base(path)
+		archiveApp := app.NewArchiveApp(streamRepo.WithPlaylist(name), archiveRepo, appOptions(loggers)...)
+		result := archiveApp.Archive()
+		results[name] = result
+		if result.Error != nil {
+			failed = true
+			logger.Error("Failed to backfill playlist", "playlist", name, "error", result.Error)
+			continue
+		}
+		logger.Info("Backfilled playlist", "playlist", name, "archived_segments", result.ArchivedSegments,
+			"skipped_segments", result.Count(app.SegmentSkipped))
+	}
+
+	printJSON(results)
+	if failed {
+		os.Exit(1)
+	}
+}
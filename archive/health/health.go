@@ -0,0 +1,50 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// checkTimeout bounds how long readiness checks may take together
+const checkTimeout = 5 * time.Second
+
+// Check is a named readiness check
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Live responds OK for as long as the process is serving requests
+func Live() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// Ready responds OK when every check passes, and with 503 and the failed
+// checks otherwise
+func Ready(checks ...Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		defer cancel()
+
+		var failures []string
+		for _, check := range checks {
+			if err := check.Check(ctx); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", check.Name, err))
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if len(failures) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, strings.Join(failures, "\n"))
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
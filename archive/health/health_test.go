@@ -0,0 +1,30 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReady(t *testing.T) {
+	passing := Check{Name: "store", Check: func(ctx context.Context) error { return nil }}
+	failing := Check{Name: "stream", Check: func(ctx context.Context) error { return errors.New("playlist is stale") }}
+
+	recorder := httptest.NewRecorder()
+	Ready(passing).ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+
+	recorder = httptest.NewRecorder()
+	Ready(passing, failing).ServeHTTP(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Status = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+	if body := recorder.Body.String(); !strings.Contains(body, "stream: playlist is stale") || strings.Contains(body, "store") {
+		t.Errorf("Body = %q, want only the failed check", body)
+	}
+}
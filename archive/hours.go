@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"archive/app"
+	"archive/archiverepo"
+	"archive/thumbnail"
+)
+
+// hourWorker finishes the hours the daemon archives, in the background,
+// once archiving has moved on from them: it indexes their keyframes and
+// builds their thumbnails. Hours that get more footage later, from a
+// backfill or late segments, are finished again.
+type hourWorker struct {
+	archiveRepo *archiverepo.ArchiveRepository
+	// thumbnails is nil if thumbnails are off
+	thumbnails *thumbnail.Generator
+	// pending are the archived hours waiting for the hour to be over
+	pending map[string]bool
+	queue   chan string
+	logger  *slog.Logger
+}
+
+// newHourWorker starts finishing hours in the background
+func newHourWorker(archiveRepo *archiverepo.ArchiveRepository, thumbnails *thumbnail.Generator, logger *slog.Logger) *hourWorker {
+	w := &hourWorker{
+		archiveRepo: archiveRepo,
+		thumbnails:  thumbnails,
+		pending:     make(map[string]bool),
+		queue:       make(chan string, 24),
+		logger:      logger,
+	}
+	go w.run()
+	return w
+}
+
+// archived notes the hours an archive run added footage to, and queues the
+// ones that are over. An hour is over once a whole archive interval has
+// passed since it ended, so segments still being copied have landed.
+func (w *hourWorker) archived(result app.ArchiveResult) {
+	for _, hour := range result.Hours() {
+		w.pending[hour] = true
+	}
+
+	// Hour paths sort in time order
+	current := archiverepo.HourPath(time.Now().Add(-archiveInterval))
+	var over []string
+	for hour := range w.pending {
+		if hour < current {
+			over = append(over, hour)
+		}
+	}
+	sort.Strings(over)
+	for _, hour := range over {
+		select {
+		case w.queue <- hour:
+			delete(w.pending, hour)
+		default:
+			// Busy: try again after the next run
+			return
+		}
+	}
+}
+
+// run finishes queued hours one at a time
+func (w *hourWorker) run() {
+	ctx := context.Background()
+	for hour := range w.queue {
+		if _, err := w.archiveRepo.IndexIFrames(ctx, hour); err != nil {
+			w.logger.Error("Failed to index keyframes", "hour", hour, "error", err)
+		}
+		if w.thumbnails == nil {
+			continue
+		}
+		if _, err := w.thumbnails.Generate(ctx, hour); err != nil {
+			w.logger.Error("Failed to build thumbnails", "hour", hour, "error", err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"archive/archiverepo"
+	"archive/logging"
+)
+
+// runIFrames writes the I-frame and master playlists of every archived hour,
+// or just one, and prints a JSON report. It exits with an error status if
+// any hour or segment couldn't be indexed.
+func runIFrames(loggers *logging.Loggers, args []string) {
+	flags := flag.NewFlagSet("iframes", flag.ExitOnError)
+	output := flags.String("output", os.Getenv("OUTPUT_DIR"), "archive directory or object store location")
+	hour := flags.String("hour", "", "only index this hour directory, such as 2025/04/11/18")
+	flags.Parse(args)
+
+	ctx := context.Background()
+	archiveRepo := newRepositories(loggers).archive(*output)
+	hours := []string{*hour}
+	if *hour == "" {
+		var err error
+		hours, err = archiveRepo.Hours(ctx)
+		if err != nil {
+			log.Fatalf("Error: Unable to list archived hours: %v\n", err)
+		}
+	}
+
+	logger := loggers.For("main")
+	reports := []archiverepo.IFramesReport{}
+	failed := false
+	for _, hour := range hours {
+		report, err := archiveRepo.IndexIFrames(ctx, hour)
+		if err != nil {
+			failed = true
+			logger.Error("Failed to index keyframes", "hour", hour, "error", err)
+			continue
+		}
+		if len(report.Skipped) > 0 {
+			failed = true
+		}
+		reports = append(reports, report)
+	}
+
+	printJSON(reports)
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"archive/app"
+	"archive/importer"
+	"archive/logging"
+)
+
+// runImport archives recordings from phones and other cameras next to the
+// live footage. It prints a JSON report for each recording and exits with
+// an error status if anything failed.
+func runImport(loggers *logging.Loggers, args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	startFlag := flags.String("start", "", "when the recording started (RFC3339, or local time such as 2006-01-02T15:04:05); read from the recording's metadata if not set")
+	segmentDuration := flags.Duration("segment", 10*time.Second, "shortest segment to cut at a keyframe")
+	output := flags.String("output", os.Getenv("OUTPUT_DIR"), "archive directory or object store location")
+	ffmpegPath := flags.String("ffmpeg", "ffmpeg", "ffmpeg binary used to remux recordings that aren't transport streams")
+	ffprobePath := flags.String("ffprobe", "ffprobe", "ffprobe binary used to read start times")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: archive import [flags] FILE...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	var start time.Time
+	if *startFlag != "" {
+		if flags.NArg() > 1 {
+			log.Fatalln("Error: -start only applies to a single recording")
+		}
+		var err error
+		if start, err = parseTime(*startFlag); err != nil {
+			log.Fatalf("Error: invalid -start: %v\n", err)
+		}
+	}
+
+	ctx := context.Background()
+	archiveRepo := newRepositories(loggers).archive(*output)
+	imp := importer.New(*ffmpegPath, *ffprobePath, *segmentDuration, loggers.For("importer"))
+	logger := loggers.For("main")
+
+	results := make(map[string]app.ArchiveResult, flags.NArg())
+	failed := false
+	for _, path := range flags.Args() {
+		recording, err := imp.Prepare(ctx, path, start)
+		if err != nil {
+			failed = true
+			logger.Error("Failed to import recording", "path", path, "error", err)
+			continue
+		}
+		result := app.NewArchiveApp(recording, archiveRepo, appOptions(loggers)...).Archive()
+		if err := recording.Close(); err != nil {
+			logger.Warn("Failed to remove split recording", "path", path, "error", err)
+		}
+		results[path] = result
+
+		// Recordings land in hours that may hold live footage already, so
+		// put their playlists back in time order
+		for _, hour := range result.Hours() {
+			if _, err := archiveRepo.Reindex(ctx, hour, false); err != nil {
+				failed = true
+				logger.Error("Failed to reindex hour", "hour", hour, "error", err)
+			}
+		}
+		if result.Error != nil {
+			failed = true
+			logger.Error("Failed to import recording", "path", path, "error", result.Error)
+			continue
+		}
+		logger.Info("Imported recording", "path", path, "archived_segments", result.ArchivedSegments, "hours", result.Hours())
+	}
+
+	printJSON(results)
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// parseTime parses an RFC3339 time, or a local time without an offset
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04:05", value, time.Local)
+}
@@ -0,0 +1,201 @@
+// Package importer splits recordings from phones and other cameras into
+// segments the archive app can archive like the live stream
+package importer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"archive/mpegts"
+	"archive/playlist"
+)
+
+// ErrNoStartTime is returned when a recording's metadata has no start time
+var ErrNoStartTime = errors.New("no start time in the recording's metadata")
+
+// Importer splits recordings into segments
+type Importer struct {
+	ffmpegPath      string
+	ffprobePath     string
+	segmentDuration time.Duration
+	logger          *slog.Logger
+}
+
+// New returns an importer that cuts segments of at least segmentDuration,
+// using ffmpeg to remux recordings that aren't transport streams and
+// ffprobe to read their start times
+func New(ffmpegPath, ffprobePath string, segmentDuration time.Duration, logger *slog.Logger) *Importer {
+	return &Importer{
+		ffmpegPath:      ffmpegPath,
+		ffprobePath:     ffprobePath,
+		segmentDuration: segmentDuration,
+		logger:          logger,
+	}
+}
+
+// Prepare splits the recording at path into segments at keyframes, in a
+// temporary directory that Close removes. Transport streams are split as
+// they are; anything else, such as MP4, is remuxed to one with ffmpeg
+// first. A zero start reads the start time from the recording's metadata.
+func (i *Importer) Prepare(ctx context.Context, path string, start time.Time) (*Recording, error) {
+	if start.IsZero() {
+		var err error
+		if start, err = i.StartTime(ctx, path); err != nil {
+			return nil, err
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "archive-import-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	recording := &Recording{dir: dir}
+	if err := i.split(ctx, recording, path, start); err != nil {
+		recording.Close()
+		return nil, err
+	}
+	return recording, nil
+}
+
+// split fills the recording with the segments of the file at path
+func (i *Importer) split(ctx context.Context, recording *Recording, path string, start time.Time) error {
+	transportStream, err := isTransportStream(path)
+	if err != nil {
+		return err
+	}
+	if !transportStream {
+		remuxed := filepath.Join(recording.dir, "remuxed.ts")
+		i.logger.Info("Remuxing recording to a transport stream", "path", path)
+		if err := i.remux(ctx, path, remuxed); err != nil {
+			return err
+		}
+		defer os.Remove(remuxed)
+		path = remuxed
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	segments, err := mpegts.Split(file, i.segmentDuration, func(index int) (io.WriteCloser, error) {
+		return os.Create(filepath.Join(recording.dir, segmentName(index)))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to split %s: %w", path, err)
+	}
+
+	recording.playlist = &playlist.Playlist{Version: 3, Segments: make([]playlist.Segment, 0, len(segments))}
+	for index, segment := range segments {
+		dateTime := start.Add(segment.Start)
+		recording.playlist.Segments = append(recording.playlist.Segments, playlist.Segment{
+			Filename:        segmentName(index),
+			Duration:        segment.Duration.Seconds(),
+			DateTime:        dateTime,
+			ProgramDateTime: dateTime.UTC().Format(playlist.DateTimeLayout),
+		})
+		recording.playlist.TargetDuration = max(recording.playlist.TargetDuration, int(math.Ceil(segment.Duration.Seconds())))
+	}
+	i.logger.Info("Split recording", "path", path, "segments", len(segments), "start", start)
+	return nil
+}
+
+// remux copies the video and audio of a recording into a transport stream
+func (i *Importer) remux(ctx context.Context, path, output string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, i.ffmpegPath,
+		"-hide_banner", "-loglevel", "error", "-nostdin",
+		"-i", path,
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-c", "copy",
+		"-f", "mpegts", output)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to remux %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// StartTime reads when a recording started from its creation_time
+// metadata with ffprobe
+func (i *Importer) StartTime(ctx context.Context, path string) (time.Time, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, i.ffprobePath,
+		"-v", "error",
+		"-show_entries", "format_tags=creation_time",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return time.Time{}, fmt.Errorf("failed to probe %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	value := strings.TrimSpace(stdout.String())
+	if value == "" {
+		return time.Time{}, fmt.Errorf("%w: %s", ErrNoStartTime, path)
+	}
+	start, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid creation time %q in %s: %w", value, path, err)
+	}
+	return start, nil
+}
+
+// isTransportStream reports whether the file at path starts with two
+// transport stream packets
+func isTransportStream(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	head := make([]byte, mpegts.PacketSize+1)
+	if _, err := io.ReadFull(file, head); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	return head[0] == 0x47 && head[mpegts.PacketSize] == 0x47, nil
+}
+
+// segmentName returns the name of a segment in a recording
+func segmentName(index int) string {
+	return fmt.Sprintf("segment_%05d.ts", index)
+}
+
+// Recording is a recording split into segments. It reads like the live
+// stream, so the archive app can archive it.
+type Recording struct {
+	dir      string
+	playlist *playlist.Playlist
+}
+
+// GetPlaylist returns the playlist of the recording's segments
+func (r *Recording) GetPlaylist() (*playlist.Playlist, error) {
+	return r.playlist, nil
+}
+
+// GetSegment opens a segment of the recording
+func (r *Recording) GetSegment(filename string) (io.ReadCloser, error) {
+	if filename != filepath.Base(filename) {
+		return nil, fmt.Errorf("invalid segment name %q", filename)
+	}
+	return os.Open(filepath.Join(r.dir, filename))
+}
+
+// Close removes the recording's segments
+func (r *Recording) Close() error {
+	return os.RemoveAll(r.dir)
+}
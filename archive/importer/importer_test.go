@@ -0,0 +1,130 @@
+package importer_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"archive/app"
+	"archive/archiverepo"
+	"archive/importer"
+	"archive/mpegts/mpegtstest"
+	"archive/objectstore"
+	"archive/playlist"
+)
+
+// writeScript writes an executable shell script standing in for ffmpeg or
+// ffprobe
+func writeScript(t *testing.T, name, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// writeRecording writes 25s of video with keyframes every 2s, which splits
+// into segments of 10s, 10s and 5s
+func writeRecording(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	stream := mpegtstest.Stream{Frames: 625, FrameRate: 25, GOP: 50, Audio: true}
+	if err := os.WriteFile(path, stream.Bytes(), 0o644); err != nil {
+		t.Fatalf("Failed to write recording: %v", err)
+	}
+	return path
+}
+
+func TestImporter_Prepare_TransportStream(t *testing.T) {
+	// Setup
+	path := writeRecording(t, "match.ts")
+	start := time.Date(2025, 3, 1, 18, 59, 50, 0, time.UTC)
+	imp := importer.New("/nonexistent/ffmpeg", "/nonexistent/ffprobe", 10*time.Second, slog.Default())
+
+	// Execute
+	recording, err := imp.Prepare(context.Background(), path, start)
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer recording.Close()
+	recorded, _ := recording.GetPlaylist()
+
+	// Assert
+	wantTimes := []string{"2025-03-01T18:59:50.000Z", "2025-03-01T19:00:00.000Z", "2025-03-01T19:00:10.000Z"}
+	wantDurations := []float64{10, 10, 5}
+	if len(recorded.Segments) != len(wantTimes) {
+		t.Fatalf("got %d segments, want %d", len(recorded.Segments), len(wantTimes))
+	}
+	for i, segment := range recorded.Segments {
+		if segment.ProgramDateTime != wantTimes[i] || segment.Duration != wantDurations[i] {
+			t.Errorf("segment %d = %s for %vs, want %s for %vs", i, segment.ProgramDateTime, segment.Duration, wantTimes[i], wantDurations[i])
+		}
+	}
+	if recorded.TargetDuration != 10 {
+		t.Errorf("TargetDuration = %d, want 10", recorded.TargetDuration)
+	}
+}
+
+func TestImporter_Prepare_RemuxesWithFFmpeg(t *testing.T) {
+	// Setup: the stand-ins copy the input and report a creation time
+	path := writeRecording(t, "phone.mp4")
+	ffmpeg := writeScript(t, "ffmpeg", `while [ "$1" != "-i" ]; do shift; done; in="$2"; for out; do :; done; cp "$in" "$out"`)
+	ffprobe := writeScript(t, "ffprobe", `echo 2025-03-01T18:00:00.000000Z`)
+	imp := importer.New(ffmpeg, ffprobe, 10*time.Second, slog.Default())
+
+	// Execute
+	recording, err := imp.Prepare(context.Background(), path, time.Time{})
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer recording.Close()
+	recorded, _ := recording.GetPlaylist()
+
+	// Assert
+	if len(recorded.Segments) != 3 || !recorded.Segments[0].DateTime.Equal(time.Date(2025, 3, 1, 18, 0, 0, 0, time.UTC)) {
+		t.Errorf("segments = %+v, want 3 starting at the creation time", recorded.Segments)
+	}
+}
+
+func TestImporter_StartTime_Missing(t *testing.T) {
+	ffprobe := writeScript(t, "ffprobe", `exit 0`)
+	imp := importer.New("ffmpeg", ffprobe, 10*time.Second, slog.Default())
+
+	_, err := imp.StartTime(context.Background(), "match.mp4")
+	if !errors.Is(err, importer.ErrNoStartTime) {
+		t.Errorf("StartTime error = %v, want %v", err, importer.ErrNoStartTime)
+	}
+}
+
+func TestRecording_ArchivesIntoHours(t *testing.T) {
+	// Setup
+	path := writeRecording(t, "match.ts")
+	start := time.Date(2025, 3, 1, 18, 59, 50, 0, time.UTC)
+	recording, err := importer.New("ffmpeg", "ffprobe", 10*time.Second, slog.Default()).Prepare(context.Background(), path, start)
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	defer recording.Close()
+	store := objectstore.NewMemory()
+	archiveRepo := archiverepo.New(store, playlist.NewParser(slog.Default()), slog.Default())
+
+	// Execute
+	result := app.NewArchiveApp(recording, archiveRepo).Archive()
+
+	// Assert
+	if result.Error != nil || result.ArchivedSegments != 3 {
+		t.Fatalf("Archive = %d segments, %v, want 3 segments", result.ArchivedSegments, result.Error)
+	}
+	hours := result.Hours()
+	if len(hours) != 2 || hours[0] != "2025/03/01/18" || hours[1] != "2025/03/01/19" {
+		t.Errorf("Hours = %v, want 18:00 and 19:00", hours)
+	}
+	if _, err := store.Stat(context.Background(), "2025/03/01/19/segment_001.ts"); err != nil {
+		t.Errorf("Stat of the last segment failed: %v", err)
+	}
+}
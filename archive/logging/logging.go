@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Config says how to format logs and which levels to log at
+type Config struct {
+	// Format is json or text
+	Format string
+	// Level is the level for components without their own
+	Level slog.Level
+	// Levels are the levels of individual components
+	Levels map[string]slog.Level
+}
+
+// ParseConfig parses the format, the default level such as info, and
+// per-component levels such as playlist=debug,app=warn. Empty values keep
+// the defaults of JSON logs at info level.
+func ParseConfig(format, level, levels string) (Config, error) {
+	config := Config{Format: "json", Level: slog.LevelInfo, Levels: make(map[string]slog.Level)}
+
+	switch format {
+	case "":
+	case "json", "text":
+		config.Format = format
+	default:
+		return Config{}, fmt.Errorf("unknown log format %q: use json or text", format)
+	}
+
+	if level != "" {
+		if err := config.Level.UnmarshalText([]byte(level)); err != nil {
+			return Config{}, fmt.Errorf("invalid log level %q: %w", level, err)
+		}
+	}
+
+	for _, field := range strings.Split(levels, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		component, value, found := strings.Cut(field, "=")
+		if !found || component == "" {
+			return Config{}, fmt.Errorf("invalid component log level %q: use component=level", field)
+		}
+		var componentLevel slog.Level
+		if err := componentLevel.UnmarshalText([]byte(value)); err != nil {
+			return Config{}, fmt.Errorf("invalid log level for %s: %w", component, err)
+		}
+		config.Levels[component] = componentLevel
+	}
+	return config, nil
+}
+
+// Loggers hands out a logger for each component of a program, all writing
+// to the same output
+type Loggers struct {
+	w      io.Writer
+	config Config
+}
+
+// New returns loggers that write to w
+func New(w io.Writer, config Config) *Loggers {
+	return &Loggers{w: w, config: config}
+}
+
+// For returns the logger of a component, which tags every record with the
+// component's name and logs at the component's level
+func (l *Loggers) For(component string) *slog.Logger {
+	level, found := l.config.Levels[component]
+	if !found {
+		level = l.config.Level
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	if l.config.Format == "text" {
+		handler = slog.NewTextHandler(l.w, options)
+	} else {
+		handler = slog.NewJSONHandler(l.w, options)
+	}
+	return slog.New(handler).With("component", component)
+}
@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig("", "warn", "playlist=debug, app=error")
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	if config.Format != "json" || config.Level != slog.LevelWarn {
+		t.Errorf("Format, Level = %s, %v, want json, WARN", config.Format, config.Level)
+	}
+	if config.Levels["playlist"] != slog.LevelDebug || config.Levels["app"] != slog.LevelError {
+		t.Errorf("Levels = %v, want playlist at DEBUG and app at ERROR", config.Levels)
+	}
+
+	for _, bad := range [][3]string{{"xml", "", ""}, {"", "loud", ""}, {"", "", "playlist"}, {"", "", "app=loud"}} {
+		if _, err := ParseConfig(bad[0], bad[1], bad[2]); err == nil {
+			t.Errorf("ParseConfig(%q, %q, %q) error = nil, want an error", bad[0], bad[1], bad[2])
+		}
+	}
+}
+
+func TestLoggers_For(t *testing.T) {
+	config, err := ParseConfig("json", "info", "playlist=debug")
+	if err != nil {
+		t.Fatalf("ParseConfig() error = %v", err)
+	}
+	var out bytes.Buffer
+	loggers := New(&out, config)
+
+	loggers.For("app").Debug("hidden")
+	loggers.For("playlist").Debug("parsed playlist", "segments", 3)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Logged %d lines, want 1: %q", len(lines), out.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Failed to decode %q: %v", lines[0], err)
+	}
+	if record["component"] != "playlist" || record["msg"] != "parsed playlist" || record["segments"] != 3.0 {
+		t.Errorf("Record = %v, want the playlist debug record", record)
+	}
+}
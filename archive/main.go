@@ -1,50 +1,304 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"archive/app"
 	"archive/archiverepo"
+	"archive/health"
+	"archive/logging"
+	"archive/metrics"
+	"archive/notify"
+	"archive/objectstore"
+	"archive/playlist"
 	"archive/streamrepo"
 )
 
+// archiveInterval is how often the archive copies new segments
+const archiveInterval = time.Minute
+
 func main() {
-	inputDir, found := os.LookupEnv("INPUT_DIR")
-	if !found {
-		log.Fatalln("Error: INPUT_DIR environment variable is not set")
+	loggers := newLoggers()
+	// Route the standard logger, used for fatal configuration errors,
+	// through the same output as errors
+	slog.SetDefault(loggers.For("main"))
+	slog.SetLogLoggerLevel(slog.LevelError)
+
+	// Without a command the archive runs as a daemon, as it always has
+	command, args := "run", os.Args[1:]
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command, args = os.Args[1], os.Args[2:]
 	}
-	streamRepo := streamrepo.New(inputDir)
+	if once, rest := onceFlag(args); command == "run" && once {
+		loggers.For("main").Warn("The -once flag is deprecated, use the once command")
+		command, args = "once", rest
+	}
+	switch command {
+	case "run":
+		runDaemon(loggers, args)
+	case "once":
+		runOnce(loggers, args)
+	case "backfill":
+		runBackfill(loggers, args)
+	case "reindex":
+		runReindex(loggers, args)
+	case "import":
+		runImport(loggers, args)
+	case "verify":
+		runVerify(loggers, args)
+	case "thumbnails":
+		runThumbnails(loggers, args)
+	case "iframes":
+		runIFrames(loggers, args)
+	default:
+		log.Fatalf("Error: unknown command %q: use run, once, backfill, reindex, import, verify, thumbnails or iframes\n", command)
+	}
+}
 
-	outputDir, found := os.LookupEnv("OUTPUT_DIR")
-	if !found {
+// onceFlag reports whether args set the -once flag, which the once command
+// replaced, and returns them without it
+func onceFlag(args []string) (bool, []string) {
+	once := false
+	var rest []string
+	for _, arg := range args {
+		switch arg {
+		case "-once", "--once", "-once=true", "--once=true":
+			once = true
+		case "-once=false", "--once=false":
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return once, rest
+}
+
+// repositories builds the repositories of the archive, which log through
+// loggers
+type repositories struct {
+	loggers *logging.Loggers
+	parser  *playlist.Parser
+}
+
+func newRepositories(loggers *logging.Loggers) repositories {
+	return repositories{loggers: loggers, parser: playlist.NewParser(loggers.For("playlist"))}
+}
+
+// stream returns the repository of the ffmpeg output in a directory
+func (r repositories) stream(dir string) *streamrepo.StreamRepository {
+	return streamrepo.New(dir, r.parser, r.loggers.For("streamrepo"))
+}
+
+// archive opens the archive at a directory, or any other object store
+// location such as s3://bucket/prefix?endpoint=http://minio:9000
+func (r repositories) archive(location string) *archiverepo.ArchiveRepository {
+	return archiverepo.New(r.store(location), r.parser, r.loggers.For("archiverepo"))
+}
+
+// store opens the object store the archive is kept in
+func (r repositories) store(location string) objectstore.Store {
+	if location == "" {
 		log.Fatalln("Error: OUTPUT_DIR environment variable is not set")
 	}
-	archiveRepo := archiverepo.New(outputDir)
+	store, err := objectstore.Open(location)
+	if err != nil {
+		log.Fatalf("Error: Unable to open the archive at %s: %v\n", location, err)
+	}
+	return store
+}
+
+// runDaemon archives INPUT_DIR into OUTPUT_DIR every minute, serving health
+// and metrics on PORT, which is only reachable from the compose network
+func runDaemon(loggers *logging.Loggers, args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	input := flags.String("input", os.Getenv("INPUT_DIR"), "ffmpeg output directory")
+	output := flags.String("output", os.Getenv("OUTPUT_DIR"), "archive directory or object store location")
+	flags.Parse(args)
+
+	if *input == "" {
+		log.Fatalln("Error: INPUT_DIR environment variable is not set")
+	}
+	repos := newRepositories(loggers)
+	archiveRepo := repos.archive(*output)
+	archiveApp := app.NewArchiveApp(repos.stream(*input), archiveRepo,
+		appOptions(loggers, stallDetection(loggers.For("notify")))...)
+	hours := newHourWorker(archiveRepo, thumbnailGenerator(repos, *output), loggers.For("main"))
 
-	archiveApp := app.NewArchiveApp(streamRepo, archiveRepo)
+	logger := loggers.For("main")
+	registry := metrics.NewRegistry()
+	status := newArchiveStatus(registry, archiveInterval)
+	go serveStatus(registry, status, logger)
 
 	// Create a ticker that runs every minute
-	ticker := time.NewTicker(1 * time.Minute)
+	ticker := time.NewTicker(archiveInterval)
 	defer ticker.Stop()
 
-	// Run immediately on startup
-	doArchive(archiveApp)
+	// Run immediately on startup, then every minute
+	for {
+		result := doArchive(archiveApp, status, logger)
+		hours.archived(result)
+		<-ticker.C
+	}
+}
+
+// runOnce archives once, for cron jobs, and prints a JSON report of every
+// segment. It exits with an error status if anything failed. Each run starts
+// without the previous run's continuity, so only run notices recorder
+// restarts by a reset media sequence or a jump in timestamps.
+func runOnce(loggers *logging.Loggers, args []string) {
+	flags := flag.NewFlagSet("once", flag.ExitOnError)
+	input := flags.String("input", os.Getenv("INPUT_DIR"), "ffmpeg output directory")
+	output := flags.String("output", os.Getenv("OUTPUT_DIR"), "archive directory or object store location")
+	flags.Parse(args)
+
+	if *input == "" {
+		log.Fatalln("Error: INPUT_DIR environment variable is not set")
+	}
+	repos := newRepositories(loggers)
+	archiveRepo := repos.archive(*output)
+	result := app.NewArchiveApp(repos.stream(*input), archiveRepo, appOptions(loggers)...).Archive()
+
+	printJSON(result)
+	if result.Error != nil {
+		os.Exit(1)
+	}
+}
+
+// printJSON prints a report as indented JSON
+func printJSON(report any) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("Error: Unable to write report: %v\n", err)
+	}
+}
+
+// newLoggers configures logging from LOG_FORMAT, json or text, LOG_LEVEL,
+// the level for every component, and LOG_LEVELS, levels for individual
+// components such as playlist=debug,app=warn. The components are main,
+// app, streamrepo, archiverepo, playlist, notify, importer and thumbnail.
+func newLoggers() *logging.Loggers {
+	config, err := logging.ParseConfig(os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"), os.Getenv("LOG_LEVELS"))
+	if err != nil {
+		log.Fatalf("Error: Unable to configure logging: %v\n", err)
+	}
+	return logging.New(os.Stderr, config)
+}
+
+// appOptions configures the archive app for every command. INSPECT_SEGMENTS
+// sets what happens to segments that don't inspect cleanly: flag, the
+// default, archives them and reports their problems, reject refuses the ones
+// that aren't playable video, and off skips inspection. GAP_FILL sets how
+// time without footage is covered: gap with EXT-X-GAP entries, slate with
+// the transport stream at GAP_SLATE, or off, the default.
+func appOptions(loggers *logging.Loggers, options ...app.Option) []app.Option {
+	options = append(options, app.WithLogger(loggers.For("app")))
+	switch inspect := os.Getenv("INSPECT_SEGMENTS"); inspect {
+	case "", "flag":
+		options = append(options, app.WithSegmentInspection(false))
+	case "reject":
+		options = append(options, app.WithSegmentInspection(true))
+	case "off":
+	default:
+		log.Fatalf("Error: invalid INSPECT_SEGMENTS %q: use flag, reject or off\n", inspect)
+	}
+	switch fill := os.Getenv("GAP_FILL"); fill {
+	case "gap":
+		options = append(options, app.WithGapFilling(nil))
+	case "slate":
+		options = append(options, app.WithGapFilling(loadSlate(os.Getenv("GAP_SLATE"))))
+	case "", "off":
+	default:
+		log.Fatalf("Error: invalid GAP_FILL %q: use gap, slate or off\n", fill)
+	}
+	return options
+}
+
+// loadSlate reads the slate shown in place of missing footage
+func loadSlate(path string) *app.Slate {
+	if path == "" {
+		log.Fatalln("Error: GAP_SLATE environment variable is not set")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Error: Unable to read slate: %v\n", err)
+	}
+	slate, err := app.NewSlate(content)
+	if err != nil {
+		log.Fatalf("Error: Unable to use %s as a slate: %v\n", path, err)
+	}
+	return slate
+}
+
+// stallDetection configures alerts for a stalled recorder. STALL_THRESHOLD
+// is how old the newest segment may get, and STALL_RECOVERY how long it
+// must stay fresh before the recorder counts as recovered. Events are
+// always logged, and also posted to STALL_WEBHOOK_URL and passed to the
+// STALL_EXEC shell command when they are set.
+func stallDetection(logger *slog.Logger) app.Option {
+	threshold := durationEnv("STALL_THRESHOLD", 2*time.Minute)
+	recovery := durationEnv("STALL_RECOVERY", 2*time.Minute)
+
+	notifiers := notify.Multi{notify.Log{Logger: logger}}
+	if url, found := os.LookupEnv("STALL_WEBHOOK_URL"); found && url != "" {
+		notifiers = append(notifiers, notify.Webhook{URL: url})
+	}
+	if command, found := os.LookupEnv("STALL_EXEC"); found && command != "" {
+		notifiers = append(notifiers, notify.Exec{Command: command})
+	}
+	return app.WithStallDetection(threshold, recovery, notifiers)
+}
+
+// durationEnv parses a duration such as 90s from an environment variable
+func durationEnv(name string, fallback time.Duration) time.Duration {
+	value, found := os.LookupEnv(name)
+	if !found || value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Fatalf("Error: invalid %s %q: use a duration such as 2m\n", name, value)
+	}
+	return duration
+}
+
+// serveStatus serves /healthz, /readyz and /metrics
+func serveStatus(registry *metrics.Registry, status *archiveStatus, logger *slog.Logger) {
+	port, found := os.LookupEnv("PORT")
+	if !found {
+		port = "6002"
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /healthz", health.Live())
+	mux.Handle("GET /readyz", health.Ready(health.Check{Name: "archive", Check: status.ready}))
+	mux.Handle("GET /metrics", registry)
 
-	// Then run every minute
-	for range ticker.C {
-		doArchive(archiveApp)
+	serverAddr := fmt.Sprintf(":%s", port)
+	logger.Info("Serving health and metrics", "addr", serverAddr)
+	if err := http.ListenAndServe(serverAddr, mux); err != nil {
+		log.Fatalf("Error: Unable to serve health and metrics: %+v\n", err)
 	}
 }
 
-func doArchive(archiveApp *app.ArchiveApp) {
-	fmt.Println("Starting archive...")
+func doArchive(archiveApp *app.ArchiveApp, status *archiveStatus, logger *slog.Logger) app.ArchiveResult {
+	logger.Debug("Starting archive")
 	result := archiveApp.Archive()
+	status.record(result, result.Started, result.Elapsed)
 	if result.Error != nil {
-		log.Printf("Archive failed: %v\n", result.Error)
-		return
+		logger.Error("Archive failed", "error", result.Error, "archived_segments", result.ArchivedSegments,
+			"failed_segments", result.Count(app.SegmentFailed), "failures", result.Failures)
+		return result
 	}
-	log.Printf("Successfully archived %d segments\n", result.ArchivedSegments)
+	logger.Info("Archived segments", "archived_segments", result.ArchivedSegments,
+		"bytes", result.BytesWritten, "hours", result.Hours(), "lag_seconds", result.Lag().Seconds(),
+		"duration_seconds", result.Elapsed.Seconds())
+	return result
 }
@@ -0,0 +1,348 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram buckets in seconds suited to request and
+// tick durations
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Registry holds metrics and serves them in the Prometheus text format
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// metric is a family of samples with a name and help text
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// register adds a metric, panicking on duplicate names like the Prometheus
+// client does, since that is a programming error
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.metrics {
+		if existing.name() == m.name() {
+			panic(fmt.Sprintf("metrics: %s registered twice", m.name()))
+		}
+	}
+	r.metrics = append(r.metrics, m)
+}
+
+// ServeHTTP writes every metric, sorted by name
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// family holds the samples of a metric by their label values
+type family[T any] struct {
+	metricName string
+	help       string
+	kind       string
+	labelNames []string
+	newSample  func() *T
+	mu         sync.Mutex
+	samples    map[string]*T
+	labels     map[string][]string
+}
+
+func newFamily[T any](name, help, kind string, labelNames []string, newSample func() *T) *family[T] {
+	return &family[T]{
+		metricName: name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		newSample:  newSample,
+		samples:    make(map[string]*T),
+		labels:     make(map[string][]string),
+	}
+}
+
+func (f *family[T]) name() string {
+	return f.metricName
+}
+
+// with returns the sample for label values, creating it on first use
+func (f *family[T]) with(values []string) *T {
+	if len(values) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: %s takes %d labels, got %d", f.metricName, len(f.labelNames), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sample, found := f.samples[key]
+	if !found {
+		sample = f.newSample()
+		f.samples[key] = sample
+		f.labels[key] = append([]string(nil), values...)
+	}
+	return sample
+}
+
+// each calls write with the samples sorted by their label values
+func (f *family[T]) each(w io.Writer, write func(labels string, sample *T)) {
+	f.mu.Lock()
+	keys := make([]string, 0, len(f.samples))
+	for key := range f.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	samples := make([]*T, len(keys))
+	labels := make([]string, len(keys))
+	for i, key := range keys {
+		samples[i] = f.samples[key]
+		labels[i] = formatLabels(f.labelNames, f.labels[key])
+	}
+	f.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", f.metricName, f.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.metricName, f.kind)
+	for i := range samples {
+		write(labels[i], samples[i])
+	}
+}
+
+// value is a float64 that can be changed concurrently
+type value struct {
+	mu sync.Mutex
+	v  float64
+}
+
+func (v *value) add(delta float64) {
+	v.mu.Lock()
+	v.v += delta
+	v.mu.Unlock()
+}
+
+func (v *value) set(x float64) {
+	v.mu.Lock()
+	v.v = x
+	v.mu.Unlock()
+}
+
+func (v *value) get() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.v
+}
+
+// Counter is a value that only goes up
+type Counter struct {
+	value
+}
+
+// Inc adds one
+func (c *Counter) Inc() {
+	c.add(1)
+}
+
+// Add adds a non-negative amount
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("metrics: counters can't go down")
+	}
+	c.add(delta)
+}
+
+// CounterVec is a counter for each combination of label values
+type CounterVec struct {
+	*family[Counter]
+}
+
+// Counter registers a counter with the given labels
+func (r *Registry) Counter(name, help string, labelNames ...string) *CounterVec {
+	vec := &CounterVec{newFamily(name, help, "counter", labelNames, func() *Counter { return &Counter{} })}
+	r.register(vec)
+	return vec
+}
+
+// With returns the counter for label values
+func (v *CounterVec) With(labelValues ...string) *Counter {
+	return v.with(labelValues)
+}
+
+func (v *CounterVec) write(w io.Writer) {
+	v.each(w, func(labels string, c *Counter) {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, labels, formatFloat(c.get()))
+	})
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	value
+}
+
+// Set sets the gauge
+func (g *Gauge) Set(x float64) {
+	g.set(x)
+}
+
+// Add adds to the gauge, or subtracts for negative amounts
+func (g *Gauge) Add(delta float64) {
+	g.add(delta)
+}
+
+// GaugeVec is a gauge for each combination of label values
+type GaugeVec struct {
+	*family[Gauge]
+}
+
+// Gauge registers a gauge with the given labels
+func (r *Registry) Gauge(name, help string, labelNames ...string) *GaugeVec {
+	vec := &GaugeVec{newFamily(name, help, "gauge", labelNames, func() *Gauge { return &Gauge{} })}
+	r.register(vec)
+	return vec
+}
+
+// With returns the gauge for label values
+func (v *GaugeVec) With(labelValues ...string) *Gauge {
+	return v.with(labelValues)
+}
+
+func (v *GaugeVec) write(w io.Writer) {
+	v.each(w, func(labels string, g *Gauge) {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, labels, formatFloat(g.get()))
+	})
+}
+
+// gaugeFunc is a gauge whose value is computed when it is scraped
+type gaugeFunc struct {
+	metricName string
+	help       string
+	fn         func() float64
+}
+
+// GaugeFunc registers a gauge that calls fn for its value on every scrape
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	r.register(&gaugeFunc{metricName: name, help: help, fn: fn})
+}
+
+func (g *gaugeFunc) name() string {
+	return g.metricName
+}
+
+func (g *gaugeFunc) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", g.metricName, g.help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", g.metricName)
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatFloat(g.fn()))
+}
+
+// Histogram counts observations into buckets
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+// Observe records an observation
+func (h *Histogram) Observe(x float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.buckets {
+		if x <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += x
+}
+
+// HistogramVec is a histogram for each combination of label values
+type HistogramVec struct {
+	*family[Histogram]
+	buckets []float64
+}
+
+// Histogram registers a histogram with upper bucket bounds and labels
+func (r *Registry) Histogram(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	newHistogram := func() *Histogram {
+		return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+	}
+	vec := &HistogramVec{family: newFamily(name, help, "histogram", labelNames, newHistogram), buckets: buckets}
+	r.register(vec)
+	return vec
+}
+
+// With returns the histogram for label values
+func (v *HistogramVec) With(labelValues ...string) *Histogram {
+	return v.with(labelValues)
+}
+
+func (v *HistogramVec) write(w io.Writer) {
+	v.each(w, func(labels string, h *Histogram) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.metricName, withLabel(labels, "le", formatFloat(bound)), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.metricName, withLabel(labels, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.metricName, labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.metricName, labels, h.count)
+	})
+}
+
+// labelEscaper escapes label values as the text format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats label pairs as {name="value",...}
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(values[i]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// withLabel adds a label pair to formatted labels
+func withLabel(labels, name, value string) string {
+	pair := fmt.Sprintf("%s=%q", name, value)
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+// formatFloat formats a sample value the way Prometheus expects
+func formatFloat(x float64) string {
+	switch {
+	case math.IsInf(x, 1):
+		return "+Inf"
+	case math.IsInf(x, -1):
+		return "-Inf"
+	case math.IsNaN(x):
+		return "NaN"
+	}
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	// Setup
+	registry := NewRegistry()
+	requests := registry.Counter("http_requests_total", "Requests served.", "route", "code")
+	lag := registry.Gauge("archive_lag_seconds", "Archive lag.")
+	durations := registry.Histogram("tick_seconds", "Tick durations.", []float64{1, 0.5}, "result")
+	registry.GaugeFunc("up", "Always one.", func() float64 { return 1 })
+
+	requests.With("GET /archive/", "200").Inc()
+	requests.With("GET /archive/", "200").Add(2)
+	requests.With(`GET "quoted"`, "404").Inc()
+	lag.With().Set(12.5)
+	durations.With("ok").Observe(0.25)
+	durations.With("ok").Observe(0.75)
+
+	// Execute
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	// Assert
+	expected := `# HELP archive_lag_seconds Archive lag.
+# TYPE archive_lag_seconds gauge
+archive_lag_seconds 12.5
+# HELP http_requests_total Requests served.
+# TYPE http_requests_total counter
+http_requests_total{route="GET \"quoted\"",code="404"} 1
+http_requests_total{route="GET /archive/",code="200"} 3
+# HELP tick_seconds Tick durations.
+# TYPE tick_seconds histogram
+tick_seconds_bucket{result="ok",le="0.5"} 1
+tick_seconds_bucket{result="ok",le="1"} 2
+tick_seconds_bucket{result="ok",le="+Inf"} 2
+tick_seconds_sum{result="ok"} 1
+tick_seconds_count{result="ok"} 2
+# HELP up Always one.
+# TYPE up gauge
+up 1
+`
+	if body := recorder.Body.String(); body != expected {
+		t.Errorf("metrics =\n%s\nwant\n%s", body, expected)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("Content-Type = %s", contentType)
+	}
+}
+
+func TestRegistry_PanicsOnDuplicates(t *testing.T) {
+	registry := NewRegistry()
+	registry.Counter("errors_total", "Errors.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice did not panic")
+		}
+	}()
+	registry.Gauge("errors_total", "Errors.")
+}
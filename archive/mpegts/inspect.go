@@ -0,0 +1,364 @@
+package mpegts
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// nullPID is the PID of stuffing packets
+const nullPID = 0x1fff
+
+// DurationTolerance is how far a segment's real duration may be from its
+// EXTINF duration before Problems reports it
+const DurationTolerance = 500 * time.Millisecond
+
+// codecs names the codecs of the stream types
+var codecs = map[byte]string{
+	0x01:                 "mpeg1video",
+	StreamTypeMPEG2Video: "mpeg2video",
+	0x03:                 "mp3",
+	0x04:                 "mp3",
+	StreamTypeAAC:        "aac",
+	0x10:                 "mpeg4",
+	0x11:                 "aac_latm",
+	StreamTypeH264:       "h264",
+	StreamTypeH265:       "hevc",
+	0x81:                 "ac3",
+	0x87:                 "eac3",
+}
+
+// StreamInfo describes an elementary stream of a transport stream
+type StreamInfo struct {
+	PID   uint16 `json:"pid"`
+	Type  byte   `json:"stream_type"`
+	Codec string `json:"codec"`
+	// Width and Height are the picture size of video streams, if a
+	// sequence parameter set was found
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// FirstPTS and LastPTS are the earliest and latest presentation
+	// timestamps, in 90kHz ticks
+	FirstPTS int64 `json:"first_pts"`
+	LastPTS  int64 `json:"last_pts"`
+	// Frames counts the stream's PES packets
+	Frames int `json:"frames"`
+	// Duration is how long the stream plays for, from the first frame to
+	// the end of the last
+	Duration time.Duration `json:"-"`
+
+	hasPTS   bool
+	lastDTS  int64
+	interval int64
+}
+
+// EndPTS returns the presentation timestamp just after the stream's last
+// frame, where a stream that continues it starts
+func (s StreamInfo) EndPTS() int64 {
+	return s.LastPTS + s.interval
+}
+
+// Keyframe is a place in a transport stream where decoding can start
+type Keyframe struct {
+	// Offset is where the keyframe's first packet starts
+	Offset int64 `json:"offset"`
+	// Size is how many bytes there are from Offset to where the next video
+	// frame starts, or the end of the stream, taking in the keyframe and
+	// any other streams' packets between its packets
+	Size int64 `json:"size"`
+	// Time is the keyframe's presentation time from the start of the
+	// video
+	Time time.Duration `json:"-"`
+}
+
+// ContinuityError is a packet whose continuity counter shows that packets
+// before it were lost
+type ContinuityError struct {
+	PID      uint16 `json:"pid"`
+	Offset   int64  `json:"offset"`
+	Expected uint8  `json:"expected"`
+	Got      uint8  `json:"got"`
+}
+
+// Info describes a transport stream
+type Info struct {
+	Size    int64        `json:"size"`
+	Packets int          `json:"packets"`
+	Streams []StreamInfo `json:"streams"`
+	// Video is the index in Streams of the first video stream, or -1
+	Video     int        `json:"video"`
+	Keyframes []Keyframe `json:"-"`
+	// Tables is how many bytes from the start of the stream it takes to
+	// read its first program association and program map tables, which
+	// players need before any keyframe read on its own, or zero if they
+	// aren't there
+	Tables int64 `json:"-"`
+	// Duration is how long the video plays for, or the longest stream's
+	// duration if there is no video
+	Duration         time.Duration     `json:"-"`
+	ContinuityErrors []ContinuityError `json:"continuity_errors,omitempty"`
+	// BrokenPES counts PES packets whose header could not be parsed
+	BrokenPES int `json:"broken_pes,omitempty"`
+}
+
+// VideoStream returns the first video stream
+func (i Info) VideoStream() (StreamInfo, bool) {
+	if i.Video < 0 {
+		return StreamInfo{}, false
+	}
+	return i.Streams[i.Video], true
+}
+
+// Problems describes what is wrong with a segment that its playlist says
+// lasts extinf, which is not checked if zero
+func (i Info) Problems(extinf time.Duration) []string {
+	var problems []string
+	video, found := i.VideoStream()
+	switch {
+	case !found:
+		problems = append(problems, "no video stream")
+	case len(i.Keyframes) == 0:
+		problems = append(problems, "no keyframes")
+	case i.Keyframes[0].Time > 0:
+		problems = append(problems, fmt.Sprintf("starts %s before its first keyframe", i.Keyframes[0].Time))
+	}
+	if found && video.Frames == 0 {
+		problems = append(problems, "no video frames")
+	}
+	if len(i.ContinuityErrors) > 0 {
+		pids := make(map[uint16]int)
+		for _, e := range i.ContinuityErrors {
+			pids[e.PID]++
+		}
+		problems = append(problems, fmt.Sprintf("%d continuity errors on %d streams", len(i.ContinuityErrors), len(pids)))
+	}
+	if i.BrokenPES > 0 {
+		problems = append(problems, fmt.Sprintf("%d broken PES headers", i.BrokenPES))
+	}
+	if extinf > 0 && (i.Duration-extinf > DurationTolerance || extinf-i.Duration > DurationTolerance) {
+		problems = append(problems, fmt.Sprintf("lasts %s, not the %s of its EXTINF", i.Duration.Round(time.Millisecond), extinf.Round(time.Millisecond)))
+	}
+	return problems
+}
+
+// pes is a PES packet being put together from transport stream packets
+type pes struct {
+	stream       int
+	offset       int64
+	randomAccess bool
+	data         []byte
+}
+
+// inspector holds the state of Inspect
+type inspector struct {
+	info     Info
+	pmtPIDs  map[uint16]bool
+	streams  map[uint16]int
+	counters map[uint16]uint8
+	pending  map[uint16]*pes
+	keyPTS   []int64
+}
+
+// Inspect reads a transport stream and describes it. It fails if the data
+// is not a transport stream, but reports damage inside one in Info.
+func Inspect(r io.Reader) (Info, error) {
+	in := &inspector{
+		info:     Info{Video: -1},
+		pmtPIDs:  make(map[uint16]bool),
+		streams:  make(map[uint16]int),
+		counters: make(map[uint16]uint8),
+		pending:  make(map[uint16]*pes),
+	}
+	reader := NewReader(r)
+	for {
+		offset := reader.Offset()
+		packet, err := reader.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Info{}, err
+		}
+		in.info.Packets++
+		in.info.Size += PacketSize
+		in.packet(packet, offset)
+	}
+	for pid := range in.pending {
+		in.flush(pid)
+	}
+	if in.info.Packets == 0 {
+		return Info{}, errors.New("empty transport stream")
+	}
+	in.finish()
+	return in.info, nil
+}
+
+// packet inspects one packet at offset
+func (in *inspector) packet(packet Packet, offset int64) {
+	pid := packet.PID()
+	if pid == nullPID {
+		return
+	}
+	in.checkContinuity(packet, pid, offset)
+	if !packet.HasPayload() {
+		return
+	}
+
+	switch {
+	case pid == PATPID && packet.PayloadStart():
+		if pids, err := ParsePAT(packet.Payload()); err == nil {
+			for _, pmtPID := range pids {
+				in.pmtPIDs[pmtPID] = true
+			}
+		}
+	case in.pmtPIDs[pid] && packet.PayloadStart():
+		if streams, err := ParsePMT(packet.Payload()); err == nil {
+			if in.info.Tables == 0 {
+				in.info.Tables = offset + PacketSize
+			}
+			for _, stream := range streams {
+				in.addStream(stream)
+			}
+		}
+	default:
+		index, found := in.streams[pid]
+		if !found {
+			return
+		}
+		if packet.PayloadStart() {
+			in.flush(pid)
+			if index == in.info.Video {
+				in.endKeyframe(offset)
+			}
+			in.pending[pid] = &pes{stream: index, offset: offset, randomAccess: packet.RandomAccess()}
+		}
+		if pending := in.pending[pid]; pending != nil {
+			pending.data = append(pending.data, packet.Payload()...)
+		}
+	}
+}
+
+// checkContinuity records packets lost before this one
+func (in *inspector) checkContinuity(packet Packet, pid uint16, offset int64) {
+	counter := packet.ContinuityCounter()
+	last, seen := in.counters[pid]
+	// The counter only increases with packets that carry payload, and a
+	// packet may be sent twice
+	if packet.HasPayload() {
+		in.counters[pid] = counter
+	}
+	if !seen || packet.Discontinuity() {
+		return
+	}
+	expected := last
+	if packet.HasPayload() {
+		expected = (last + 1) & 0x0f
+		if counter == last {
+			return
+		}
+	}
+	if counter != expected {
+		in.info.ContinuityErrors = append(in.info.ContinuityErrors, ContinuityError{PID: pid, Offset: offset, Expected: expected, Got: counter})
+	}
+}
+
+// addStream adds an elementary stream from a program map table
+func (in *inspector) addStream(stream Stream) {
+	if _, found := in.streams[stream.PID]; found {
+		return
+	}
+	codec := codecs[stream.Type]
+	if codec == "" {
+		codec = fmt.Sprintf("0x%02x", stream.Type)
+	}
+	in.streams[stream.PID] = len(in.info.Streams)
+	if stream.IsVideo() && in.info.Video < 0 {
+		in.info.Video = len(in.info.Streams)
+	}
+	in.info.Streams = append(in.info.Streams, StreamInfo{PID: stream.PID, Type: stream.Type, Codec: codec})
+}
+
+// flush inspects the PES packet put together for a PID
+func (in *inspector) flush(pid uint16) {
+	pending := in.pending[pid]
+	delete(in.pending, pid)
+	if pending == nil {
+		return
+	}
+	stream := &in.info.Streams[pending.stream]
+	header, err := ParsePESHeader(pending.data)
+	if err != nil {
+		in.info.BrokenPES++
+		return
+	}
+	stream.Frames++
+	if !header.HasPTS {
+		return
+	}
+
+	pts, decode := header.PTS, header.PTS
+	if header.HasDTS {
+		decode = header.DTS
+	}
+	if stream.hasPTS {
+		pts = Unwrap(pts, stream.LastPTS)
+		decode = Unwrap(decode, stream.lastDTS)
+		if decode > stream.lastDTS {
+			stream.interval = decode - stream.lastDTS
+		}
+		stream.FirstPTS = min(stream.FirstPTS, pts)
+		stream.LastPTS = max(stream.LastPTS, pts)
+	} else {
+		stream.FirstPTS, stream.LastPTS, stream.hasPTS = pts, pts, true
+	}
+	stream.lastDTS = decode
+
+	if pending.stream != in.info.Video {
+		return
+	}
+	if stream.Width == 0 {
+		if width, height, found := resolution(stream.Type, header.Data); found {
+			stream.Width, stream.Height = width, height
+		}
+	}
+	if pending.randomAccess || IsKeyframe(stream.Type, header.Data) {
+		in.info.Keyframes = append(in.info.Keyframes, Keyframe{Offset: pending.offset})
+		in.keyPTS = append(in.keyPTS, pts)
+	}
+}
+
+// endKeyframe notes where the next video frame starts at offset, which is
+// where the last keyframe ends if its size isn't known yet
+func (in *inspector) endKeyframe(offset int64) {
+	if n := len(in.info.Keyframes); n > 0 && in.info.Keyframes[n-1].Size == 0 {
+		in.info.Keyframes[n-1].Size = offset - in.info.Keyframes[n-1].Offset
+	}
+}
+
+// finish works out the durations once every packet has been read
+func (in *inspector) finish() {
+	in.endKeyframe(in.info.Size)
+	for i := range in.info.Streams {
+		stream := &in.info.Streams[i]
+		if stream.hasPTS {
+			stream.Duration = Duration(stream.LastPTS - stream.FirstPTS + stream.interval)
+		}
+		if i == in.info.Video {
+			in.info.Duration = stream.Duration
+		}
+	}
+	if in.info.Video < 0 {
+		for _, stream := range in.info.Streams {
+			in.info.Duration = max(in.info.Duration, stream.Duration)
+		}
+	}
+
+	if video, found := in.info.VideoStream(); found {
+		for i, pts := range in.keyPTS {
+			in.info.Keyframes[i].Time = Duration(pts - video.FirstPTS)
+		}
+	}
+	sort.Slice(in.info.Keyframes, func(i, j int) bool { return in.info.Keyframes[i].Offset < in.info.Keyframes[j].Offset })
+}
@@ -0,0 +1,104 @@
+package mpegts_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"archive/mpegts"
+	"archive/mpegts/mpegtstest"
+)
+
+func TestInspect(t *testing.T) {
+	// Setup: 4s of 1080p video at 25fps with keyframes every 2s
+	stream := mpegtstest.Stream{Frames: 100, FrameRate: 25, GOP: 50, Audio: true, StartPTS: 126000, Width: 1920, Height: 1080}
+
+	// Execute
+	info, err := mpegts.Inspect(bytes.NewReader(stream.Bytes()))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	video, found := info.VideoStream()
+	if !found {
+		t.Fatalf("no video stream in %+v", info.Streams)
+	}
+	if video.Codec != "h264" || video.Width != 1920 || video.Height != 1080 {
+		t.Errorf("video = %s %dx%d, want h264 1920x1080", video.Codec, video.Width, video.Height)
+	}
+	if video.FirstPTS != 126000 || video.LastPTS != 126000+99*3600 || video.Frames != 100 {
+		t.Errorf("video PTS %d-%d over %d frames, want 126000-%d over 100", video.FirstPTS, video.LastPTS, video.Frames, 126000+99*3600)
+	}
+	if len(info.Streams) != 2 || info.Streams[1].Codec != "aac" {
+		t.Errorf("streams = %+v, want video and aac", info.Streams)
+	}
+	if info.Duration != 4*time.Second {
+		t.Errorf("Duration = %v, want 4s", info.Duration)
+	}
+	if len(info.Keyframes) != 2 || info.Keyframes[0].Time != 0 || info.Keyframes[1].Time != 2*time.Second {
+		t.Errorf("Keyframes = %+v, want at 0s and 2s", info.Keyframes)
+	}
+	if info.Keyframes[0].Offset != 2*mpegts.PacketSize {
+		t.Errorf("first keyframe at offset %d, want %d after the tables", info.Keyframes[0].Offset, 2*mpegts.PacketSize)
+	}
+	if info.Tables != 2*mpegts.PacketSize {
+		t.Errorf("Tables = %d, want the %d bytes of the PAT and PMT", info.Tables, 2*mpegts.PacketSize)
+	}
+	for _, keyframe := range info.Keyframes {
+		if keyframe.Size <= 0 || keyframe.Size%mpegts.PacketSize != 0 || keyframe.Offset+keyframe.Size >= info.Size {
+			t.Errorf("keyframe %+v, want a whole number of packets ending before the stream of %d bytes", keyframe, info.Size)
+		}
+	}
+	if problems := info.Problems(4 * time.Second); len(problems) != 0 {
+		t.Errorf("Problems = %v, want none", problems)
+	}
+}
+
+func TestInspect_ResolutionWithCropping(t *testing.T) {
+	stream := mpegtstest.Stream{Frames: 1, FrameRate: 25, GOP: 25, Width: 854, Height: 480}
+
+	info, err := mpegts.Inspect(bytes.NewReader(stream.Bytes()))
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if video, _ := info.VideoStream(); video.Width != 854 || video.Height != 480 {
+		t.Errorf("resolution = %dx%d, want 854x480", video.Width, video.Height)
+	}
+}
+
+func TestInspect_Problems(t *testing.T) {
+	// Setup: drop the eleventh video packet, which follows the tables,
+	// the keyframe and nine more frames with audio
+	data := mpegtstest.Stream{Frames: 50, FrameRate: 25, GOP: 50, Audio: true}.Bytes()
+	dropped := (2 + 10*2) * mpegts.PacketSize
+	data = append(data[:dropped:dropped], data[dropped+mpegts.PacketSize:]...)
+
+	// Execute
+	info, err := mpegts.Inspect(bytes.NewReader(data))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if len(info.ContinuityErrors) != 1 || info.ContinuityErrors[0].PID != mpegtstest.VideoPID {
+		t.Fatalf("ContinuityErrors = %+v, want one on the video", info.ContinuityErrors)
+	}
+	if e := info.ContinuityErrors[0]; e.Expected != 10 || e.Got != 11 {
+		t.Errorf("continuity error expected %d got %d, want 10 and 11", e.Expected, e.Got)
+	}
+	problems := strings.Join(info.Problems(10*time.Second), "; ")
+	if !strings.Contains(problems, "1 continuity errors") || !strings.Contains(problems, "not the 10s of its EXTINF") {
+		t.Errorf("Problems = %q, want continuity and duration problems", problems)
+	}
+}
+
+func TestInspect_NotTransportStream(t *testing.T) {
+	if _, err := mpegts.Inspect(strings.NewReader(strings.Repeat("not a stream", 100))); err == nil {
+		t.Error("Inspect error = nil, want an error")
+	}
+	if _, err := mpegts.Inspect(strings.NewReader("")); err == nil {
+		t.Error("Inspect of nothing error = nil, want an error")
+	}
+}
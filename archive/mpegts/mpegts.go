@@ -0,0 +1,286 @@
+// Package mpegts reads the parts of MPEG transport streams the archive
+// needs: packets, the program tables, PES timestamps and keyframes.
+package mpegts
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// PacketSize is the size of every transport stream packet
+	PacketSize = 188
+	// syncByte starts every packet
+	syncByte = 0x47
+	// PATPID is the PID of the program association table
+	PATPID = 0x0000
+	// ClockRate is the frequency of PES timestamps
+	ClockRate = 90000
+	// ptsWrap is where 33 bit timestamps wrap around
+	ptsWrap = 1 << 33
+)
+
+// ErrSync is returned when the data is not a transport stream, or has lost
+// packet alignment
+var ErrSync = errors.New("lost transport stream sync")
+
+// Packet is a transport stream packet
+type Packet []byte
+
+// PID returns the packet identifier
+func (p Packet) PID() uint16 {
+	return uint16(p[1]&0x1f)<<8 | uint16(p[2])
+}
+
+// PayloadStart reports whether a PES packet or table section starts in the
+// packet
+func (p Packet) PayloadStart() bool {
+	return p[1]&0x40 != 0
+}
+
+// ContinuityCounter returns the 4 bit counter that increases with every
+// packet of a PID that carries payload
+func (p Packet) ContinuityCounter() uint8 {
+	return p[3] & 0x0f
+}
+
+// HasPayload reports whether the packet carries payload
+func (p Packet) HasPayload() bool {
+	return p[3]&0x10 != 0
+}
+
+// hasAdaptation reports whether the packet has an adaptation field
+func (p Packet) hasAdaptation() bool {
+	return p[3]&0x20 != 0
+}
+
+// RandomAccess reports whether the packet's adaptation field marks it as a
+// place to start decoding, which muxers set on keyframes
+func (p Packet) RandomAccess() bool {
+	return p.hasAdaptation() && p[4] > 0 && p[5]&0x40 != 0
+}
+
+// Discontinuity reports whether the adaptation field flags a break in the
+// continuity counter or timestamps
+func (p Packet) Discontinuity() bool {
+	return p.hasAdaptation() && p[4] > 0 && p[5]&0x80 != 0
+}
+
+// Payload returns the packet's payload, without the adaptation field
+func (p Packet) Payload() []byte {
+	if !p.HasPayload() {
+		return nil
+	}
+	offset := 4
+	if p.hasAdaptation() {
+		offset += 1 + int(p[4])
+	}
+	if offset >= PacketSize {
+		return nil
+	}
+	return p[offset:]
+}
+
+// Reader reads packets from a transport stream
+type Reader struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+// NewReader returns a reader of the packets in r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, 64*PacketSize)}
+}
+
+// Offset returns where in the stream the next packet starts
+func (r *Reader) Offset() int64 {
+	return r.offset
+}
+
+// ReadPacket returns the next packet. It returns io.EOF at the end of the
+// stream and io.ErrUnexpectedEOF if the stream ends inside a packet.
+func (r *Reader) ReadPacket() (Packet, error) {
+	packet := make(Packet, PacketSize)
+	if _, err := io.ReadFull(r.r, packet); err != nil {
+		return nil, err
+	}
+	if packet[0] != syncByte {
+		return nil, fmt.Errorf("%w at offset %d", ErrSync, r.offset)
+	}
+	r.offset += PacketSize
+	return packet, nil
+}
+
+// section returns the table section starting in a payload, skipping the
+// pointer field, or nil if the payload is too short
+func section(payload []byte) []byte {
+	if len(payload) < 1 || int(payload[0])+1 >= len(payload) {
+		return nil
+	}
+	table := payload[1+int(payload[0]):]
+	if len(table) < 3 {
+		return nil
+	}
+	length := int(table[1]&0x0f)<<8 | int(table[2])
+	if 3+length > len(table) {
+		return nil
+	}
+	return table[:3+length]
+}
+
+// ParsePAT returns the PIDs of the program map tables listed in a program
+// association table that starts in payload
+func ParsePAT(payload []byte) ([]uint16, error) {
+	table := section(payload)
+	if table == nil || table[0] != 0x00 || len(table) < 12 {
+		return nil, errors.New("invalid program association table")
+	}
+	var pids []uint16
+	// Programs follow the 8 byte header and precede the 4 byte CRC
+	for i := 8; i+4 <= len(table)-4; i += 4 {
+		program := uint16(table[i])<<8 | uint16(table[i+1])
+		if program == 0 {
+			// Program 0 points at the network information table
+			continue
+		}
+		pids = append(pids, uint16(table[i+2]&0x1f)<<8|uint16(table[i+3]))
+	}
+	return pids, nil
+}
+
+// Stream is an elementary stream listed in a program map table
+type Stream struct {
+	PID  uint16
+	Type byte
+}
+
+// Stream types of the codecs the archive knows
+const (
+	StreamTypeMPEG2Video = 0x02
+	StreamTypeAAC        = 0x0f
+	StreamTypeH264       = 0x1b
+	StreamTypeH265       = 0x24
+)
+
+// IsVideo reports whether the stream carries video
+func (s Stream) IsVideo() bool {
+	switch s.Type {
+	case 0x01, StreamTypeMPEG2Video, 0x10, StreamTypeH264, StreamTypeH265:
+		return true
+	}
+	return false
+}
+
+// ParsePMT returns the elementary streams listed in a program map table
+// that starts in payload
+func ParsePMT(payload []byte) ([]Stream, error) {
+	table := section(payload)
+	if table == nil || table[0] != 0x02 || len(table) < 16 {
+		return nil, errors.New("invalid program map table")
+	}
+	infoLength := int(table[10]&0x0f)<<8 | int(table[11])
+	var streams []Stream
+	for i := 12 + infoLength; i+5 <= len(table)-4; {
+		stream := Stream{Type: table[i], PID: uint16(table[i+1]&0x1f)<<8 | uint16(table[i+2])}
+		streams = append(streams, stream)
+		i += 5 + (int(table[i+3]&0x0f)<<8 | int(table[i+4]))
+	}
+	return streams, nil
+}
+
+// PESHeader is the start of a PES packet
+type PESHeader struct {
+	StreamID byte
+	// PTS and DTS are in 90kHz clock ticks, and set if HasPTS and HasDTS
+	PTS, DTS       int64
+	HasPTS, HasDTS bool
+	// Data is the start of the elementary stream data
+	Data []byte
+}
+
+// ParsePESHeader parses the header of a PES packet that starts in payload
+func ParsePESHeader(payload []byte) (PESHeader, error) {
+	if len(payload) < 9 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 {
+		return PESHeader{}, errors.New("invalid PES start code")
+	}
+	header := PESHeader{StreamID: payload[3]}
+	// Streams such as padding have no optional header
+	if header.StreamID == 0xbc || header.StreamID == 0xbe || header.StreamID == 0xbf {
+		header.Data = payload[6:]
+		return header, nil
+	}
+
+	flags := payload[7]
+	headerEnd := 9 + int(payload[8])
+	if headerEnd > len(payload) {
+		return PESHeader{}, errors.New("truncated PES header")
+	}
+	if flags&0x80 != 0 {
+		if len(payload) < 14 {
+			return PESHeader{}, errors.New("truncated PTS")
+		}
+		header.PTS, header.HasPTS = timestamp(payload[9:14]), true
+	}
+	if flags&0x40 != 0 {
+		if len(payload) < 19 {
+			return PESHeader{}, errors.New("truncated DTS")
+		}
+		header.DTS, header.HasDTS = timestamp(payload[14:19]), true
+	}
+	header.Data = payload[headerEnd:]
+	return header, nil
+}
+
+// timestamp decodes a 33 bit PES timestamp
+func timestamp(b []byte) int64 {
+	return int64(b[0]&0x0e)<<29 | int64(b[1])<<22 | int64(b[2]&0xfe)<<14 | int64(b[3])<<7 | int64(b[4])>>1
+}
+
+// Duration converts 90kHz clock ticks to a duration
+func Duration(ticks int64) time.Duration {
+	return time.Duration(ticks) * time.Second / ClockRate
+}
+
+// Unwrap returns the timestamp ts, which wraps around every 26.5 hours,
+// as the value closest to previous, so that timestamps keep increasing
+// across the wrap
+func Unwrap(ts, previous int64) int64 {
+	ts += previous - previous%ptsWrap
+	switch {
+	case ts-previous > ptsWrap/2:
+		ts -= ptsWrap
+	case previous-ts > ptsWrap/2:
+		ts += ptsWrap
+	}
+	return ts
+}
+
+// IsKeyframe reports whether the start of a video PES packet's data holds a
+// frame that decoding can start at: an H.264 IDR or SPS, an H.265 IRAP or
+// parameter set, or an MPEG-2 sequence header
+func IsKeyframe(streamType byte, data []byte) bool {
+	for i := 0; i+3 < len(data); i++ {
+		if data[i] != 0 || data[i+1] != 0 || data[i+2] != 1 {
+			continue
+		}
+		unit := data[i+3]
+		switch streamType {
+		case StreamTypeH264:
+			if kind := unit & 0x1f; kind == 5 || kind == 7 {
+				return true
+			}
+		case StreamTypeH265:
+			if kind := (unit >> 1) & 0x3f; (kind >= 16 && kind <= 21) || (kind >= 32 && kind <= 34) {
+				return true
+			}
+		case 0x01, StreamTypeMPEG2Video:
+			if unit == 0xb3 {
+				return true
+			}
+		}
+	}
+	return false
+}
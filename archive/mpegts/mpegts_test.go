@@ -0,0 +1,143 @@
+package mpegts_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"archive/mpegts"
+	"archive/mpegts/mpegtstest"
+)
+
+func TestParseTables(t *testing.T) {
+	reader := mpegts.NewReader(bytes.NewReader(mpegtstest.Stream{Frames: 1, FrameRate: 25, GOP: 25, Audio: true, StartPTS: 900}.Bytes()))
+
+	patPacket, err := reader.ReadPacket()
+	if err != nil {
+		t.Fatalf("ReadPacket failed: %v", err)
+	}
+	pmtPIDs, err := mpegts.ParsePAT(patPacket.Payload())
+	if err != nil || len(pmtPIDs) != 1 || pmtPIDs[0] != mpegtstest.PMTPID {
+		t.Fatalf("ParsePAT = %v, %v, want [%d]", pmtPIDs, err, mpegtstest.PMTPID)
+	}
+
+	pmtPacket, _ := reader.ReadPacket()
+	streams, err := mpegts.ParsePMT(pmtPacket.Payload())
+	if err != nil || len(streams) != 2 {
+		t.Fatalf("ParsePMT = %v, %v, want two streams", streams, err)
+	}
+	if !streams[0].IsVideo() || streams[0].PID != mpegtstest.VideoPID || streams[1].IsVideo() {
+		t.Errorf("streams = %+v, want video then audio", streams)
+	}
+
+	videoPacket, _ := reader.ReadPacket()
+	header, err := mpegts.ParsePESHeader(videoPacket.Payload())
+	if err != nil || !header.HasPTS || header.PTS != 900 {
+		t.Fatalf("ParsePESHeader = %+v, %v, want PTS 900", header, err)
+	}
+	if !videoPacket.RandomAccess() || !mpegts.IsKeyframe(mpegts.StreamTypeH264, header.Data) {
+		t.Errorf("first video packet is not a keyframe")
+	}
+}
+
+func TestReader_LostSync(t *testing.T) {
+	data := mpegtstest.Stream{Frames: 2, FrameRate: 25, GOP: 25}.Bytes()
+	reader := mpegts.NewReader(bytes.NewReader(data[1:]))
+	if _, err := reader.ReadPacket(); !errors.Is(err, mpegts.ErrSync) {
+		t.Errorf("ReadPacket error = %v, want %v", err, mpegts.ErrSync)
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	const wrap = 1 << 33
+	tests := []struct {
+		ts, previous, want int64
+	}{
+		{1000, 900, 1000},
+		{100, wrap - 100, wrap + 100},
+		{wrap - 100, wrap + 100, wrap - 100},
+		{500, 2*wrap + 400, 2*wrap + 500},
+	}
+	for _, tt := range tests {
+		if got := mpegts.Unwrap(tt.ts, tt.previous); got != tt.want {
+			t.Errorf("Unwrap(%d, %d) = %d, want %d", tt.ts, tt.previous, got, tt.want)
+		}
+	}
+}
+
+// buffers collects the segments Split writes
+type buffers []*bytes.Buffer
+
+func (b *buffers) create(index int) (io.WriteCloser, error) {
+	buffer := &bytes.Buffer{}
+	*b = append(*b, buffer)
+	return nopCloser{buffer}, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream mpegtstest.Stream
+	}{
+		{"flagged keyframes", mpegtstest.Stream{Frames: 250, FrameRate: 25, GOP: 50, Audio: true}},
+		{"keyframes found in the video data", mpegtstest.Stream{Frames: 250, FrameRate: 25, GOP: 50, NoRandomAccess: true}},
+		{"timestamps wrapping", mpegtstest.Stream{Frames: 250, FrameRate: 25, GOP: 50, StartPTS: 1<<33 - 90000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			var written buffers
+
+			// Execute: 10s of video with keyframes every 2s in 3s segments
+			segments, err := mpegts.Split(bytes.NewReader(tt.stream.Bytes()), 3*time.Second, written.create)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("Split failed: %v", err)
+			}
+			wantStarts := []time.Duration{0, 4 * time.Second, 8 * time.Second}
+			wantDurations := []time.Duration{4 * time.Second, 4 * time.Second, 2 * time.Second}
+			if len(segments) != len(wantStarts) {
+				t.Fatalf("got %d segments, want %d: %+v", len(segments), len(wantStarts), segments)
+			}
+			for i, segment := range segments {
+				if segment.Start != wantStarts[i] || segment.Duration != wantDurations[i] {
+					t.Errorf("segment %d = %v+%v, want %v+%v", i, segment.Start, segment.Duration, wantStarts[i], wantDurations[i])
+				}
+				if segment.Size != int64(written[i].Len()) {
+					t.Errorf("segment %d Size = %d, want %d", i, segment.Size, written[i].Len())
+				}
+
+				// Every segment starts with the tables and then a keyframe
+				reader := mpegts.NewReader(written[i])
+				var pids []uint16
+				for j := 0; j < 3; j++ {
+					packet, err := reader.ReadPacket()
+					if err != nil {
+						t.Fatalf("segment %d: ReadPacket failed: %v", i, err)
+					}
+					pids = append(pids, packet.PID())
+				}
+				if pids[0] != mpegts.PATPID || pids[1] != mpegtstest.PMTPID || pids[2] != mpegtstest.VideoPID {
+					t.Errorf("segment %d starts with PIDs %v, want PAT, PMT and video", i, pids)
+				}
+			}
+		})
+	}
+}
+
+func TestSplit_NoVideo(t *testing.T) {
+	_, err := mpegts.Split(bytes.NewReader(nil), time.Second, (&buffers{}).create)
+	if err == nil {
+		t.Error("Split error = nil, want an error")
+	}
+}
@@ -0,0 +1,225 @@
+// Package mpegtstest builds small transport streams for tests
+package mpegtstest
+
+import "archive/mpegts"
+
+// PIDs of the streams in built transport streams
+const (
+	PMTPID   = 0x1000
+	VideoPID = 0x0100
+	AudioPID = 0x0101
+)
+
+// Stream describes a transport stream of an H.264 video at a constant frame
+// rate, optionally with an AAC audio stream
+type Stream struct {
+	Frames    int
+	FrameRate int
+	// GOP is how many frames there are from one keyframe to the next
+	GOP      int
+	StartPTS int64
+	Audio    bool
+	// NoRandomAccess leaves keyframes unflagged in the adaptation field, so
+	// they can only be found in the video data
+	NoRandomAccess bool
+	// Width and Height are the picture size in the sequence parameter set
+	// sent with keyframes, 1280x720 if not set
+	Width, Height int
+}
+
+// Bytes returns the transport stream. The program tables are repeated
+// before every keyframe, like ffmpeg does.
+func (s Stream) Bytes() []byte {
+	b := &builder{counters: make(map[uint16]byte)}
+	ticks := int64(mpegts.ClockRate / s.FrameRate)
+	for frame := 0; frame < s.Frames; frame++ {
+		keyframe := frame%s.GOP == 0
+		pts := s.StartPTS + int64(frame)*ticks
+		if keyframe {
+			b.packet(mpegts.PATPID, true, false, pat())
+			b.packet(PMTPID, true, false, pmt(s.Audio))
+		}
+		nal := []byte{0, 0, 0, 1, 0x41, 0x9a}
+		if keyframe {
+			nal = append([]byte{0, 0, 0, 1}, s.sps()...)
+			nal = append(nal, 0, 0, 0, 1, 0x65, 0x88)
+		}
+		b.packet(VideoPID, true, keyframe && !s.NoRandomAccess, pes(0xe0, pts, nal))
+		if s.Audio {
+			b.packet(AudioPID, true, false, pes(0xc0, pts, []byte{0xff, 0xf1, 0x50, 0x80}))
+		}
+	}
+	return b.out
+}
+
+// sps returns a baseline H.264 sequence parameter set NAL unit for the
+// stream's picture size
+func (s Stream) sps() []byte {
+	width, height := s.Width, s.Height
+	if width == 0 || height == 0 {
+		width, height = 1280, 720
+	}
+	widthInMbs, heightInMbs := (width+15)/16, (height+15)/16
+
+	w := &bitWriter{}
+	w.bits(66, 8) // baseline profile
+	w.bits(0, 8)  // constraint flags
+	w.bits(31, 8) // level 3.1
+	w.ue(0)       // seq_parameter_set_id
+	w.ue(0)       // log2_max_frame_num_minus4
+	w.ue(0)       // pic_order_cnt_type
+	w.ue(0)       // log2_max_pic_order_cnt_lsb_minus4
+	w.ue(1)       // max_num_ref_frames
+	w.bits(0, 1)  // gaps_in_frame_num_value_allowed_flag
+	w.ue(widthInMbs - 1)
+	w.ue(heightInMbs - 1)
+	w.bits(1, 1) // frame_mbs_only_flag
+	w.bits(1, 1) // direct_8x8_inference_flag
+	cropRight, cropBottom := (widthInMbs*16-width)/2, (heightInMbs*16-height)/2
+	if cropRight > 0 || cropBottom > 0 {
+		w.bits(1, 1)
+		w.ue(0)
+		w.ue(cropRight)
+		w.ue(0)
+		w.ue(cropBottom)
+	} else {
+		w.bits(0, 1)
+	}
+	w.bits(0, 1) // vui_parameters_present_flag
+	w.bits(1, 1) // rbsp_stop_one_bit
+	return append([]byte{0x67}, escape(w.bytes())...)
+}
+
+// bitWriter writes the bits of a NAL unit
+type bitWriter struct {
+	out []byte
+	n   int
+}
+
+func (w *bitWriter) bits(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.out = append(w.out, 0)
+		}
+		w.out[len(w.out)-1] |= byte(v>>i&1) << (7 - w.n%8)
+		w.n++
+	}
+}
+
+// ue writes an unsigned exponential Golomb code
+func (w *bitWriter) ue(v int) {
+	length := 0
+	for (v+1)>>length > 1 {
+		length++
+	}
+	w.bits(0, length)
+	w.bits(v+1, length+1)
+}
+
+func (w *bitWriter) bytes() []byte {
+	return w.out
+}
+
+// escape inserts emulation prevention bytes into a NAL unit
+func escape(data []byte) []byte {
+	var out []byte
+	zeros := 0
+	for _, b := range data {
+		if zeros >= 2 && b <= 3 {
+			out = append(out, 3)
+			zeros = 0
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// builder writes packets, counting continuity per PID
+type builder struct {
+	out      []byte
+	counters map[uint16]byte
+}
+
+// packet writes a packet with payload, which must fit in one packet,
+// padding it with adaptation field stuffing
+func (b *builder) packet(pid uint16, start, randomAccess bool, payload []byte) {
+	packet := make([]byte, 4, mpegts.PacketSize)
+	packet[0] = 0x47
+	packet[1] = byte(pid>>8) & 0x1f
+	if start {
+		packet[1] |= 0x40
+	}
+	packet[2] = byte(pid)
+	packet[3] = 0x10 | b.counters[pid]
+	b.counters[pid] = (b.counters[pid] + 1) & 0x0f
+
+	if stuffing := mpegts.PacketSize - 4 - len(payload); stuffing > 0 || randomAccess {
+		packet[3] |= 0x20
+		packet = append(packet, byte(stuffing-1))
+		if stuffing > 1 {
+			flags := byte(0)
+			if randomAccess {
+				flags = 0x40
+			}
+			packet = append(packet, flags)
+			for i := 2; i < stuffing; i++ {
+				packet = append(packet, 0xff)
+			}
+		}
+	}
+	b.out = append(b.out, append(packet, payload...)...)
+}
+
+// pat returns a program association table pointing at the PMT
+func pat() []byte {
+	return psi(0x00, 0x0001, []byte{0x00, 0x01, 0xe0 | PMTPID>>8, PMTPID & 0xff})
+}
+
+// pmt returns a program map table listing the video, and the audio if
+// there is any
+func pmt(audio bool) []byte {
+	body := []byte{0xe0 | VideoPID>>8, VideoPID & 0xff, 0xf0, 0x00}
+	body = append(body, mpegts.StreamTypeH264, 0xe0|VideoPID>>8, VideoPID&0xff, 0xf0, 0x00)
+	if audio {
+		body = append(body, mpegts.StreamTypeAAC, 0xe0|AudioPID>>8, AudioPID&0xff, 0xf0, 0x00)
+	}
+	return psi(0x02, 0x0001, body)
+}
+
+// psi returns a table section behind a zero pointer field
+func psi(tableID byte, id uint16, body []byte) []byte {
+	length := 5 + len(body) + 4
+	section := []byte{tableID, 0xb0 | byte(length>>8), byte(length), byte(id >> 8), byte(id), 0xc1, 0x00, 0x00}
+	section = append(section, body...)
+	crc := CRC32(section)
+	section = append(section, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+	return append([]byte{0x00}, section...)
+}
+
+// pes returns a PES packet with a PTS
+func pes(streamID byte, pts int64, data []byte) []byte {
+	header := []byte{0, 0, 1, streamID, 0, 0, 0x80, 0x80, 5,
+		0x21 | byte(pts>>29)&0x0e, byte(pts >> 22), byte(pts>>14) | 1, byte(pts >> 7), byte(pts<<1) | 1}
+	return append(header, data...)
+}
+
+// CRC32 returns the MPEG-2 CRC of a table section
+func CRC32(data []byte) uint32 {
+	crc := uint32(0xffffffff)
+	for _, b := range data {
+		crc ^= uint32(b) << 24
+		for i := 0; i < 8; i++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
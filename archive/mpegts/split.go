@@ -0,0 +1,197 @@
+package mpegts
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Segment describes a piece of a stream cut by Split
+type Segment struct {
+	// Start is when the segment starts, from the first keyframe
+	Start    time.Duration
+	Duration time.Duration
+	Size     int64
+}
+
+// splitter holds the state of Split
+type splitter struct {
+	target time.Duration
+	create func(index int) (io.WriteCloser, error)
+
+	pat      Packet
+	pmtPIDs  map[uint16]bool
+	pmt      Packet
+	pmtPID   uint16
+	video    Stream
+	hasVideo bool
+
+	segments   []Segment
+	w          io.WriteCloser
+	firstPTS   int64
+	startPTS   int64
+	lastPTS    int64
+	lastDecode int64
+	interval   int64
+	started    bool
+}
+
+// Split cuts a transport stream into segments that start at video
+// keyframes and last at least target, writing each to the writer that
+// create returns for its index. Every segment starts with the stream's
+// program tables so that it plays on its own. Anything before the first
+// keyframe can't be decoded and is dropped.
+func Split(r io.Reader, target time.Duration, create func(index int) (io.WriteCloser, error)) ([]Segment, error) {
+	s := &splitter{target: target, create: create, pmtPIDs: make(map[uint16]bool)}
+	err := s.run(NewReader(r))
+	if s.w != nil {
+		if closeErr := s.w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !s.hasVideo {
+		return nil, errors.New("no video stream")
+	}
+	if !s.started {
+		return nil, errors.New("no keyframes in the video stream")
+	}
+	return s.segments, nil
+}
+
+func (s *splitter) run(reader *Reader) error {
+	for {
+		packet, err := reader.ReadPacket()
+		if err == io.EOF {
+			return s.finish(s.lastPTS - s.startPTS + s.interval)
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.handle(packet); err != nil {
+			return err
+		}
+		if s.w != nil {
+			if err := s.write(packet); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handle keeps track of the program tables and cuts a new segment where a
+// keyframe starts
+func (s *splitter) handle(packet Packet) error {
+	if !packet.PayloadStart() {
+		return nil
+	}
+	pid := packet.PID()
+	switch {
+	case pid == PATPID:
+		pmtPIDs, err := ParsePAT(packet.Payload())
+		if err != nil {
+			return err
+		}
+		s.pat = packet
+		for _, pmtPID := range pmtPIDs {
+			s.pmtPIDs[pmtPID] = true
+		}
+		return nil
+	case s.pmtPIDs[pid]:
+		streams, err := ParsePMT(packet.Payload())
+		if err != nil {
+			return err
+		}
+		// Follow the first program with video
+		for _, stream := range streams {
+			if stream.IsVideo() && !s.hasVideo {
+				s.video, s.hasVideo, s.pmtPID = stream, true, pid
+			}
+		}
+		if s.hasVideo && pid == s.pmtPID {
+			s.pmt = packet
+		}
+		return nil
+	case !s.hasVideo || pid != s.video.PID:
+		return nil
+	}
+
+	header, err := ParsePESHeader(packet.Payload())
+	if err != nil || !header.HasPTS {
+		// A broken PES header is no place to cut
+		return nil
+	}
+	decode := header.PTS
+	if header.HasDTS {
+		decode = header.DTS
+	}
+	if s.started {
+		header.PTS = Unwrap(header.PTS, s.lastPTS)
+		decode = Unwrap(decode, s.lastDecode)
+		if decode > s.lastDecode {
+			s.interval = decode - s.lastDecode
+		}
+	}
+
+	if packet.RandomAccess() || IsKeyframe(s.video.Type, header.Data) {
+		switch {
+		case !s.started:
+			s.started = true
+			s.firstPTS = header.PTS
+			if err := s.startSegment(header.PTS); err != nil {
+				return err
+			}
+		case Duration(header.PTS-s.startPTS) >= s.target:
+			if err := s.finish(header.PTS - s.startPTS); err != nil {
+				return err
+			}
+			if err := s.startSegment(header.PTS); err != nil {
+				return err
+			}
+		}
+	}
+	s.lastDecode = decode
+	s.lastPTS = max(s.lastPTS, header.PTS)
+	return nil
+}
+
+// startSegment opens the next segment and writes the program tables to it
+func (s *splitter) startSegment(pts int64) error {
+	w, err := s.create(len(s.segments))
+	if err != nil {
+		return err
+	}
+	s.w = w
+	s.startPTS = pts
+	s.segments = append(s.segments, Segment{Start: Duration(pts - s.firstPTS)})
+	if err := s.write(s.pat); err != nil {
+		return err
+	}
+	return s.write(s.pmt)
+}
+
+// finish closes the current segment, which lasted ticks
+func (s *splitter) finish(ticks int64) error {
+	if s.w == nil {
+		return nil
+	}
+	s.segments[len(s.segments)-1].Duration = Duration(ticks)
+	w := s.w
+	s.w = nil
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to close segment %d: %w", len(s.segments)-1, err)
+	}
+	return nil
+}
+
+// write writes a packet to the current segment
+func (s *splitter) write(packet Packet) error {
+	if _, err := s.w.Write(packet); err != nil {
+		return fmt.Errorf("failed to write segment %d: %w", len(s.segments)-1, err)
+	}
+	s.segments[len(s.segments)-1].Size += PacketSize
+	return nil
+}
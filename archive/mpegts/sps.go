@@ -0,0 +1,395 @@
+package mpegts
+
+import "errors"
+
+// errShortSPS is returned for sequence parameter sets that end early
+var errShortSPS = errors.New("truncated sequence parameter set")
+
+// bitReader reads the bits of a NAL unit whose emulation prevention bytes
+// have been removed
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) bit() (uint, error) {
+	if r.pos >= len(r.data)*8 {
+		return 0, errShortSPS
+	}
+	b := r.data[r.pos/8] >> (7 - r.pos%8) & 1
+	r.pos++
+	return uint(b), nil
+}
+
+func (r *bitReader) bits(n int) (uint, error) {
+	var v uint
+	for i := 0; i < n; i++ {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<1 | b
+	}
+	return v, nil
+}
+
+func (r *bitReader) skip(n int) error {
+	if r.pos+n > len(r.data)*8 {
+		return errShortSPS
+	}
+	r.pos += n
+	return nil
+}
+
+// ue reads an unsigned exponential Golomb code
+func (r *bitReader) ue() (uint, error) {
+	zeros := 0
+	for {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		if b == 1 {
+			break
+		}
+		zeros++
+		if zeros > 31 {
+			return 0, errors.New("invalid exponential Golomb code")
+		}
+	}
+	rest, err := r.bits(zeros)
+	return 1<<zeros - 1 + rest, err
+}
+
+// se reads a signed exponential Golomb code
+func (r *bitReader) se() (int, error) {
+	v, err := r.ue()
+	if v%2 == 1 {
+		return int(v+1) / 2, err
+	}
+	return -int(v / 2), err
+}
+
+// unescape removes the emulation prevention bytes from a NAL unit
+func unescape(nal []byte) []byte {
+	out := make([]byte, 0, len(nal))
+	zeros := 0
+	for _, b := range nal {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// nalUnits returns the NAL units in Annex B data, without start codes
+func nalUnits(data []byte) [][]byte {
+	var units [][]byte
+	start := -1
+	for i := 0; i+2 < len(data); i++ {
+		if data[i] != 0 || data[i+1] != 0 || data[i+2] != 1 {
+			continue
+		}
+		if start >= 0 {
+			end := i
+			// A four byte start code leaves a zero at the end of the unit
+			if end > start && data[end-1] == 0 {
+				end--
+			}
+			units = append(units, data[start:end])
+		}
+		start = i + 3
+		i += 2
+	}
+	if start >= 0 && start < len(data) {
+		units = append(units, data[start:])
+	}
+	return units
+}
+
+// resolution returns the picture size from the sequence parameter set in
+// a video frame, if it has one
+func resolution(streamType byte, data []byte) (int, int, bool) {
+	for _, unit := range nalUnits(data) {
+		if len(unit) < 2 {
+			continue
+		}
+		switch {
+		case streamType == StreamTypeH264 && unit[0]&0x1f == 7:
+			width, height, err := h264Resolution(unescape(unit[1:]))
+			return width, height, err == nil
+		case streamType == StreamTypeH265 && (unit[0]>>1)&0x3f == 33:
+			width, height, err := h265Resolution(unescape(unit[2:]))
+			return width, height, err == nil
+		}
+	}
+	return 0, 0, false
+}
+
+// h264Resolution parses an H.264 sequence parameter set, after the NAL
+// header, for the cropped picture size
+func h264Resolution(sps []byte) (int, int, error) {
+	r := &bitReader{data: sps}
+	profile, err := r.bits(8)
+	if err != nil {
+		return 0, 0, err
+	}
+	// Constraint flags and level
+	if err := r.skip(16); err != nil {
+		return 0, 0, err
+	}
+	if _, err := r.ue(); err != nil {
+		return 0, 0, err
+	}
+
+	chromaFormat := uint(1)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		if chromaFormat, err = r.ue(); err != nil {
+			return 0, 0, err
+		}
+		if chromaFormat == 3 {
+			if err := r.skip(1); err != nil {
+				return 0, 0, err
+			}
+		}
+		// Bit depths
+		if _, err := r.ue(); err != nil {
+			return 0, 0, err
+		}
+		if _, err := r.ue(); err != nil {
+			return 0, 0, err
+		}
+		if err := r.skip(1); err != nil {
+			return 0, 0, err
+		}
+		scalingMatrix, err := r.bit()
+		if err != nil {
+			return 0, 0, err
+		}
+		if scalingMatrix == 1 {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				present, err := r.bit()
+				if err != nil {
+					return 0, 0, err
+				}
+				if present == 0 {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				if err := skipScalingList(r, size); err != nil {
+					return 0, 0, err
+				}
+			}
+		}
+	}
+
+	// log2_max_frame_num_minus4
+	if _, err := r.ue(); err != nil {
+		return 0, 0, err
+	}
+	pocType, err := r.ue()
+	if err != nil {
+		return 0, 0, err
+	}
+	switch pocType {
+	case 0:
+		if _, err := r.ue(); err != nil {
+			return 0, 0, err
+		}
+	case 1:
+		if err := r.skip(1); err != nil {
+			return 0, 0, err
+		}
+		if _, err := r.se(); err != nil {
+			return 0, 0, err
+		}
+		if _, err := r.se(); err != nil {
+			return 0, 0, err
+		}
+		cycle, err := r.ue()
+		if err != nil {
+			return 0, 0, err
+		}
+		for i := uint(0); i < cycle; i++ {
+			if _, err := r.se(); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	// max_num_ref_frames and gaps_in_frame_num_value_allowed_flag
+	if _, err := r.ue(); err != nil {
+		return 0, 0, err
+	}
+	if err := r.skip(1); err != nil {
+		return 0, 0, err
+	}
+
+	widthInMbs, err := r.ue()
+	if err != nil {
+		return 0, 0, err
+	}
+	heightInMapUnits, err := r.ue()
+	if err != nil {
+		return 0, 0, err
+	}
+	frameMbsOnly, err := r.bit()
+	if err != nil {
+		return 0, 0, err
+	}
+	if frameMbsOnly == 0 {
+		if err := r.skip(1); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := r.skip(1); err != nil {
+		return 0, 0, err
+	}
+
+	width := int(widthInMbs+1) * 16
+	height := int(2-frameMbsOnly) * int(heightInMapUnits+1) * 16
+	cropping, err := r.bit()
+	if err != nil {
+		return 0, 0, err
+	}
+	if cropping == 1 {
+		var crop [4]uint
+		for i := range crop {
+			if crop[i], err = r.ue(); err != nil {
+				return 0, 0, err
+			}
+		}
+		cropX, cropY := 1, 2-int(frameMbsOnly)
+		switch chromaFormat {
+		case 1:
+			cropX, cropY = 2, 2*(2-int(frameMbsOnly))
+		case 2:
+			cropX = 2
+		}
+		width -= cropX * int(crop[0]+crop[1])
+		height -= cropY * int(crop[2]+crop[3])
+	}
+	return width, height, nil
+}
+
+// skipScalingList skips a scaling list in an H.264 sequence parameter set
+func skipScalingList(r *bitReader, size int) error {
+	last, next := 8, 8
+	for i := 0; i < size; i++ {
+		if next != 0 {
+			delta, err := r.se()
+			if err != nil {
+				return err
+			}
+			next = (last + delta + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+	return nil
+}
+
+// h265Resolution parses an H.265 sequence parameter set, after the NAL
+// header, for the cropped picture size
+func h265Resolution(sps []byte) (int, int, error) {
+	r := &bitReader{data: sps}
+	// sps_video_parameter_set_id
+	if err := r.skip(4); err != nil {
+		return 0, 0, err
+	}
+	maxSubLayers, err := r.bits(3)
+	if err != nil {
+		return 0, 0, err
+	}
+	// sps_temporal_id_nesting_flag, then the general profile, tier and level
+	if err := r.skip(1 + 88 + 8); err != nil {
+		return 0, 0, err
+	}
+	profilePresent := make([]uint, maxSubLayers)
+	levelPresent := make([]uint, maxSubLayers)
+	for i := uint(0); i < maxSubLayers; i++ {
+		if profilePresent[i], err = r.bit(); err != nil {
+			return 0, 0, err
+		}
+		if levelPresent[i], err = r.bit(); err != nil {
+			return 0, 0, err
+		}
+	}
+	if maxSubLayers > 0 {
+		if err := r.skip(2 * (8 - int(maxSubLayers))); err != nil {
+			return 0, 0, err
+		}
+	}
+	for i := uint(0); i < maxSubLayers; i++ {
+		if profilePresent[i] == 1 {
+			if err := r.skip(88); err != nil {
+				return 0, 0, err
+			}
+		}
+		if levelPresent[i] == 1 {
+			if err := r.skip(8); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	// sps_seq_parameter_set_id
+	if _, err := r.ue(); err != nil {
+		return 0, 0, err
+	}
+	chromaFormat, err := r.ue()
+	if err != nil {
+		return 0, 0, err
+	}
+	if chromaFormat == 3 {
+		if err := r.skip(1); err != nil {
+			return 0, 0, err
+		}
+	}
+	width, err := r.ue()
+	if err != nil {
+		return 0, 0, err
+	}
+	height, err := r.ue()
+	if err != nil {
+		return 0, 0, err
+	}
+	window, err := r.bit()
+	if err != nil {
+		return 0, 0, err
+	}
+	w, h := int(width), int(height)
+	if window == 1 {
+		var offsets [4]uint
+		for i := range offsets {
+			if offsets[i], err = r.ue(); err != nil {
+				return 0, 0, err
+			}
+		}
+		subX, subY := 1, 1
+		switch chromaFormat {
+		case 1:
+			subX, subY = 2, 2
+		case 2:
+			subX = 2
+		}
+		w -= subX * int(offsets[0]+offsets[1])
+		h -= subY * int(offsets[2]+offsets[3])
+	}
+	return w, h, nil
+}
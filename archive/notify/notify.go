@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// Kinds of events
+const (
+	// Stalled is raised when the recorder stops adding segments
+	Stalled = "stalled"
+	// Recovered is raised when a stalled recorder adds segments again
+	Recovered = "recovered"
+)
+
+// Event is a change in the recorder's health
+type Event struct {
+	Kind string    `json:"event"`
+	Time time.Time `json:"time"`
+	// Newest is the PROGRAM-DATE-TIME of the newest segment in the recorder
+	// playlist, zero if there never was one
+	Newest time.Time `json:"newest_segment"`
+	// Age is how long ago Newest was when the event was raised
+	Age time.Duration `json:"-"`
+}
+
+// Message describes the event for people
+func (e Event) Message() string {
+	if e.Kind == Recovered {
+		return fmt.Sprintf("Recorder recovered: newest segment is %s old", e.Age.Round(time.Second))
+	}
+	if e.Newest.IsZero() {
+		return "Recorder stalled: no segments in the live playlist"
+	}
+	return fmt.Sprintf("Recorder stalled: no new segments since %s (%s ago)",
+		e.Newest.UTC().Format(time.RFC3339), e.Age.Round(time.Second))
+}
+
+// Notifier delivers events somewhere people will see them
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// Log writes events to a logger, or the default logger if it has none.
+// Stalls are warnings and recoveries are informational.
+type Log struct {
+	Logger *slog.Logger
+}
+
+// Notify logs the event
+func (l Log) Notify(ctx context.Context, event Event) error {
+	logger := l.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	level := slog.LevelWarn
+	if event.Kind == Recovered {
+		level = slog.LevelInfo
+	}
+	logger.Log(ctx, level, event.Message(), "event", event.Kind, "newest_segment", event.Newest,
+		"age_seconds", int(event.Age.Seconds()))
+	return nil
+}
+
+// Webhook posts events as JSON to a URL. The text field holds the message,
+// which chat services such as Slack show as is.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify posts the event
+func (w Webhook) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(struct {
+		Event
+		Text       string  `json:"text"`
+		AgeSeconds float64 `json:"age_seconds"`
+	}{event, event.Message(), event.Age.Seconds()})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to post to webhook: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", response.Status)
+	}
+	return nil
+}
+
+// Exec runs a shell command for every event, with the event in the
+// STREAM_EVENT, STREAM_NEWEST_SEGMENT, STREAM_AGE_SECONDS and
+// STREAM_MESSAGE environment variables
+type Exec struct {
+	Command string
+}
+
+// Notify runs the command
+func (e Exec) Notify(ctx context.Context, event Event) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", e.Command)
+	newest := ""
+	if !event.Newest.IsZero() {
+		newest = event.Newest.UTC().Format(time.RFC3339)
+	}
+	cmd.Env = append(os.Environ(),
+		"STREAM_EVENT="+event.Kind,
+		"STREAM_NEWEST_SEGMENT="+newest,
+		"STREAM_AGE_SECONDS="+strconv.Itoa(int(event.Age.Seconds())),
+		"STREAM_MESSAGE="+event.Message(),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to run %q: %w: %s", e.Command, err, output)
+	}
+	return nil
+}
+
+// Multi delivers events to every notifier, even if some fail
+type Multi []Notifier
+
+// Notify delivers the event to every notifier and joins their errors
+func (m Multi) Notify(ctx context.Context, event Event) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var stalled = Event{
+	Kind:   Stalled,
+	Time:   time.Date(2025, 3, 1, 18, 5, 0, 0, time.UTC),
+	Newest: time.Date(2025, 3, 1, 18, 2, 0, 0, time.UTC),
+	Age:    3 * time.Minute,
+}
+
+func TestWebhook(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode body: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	if err := (Webhook{URL: server.URL}).Notify(context.Background(), stalled); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if body["event"] != Stalled || body["age_seconds"] != 180.0 || body["newest_segment"] != "2025-03-01T18:02:00Z" {
+		t.Errorf("Body = %v, want the stalled event", body)
+	}
+	if text, _ := body["text"].(string); !strings.Contains(text, "stalled") {
+		t.Errorf("text = %q, want the message", text)
+	}
+}
+
+func TestWebhook_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	if err := (Webhook{URL: server.URL}).Notify(context.Background(), stalled); err == nil {
+		t.Error("Notify() error = nil, want an error")
+	}
+}
+
+func TestExec(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "event")
+	command := Exec{Command: `echo "$STREAM_EVENT $STREAM_NEWEST_SEGMENT $STREAM_AGE_SECONDS" > ` + out}
+
+	if err := command.Notify(context.Background(), stalled); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	written, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read command output: %v", err)
+	}
+	if got, want := string(written), "stalled 2025-03-01T18:02:00Z 180\n"; got != want {
+		t.Errorf("Output = %q, want %q", got, want)
+	}
+}
+
+func TestMulti(t *testing.T) {
+	var delivered int
+	counting := notifierFunc(func(ctx context.Context, event Event) error {
+		delivered++
+		return nil
+	})
+	failing := notifierFunc(func(ctx context.Context, event Event) error {
+		return errors.New("unreachable")
+	})
+
+	err := Multi{failing, counting, failing}.Notify(context.Background(), stalled)
+	if err == nil || delivered != 1 {
+		t.Errorf("Notify() error = %v, delivered = %d, want an error and 1 delivery", err, delivered)
+	}
+}
+
+type notifierFunc func(ctx context.Context, event Event) error
+
+func (f notifierFunc) Notify(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
@@ -0,0 +1,164 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// Filesystem stores objects as files under a base directory
+type Filesystem struct {
+	basePath string
+}
+
+// NewFilesystem creates a new Filesystem rooted at basePath
+func NewFilesystem(basePath string) *Filesystem {
+	return &Filesystem{
+		basePath: basePath,
+	}
+}
+
+// Get opens the file for an object
+func (f *Filesystem) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	filePath, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, notExist(err)
+	}
+	return file, nil
+}
+
+// GetRange opens the file for an object positioned at offset
+func (f *Filesystem) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	filePath, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, notExist(err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(file, offset, length), file}, nil
+}
+
+// Put writes an object to a temporary file and renames it into place, so
+// readers never see a partly written object
+func (f *Filesystem) Put(ctx context.Context, key string, content io.Reader) error {
+	filePath, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filePath)
+}
+
+// Stat describes the file for an object
+func (f *Filesystem) Stat(ctx context.Context, key string) (Info, error) {
+	filePath, err := f.path(key)
+	if err != nil {
+		return Info{}, err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return Info{}, notExist(err)
+	}
+	key, _ = cleanKey(key)
+	return Info{Key: key, Size: info.Size(), ModTime: info.ModTime(), IsDir: info.IsDir()}, nil
+}
+
+// List describes the files and directories in a directory. Temporary files
+// left by Put are skipped.
+func (f *Filesystem) List(ctx context.Context, dir string) ([]Info, error) {
+	dirPath, err := f.path(dir)
+	if err != nil {
+		return nil, err
+	}
+	dir, _ = cleanKey(dir)
+
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	infos := []Info{}
+	for _, entry := range entries {
+		if entry.Name()[0] == '.' {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		infos = append(infos, Info{
+			Key:     path.Join(dir, entry.Name()),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			IsDir:   entry.IsDir(),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+// Delete removes the file for an object
+func (f *Filesystem) Delete(ctx context.Context, key string) error {
+	filePath, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	return notExist(os.Remove(filePath))
+}
+
+// path returns the file path for a key
+func (f *Filesystem) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(f.basePath, filepath.FromSlash(key)), nil
+}
+
+// notExist converts a missing file error into ErrNotExist
+func notExist(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotExist
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory stores objects in memory. It is meant for tests.
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data    []byte
+	modTime time.Time
+}
+
+// NewMemory creates a new, empty Memory store
+func NewMemory() *Memory {
+	return &Memory{
+		objects: make(map[string]memoryObject),
+	}
+}
+
+// Get reads an object
+func (m *Memory) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := m.object(key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(object.data)), nil
+}
+
+// GetRange reads part of an object
+func (m *Memory) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	object, err := m.object(key)
+	if err != nil {
+		return nil, err
+	}
+	reader := io.NewSectionReader(bytes.NewReader(object.data), offset, length)
+	return io.NopCloser(reader), nil
+}
+
+// Put writes an object
+func (m *Memory) Put(ctx context.Context, key string, content io.Reader) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = memoryObject{data: data, modTime: time.Now()}
+	return nil
+}
+
+// Stat describes an object or directory
+func (m *Memory) Stat(ctx context.Context, key string) (Info, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return Info{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if object, found := m.objects[key]; found {
+		return Info{Key: key, Size: int64(len(object.data)), ModTime: object.modTime}, nil
+	}
+	for existing := range m.objects {
+		if strings.HasPrefix(existing, key+"/") {
+			return Info{Key: key, IsDir: true}, nil
+		}
+	}
+	return Info{}, ErrNotExist
+}
+
+// List describes the objects and directories directly inside a directory
+func (m *Memory) List(ctx context.Context, dir string) ([]Info, error) {
+	dir, err := cleanKey(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix := dir + "/"
+	if dir == "" {
+		prefix = ""
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	infos := []Info{}
+	seenDirs := make(map[string]bool)
+	for key, object := range m.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		rest := key[len(prefix):]
+		if slash := strings.Index(rest, "/"); slash >= 0 {
+			subdir := prefix + rest[:slash]
+			if !seenDirs[subdir] {
+				seenDirs[subdir] = true
+				infos = append(infos, Info{Key: subdir, IsDir: true})
+			}
+			continue
+		}
+		infos = append(infos, Info{Key: key, Size: int64(len(object.data)), ModTime: object.modTime})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+// Delete removes an object
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, found := m.objects[key]; !found {
+		return ErrNotExist
+	}
+	delete(m.objects, key)
+	return nil
+}
+
+// object returns the object stored under a key
+func (m *Memory) object(key string) (memoryObject, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return memoryObject{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	object, found := m.objects[key]
+	if !found {
+		return memoryObject{}, ErrNotExist
+	}
+	return object, nil
+}
@@ -0,0 +1,171 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNotExist is returned when an object does not exist
+var ErrNotExist = errors.New("object does not exist")
+
+// Info describes an object, or a directory of objects, in a Store
+type Info struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// Name returns the last element of the key
+func (i Info) Name() string {
+	return i.Key[strings.LastIndex(i.Key, "/")+1:]
+}
+
+// Store holds archive objects under slash-separated keys such as
+// 2025/04/11/00/playlist.m3u8. Directories are implied by the keys of the
+// objects inside them.
+type Store interface {
+	// Get reads an object
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// GetRange reads length bytes of an object starting at offset
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+
+	// Put writes an object, replacing any object with the same key
+	Put(ctx context.Context, key string, content io.Reader) error
+
+	// Stat describes an object
+	Stat(ctx context.Context, key string) (Info, error)
+
+	// List describes the objects and directories directly inside a
+	// directory, sorted by key. The root directory is the empty string.
+	List(ctx context.Context, dir string) ([]Info, error)
+
+	// Delete removes an object
+	Delete(ctx context.Context, key string) error
+}
+
+// Open returns the Store at a location. A location is a local directory
+// (/archive or file:///archive), mem:// for a store that lives in memory, or
+// s3://bucket/prefix for an S3-compatible bucket. S3 locations take their
+// endpoint and region from the endpoint and region query parameters, such as
+// s3://archive?endpoint=http://minio:9000&region=us-east-1, and their
+// credentials from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+func Open(location string) (Store, error) {
+	if !strings.Contains(location, "://") {
+		return NewFilesystem(location), nil
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store location %q: %w", location, err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		return NewFilesystem(u.Path), nil
+	case "mem":
+		return NewMemory(), nil
+	case "s3":
+		config := S3Config{
+			Endpoint:        u.Query().Get("endpoint"),
+			Region:          u.Query().Get("region"),
+			Bucket:          u.Host,
+			Prefix:          strings.Trim(u.Path, "/"),
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		}
+		if config.Endpoint == "" {
+			config.Endpoint = "https://s3.amazonaws.com"
+		}
+		if config.Region == "" {
+			config.Region = "us-east-1"
+		}
+		return NewS3(config), nil
+	default:
+		return nil, fmt.Errorf("unsupported store location %q", location)
+	}
+}
+
+// NewReadSeeker returns a reader over an object that supports seeking by
+// reading ranges of it on demand. It can be passed to http.ServeContent to
+// serve range requests.
+func NewReadSeeker(ctx context.Context, store Store, info Info) io.ReadSeekCloser {
+	return &readSeeker{ctx: ctx, store: store, info: info}
+}
+
+type readSeeker struct {
+	ctx    context.Context
+	store  Store
+	info   Info
+	offset int64
+	body   io.ReadCloser
+}
+
+func (r *readSeeker) Read(p []byte) (int, error) {
+	if r.offset >= r.info.Size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.store.GetRange(r.ctx, r.info.Key, r.offset, r.info.Size-r.offset)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.info.Size {
+		// The object shrank after it was described
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *readSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.info.Size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if offset != r.offset {
+		r.Close()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+func (r *readSeeker) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
+
+// cleanKey validates a key and strips any leading or trailing slashes
+func cleanKey(key string) (string, error) {
+	key = strings.Trim(key, "/")
+	for _, element := range strings.Split(key, "/") {
+		if element == "." || element == ".." {
+			return "", fmt.Errorf("invalid key %q", key)
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,207 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestFilesystem(t *testing.T) {
+	testStore(t, NewFilesystem(t.TempDir()))
+}
+
+func TestS3(t *testing.T) {
+	server := httptest.NewServer(newFakeS3("archive"))
+	defer server.Close()
+
+	testStore(t, NewS3(S3Config{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "archive",
+		Prefix:          "court6",
+		AccessKeyID:     "minio",
+		SecretAccessKey: "minio123",
+	}))
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		location string
+		expected string
+	}{
+		{"/archive", "*objectstore.Filesystem"},
+		{"file:///archive", "*objectstore.Filesystem"},
+		{"mem://", "*objectstore.Memory"},
+		{"s3://archive/court6?endpoint=http://minio:9000", "*objectstore.S3"},
+	}
+
+	for _, tt := range tests {
+		store, err := Open(tt.location)
+		if err != nil {
+			t.Errorf("Open(%s) failed: %v", tt.location, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", store); got != tt.expected {
+			t.Errorf("Open(%s) = %s, want %s", tt.location, got, tt.expected)
+		}
+	}
+
+	if _, err := Open("ftp://archive"); err == nil {
+		t.Error("Expected an error for an unsupported scheme")
+	}
+}
+
+func TestOpen_S3Config(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "minio")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "minio123")
+
+	store, err := Open("s3://archive/court6/?endpoint=http://minio:9000&region=eu-west-2")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	config := store.(*S3).config
+	if config.Bucket != "archive" || config.Prefix != "court6" || config.Endpoint != "http://minio:9000" ||
+		config.Region != "eu-west-2" || config.AccessKeyID != "minio" || config.SecretAccessKey != "minio123" {
+		t.Errorf("Unexpected config %+v", config)
+	}
+}
+
+func TestNewReadSeeker(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemory()
+	if err := store.Put(ctx, "a/b.ts", strings.NewReader("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := store.Stat(ctx, "a/b.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewReadSeeker(ctx, store, info)
+	defer reader.Close()
+	if _, err := reader.Seek(-4, io.SeekEnd); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if string(data) != "6789" {
+		t.Errorf("Content = %q, want %q", data, "6789")
+	}
+}
+
+// testStore checks the behaviour every Store implementation shares
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	objects := map[string]string{
+		"2025/04/11/00/playlist.m3u8":  "#EXTM3U\n",
+		"2025/04/11/00/segment_000.ts": "segment zero",
+		"2025/04/11/01/playlist.m3u8":  "#EXTM3U\n",
+		"2025/04/12/09/playlist.m3u8":  "#EXTM3U\n",
+	}
+	for key, content := range objects {
+		if err := store.Put(ctx, key, strings.NewReader(content)); err != nil {
+			t.Fatalf("Put(%s) failed: %v", key, err)
+		}
+	}
+
+	// Get
+	body, err := store.Get(ctx, "2025/04/11/00/segment_000.ts")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "segment zero" {
+		t.Errorf("Get = %q, want %q", data, "segment zero")
+	}
+	if _, err := store.Get(ctx, "2025/04/11/00/missing.ts"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Get of a missing object = %v, want ErrNotExist", err)
+	}
+
+	// GetRange
+	body, err = store.GetRange(ctx, "2025/04/11/00/segment_000.ts", 8, 4)
+	if err != nil {
+		t.Fatalf("GetRange failed: %v", err)
+	}
+	data, _ = io.ReadAll(body)
+	body.Close()
+	if string(data) != "zero" {
+		t.Errorf("GetRange = %q, want %q", data, "zero")
+	}
+
+	// Put replaces
+	if err := store.Put(ctx, "2025/04/11/00/playlist.m3u8", strings.NewReader("#EXTM3U\n#EXT-X-VERSION:3\n")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// Stat
+	info, err := store.Stat(ctx, "2025/04/11/00/playlist.m3u8")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Key != "2025/04/11/00/playlist.m3u8" || info.Size != 25 || info.IsDir || info.Name() != "playlist.m3u8" {
+		t.Errorf("Stat = %+v", info)
+	}
+	info, err = store.Stat(ctx, "2025/04/11")
+	if err != nil || !info.IsDir {
+		t.Errorf("Stat of a directory = %+v, %v", info, err)
+	}
+	if _, err := store.Stat(ctx, "2025/05"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat of a missing object = %v, want ErrNotExist", err)
+	}
+
+	// List
+	assertList(t, store, "", "2025/")
+	assertList(t, store, "2025/04", "2025/04/11/", "2025/04/12/")
+	assertList(t, store, "2025/04/11/00", "2025/04/11/00/playlist.m3u8", "2025/04/11/00/segment_000.ts")
+	assertList(t, store, "2026")
+
+	// Delete
+	if err := store.Delete(ctx, "2025/04/11/00/segment_000.ts"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Stat(ctx, "2025/04/11/00/segment_000.ts"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat after Delete = %v, want ErrNotExist", err)
+	}
+	if err := store.Delete(ctx, "2025/04/11/00/segment_000.ts"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Delete of a missing object = %v, want ErrNotExist", err)
+	}
+
+	// Keys may not escape the store
+	if err := store.Put(ctx, "../outside", strings.NewReader("")); err == nil {
+		t.Error("Expected an error for a key outside the store")
+	}
+}
+
+// assertList checks the keys a directory lists, with directories marked by a
+// trailing slash
+func assertList(t *testing.T, store Store, dir string, expected ...string) {
+	t.Helper()
+	infos, err := store.List(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("List(%s) failed: %v", dir, err)
+	}
+	var keys []string
+	for _, info := range infos {
+		key := info.Key
+		if info.IsDir {
+			key += "/"
+		}
+		keys = append(keys, key)
+	}
+	if strings.Join(keys, ",") != strings.Join(expected, ",") {
+		t.Errorf("List(%s) = %v, want %v", dir, keys, expected)
+	}
+}
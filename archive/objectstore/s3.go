@@ -0,0 +1,350 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Config configures an S3-compatible store
+type S3Config struct {
+	// Endpoint is the base URL of the service, such as http://minio:9000
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to every key, so several archives can share a bucket
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// Client is the HTTP client used for requests, defaulting to
+	// http.DefaultClient
+	Client *http.Client
+}
+
+// S3 stores objects in an S3-compatible bucket, such as MinIO or AWS S3. It
+// uses path-style requests signed with AWS Signature Version 4.
+type S3 struct {
+	config S3Config
+}
+
+// NewS3 creates a new S3 store
+func NewS3(config S3Config) *S3 {
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	config.Prefix = strings.Trim(config.Prefix, "/")
+	return &S3{
+		config: config,
+	}
+}
+
+// Get reads an object
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	response, err := s.do(ctx, http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+// GetRange reads part of an object with a range request
+func (s *S3) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	if length <= 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	response, err := s.do(ctx, http.MethodGet, key, nil, header, nil)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+// Put uploads an object. The content is buffered so its length is known.
+func (s *S3) Put(ctx context.Context, key string, content io.Reader) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	response, err := s.do(ctx, http.MethodPut, key, nil, nil, data)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// Stat describes an object with a HEAD request. Directories are not objects
+// in S3, so a key that is only a directory is reported as a directory when
+// it has objects inside it.
+func (s *S3) Stat(ctx context.Context, key string) (Info, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return Info{}, err
+	}
+
+	response, err := s.do(ctx, http.MethodHead, key, nil, nil, nil)
+	if errors.Is(err, ErrNotExist) {
+		infos, listErr := s.List(ctx, key)
+		if listErr == nil && len(infos) > 0 {
+			return Info{Key: key, IsDir: true}, nil
+		}
+		return Info{}, err
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	response.Body.Close()
+
+	modTime, _ := http.ParseTime(response.Header.Get("Last-Modified"))
+	return Info{Key: key, Size: response.ContentLength, ModTime: modTime}, nil
+}
+
+// List describes the objects and directories directly inside a directory
+// using ListObjectsV2 with a delimiter
+func (s *S3) List(ctx context.Context, dir string) ([]Info, error) {
+	dir, err := cleanKey(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix := s.objectKey(dir)
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	infos := []Info{}
+	continuationToken := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("delimiter", "/")
+		query.Set("prefix", prefix)
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		response, err := s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+			CommonPrefixes []struct {
+				Prefix string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(response.Body).Decode(&result)
+		response.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode bucket listing: %w", err)
+		}
+
+		for _, content := range result.Contents {
+			infos = append(infos, Info{
+				Key:     path.Join(dir, strings.TrimPrefix(content.Key, prefix)),
+				Size:    content.Size,
+				ModTime: content.LastModified,
+			})
+		}
+		for _, commonPrefix := range result.CommonPrefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(commonPrefix.Prefix, prefix), "/")
+			infos = append(infos, Info{Key: path.Join(dir, name), IsDir: true})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		continuationToken = result.NextContinuationToken
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+// Delete removes an object
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if _, err := s.Stat(ctx, key); err != nil {
+		return err
+	}
+	response, err := s.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// objectKey returns the key of an object in the bucket
+func (s *S3) objectKey(key string) string {
+	if s.config.Prefix == "" {
+		return key
+	}
+	if key == "" {
+		return s.config.Prefix
+	}
+	return s.config.Prefix + "/" + key
+}
+
+// do sends a signed request for an object, or for the bucket itself when key
+// is empty, and returns the response if it succeeded
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	uriPath := "/" + s.config.Bucket
+	if key != "" {
+		uriPath += "/" + s.objectKey(key)
+	}
+	requestURL := s.config.Endpoint + escapePath(uriPath)
+	if len(query) > 0 {
+		requestURL += "?" + canonicalQuery(query)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	request.ContentLength = int64(len(body))
+	s.sign(request, body, time.Now().UTC())
+
+	response, err := s.config.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, ErrNotExist
+	}
+	if response.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		response.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", method, uriPath, response.Status, strings.TrimSpace(string(message)))
+	}
+	return response, nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to a request
+func (s *S3) sign(request *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if request.Header.Get("Range") != "" {
+		signedHeaders = append(signedHeaders, "range")
+		sort.Strings(signedHeaders)
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := request.Header.Get(name)
+		if name == "host" {
+			value = request.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretAccessKey), date)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapePath URI-encodes every element of a slash-separated path the way
+// Signature Version 4 expects
+func escapePath(p string) string {
+	elements := strings.Split(p, "/")
+	for i, element := range elements {
+		elements[i] = escape(element)
+	}
+	return strings.Join(elements, "/")
+}
+
+// canonicalQuery encodes query parameters sorted by name, as Signature
+// Version 4 expects
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, escape(name)+"="+escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escape percent-encodes everything except the RFC 3986 unreserved
+// characters
+func escape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			sb.WriteByte(c)
+			continue
+		}
+		sb.WriteString("%" + strings.ToUpper(strconv.FormatInt(int64(c)|0x100, 16)[1:]))
+	}
+	return sb.String()
+}
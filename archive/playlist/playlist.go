@@ -4,17 +4,74 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"log/slog"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 )
 
+const (
+	// IFramesVersion is the playlist version that introduced
+	// EXT-X-I-FRAMES-ONLY and byte ranges
+	IFramesVersion = 4
+	// IFramesMapVersion is the playlist version that introduced EXT-X-MAP
+	// in I-frame playlists
+	IFramesMapVersion = 5
+	// GapVersion is the playlist version that introduced EXT-X-GAP
+	GapVersion = 8
+	// DateTimeLayout is how PROGRAM-DATE-TIME and date range times are
+	// written, to the millisecond like ffmpeg's
+	DateTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
 // Segment represents a single segment in an HLS playlist
 type Segment struct {
 	Filename        string
 	DateTime        time.Time
 	ProgramDateTime string
 	Duration        float64
+	// Discontinuity is set when the segment does not continue the one
+	// before it, such as after the recorder restarted
+	Discontinuity bool
+	// DateRanges are EXT-X-DATERANGE tags written before the segment
+	DateRanges []DateRange
+	// Gap is set for a placeholder covering time without footage, which
+	// players skip over without loading it
+	Gap bool
+	// ByteRange is the part of the file the segment is, if it isn't the
+	// whole file
+	ByteRange *ByteRange
+	// Map is the initialization section players need before the segment,
+	// which is written as EXT-X-MAP where it changes
+	Map *Map
+}
+
+// Map is an EXT-X-MAP tag: the part of a file, such as the program tables
+// at the start of a transport stream, players need to decode the segments
+// after it
+type Map struct {
+	URI       string
+	ByteRange *ByteRange
+}
+
+// ByteRange is an EXT-X-BYTERANGE tag: Length bytes starting Offset bytes
+// into a file
+type ByteRange struct {
+	Length int64
+	Offset int64
+}
+
+// DateRange is an EXT-X-DATERANGE tag, which marks a stretch of time in the
+// playlist such as a gap in the recording
+type DateRange struct {
+	ID    string
+	Class string
+	Start time.Time
+	End   time.Time
+	// Attributes are client attributes, whose names start with X-, written
+	// as quoted strings
+	Attributes map[string]string
 }
 
 // Playlist represents a complete HLS playlist
@@ -22,11 +79,30 @@ type Playlist struct {
 	Version        int
 	TargetDuration int
 	MediaSequence  int
-	Segments       []Segment
+	// IFramesOnly is set for playlists whose segments are single keyframes,
+	// for fast forward and scrubbing
+	IFramesOnly bool
+	Segments    []Segment
 }
 
-// Parse reads an HLS playlist from a reader and returns a Playlist struct
+// Parser parses HLS playlists, logging segments it has to skip
+type Parser struct {
+	logger *slog.Logger
+}
+
+// NewParser returns a parser that logs to logger
+func NewParser(logger *slog.Logger) *Parser {
+	return &Parser{logger: logger}
+}
+
+// Parse reads an HLS playlist from a reader with a parser that logs to the
+// default logger
 func Parse(reader io.Reader) (*Playlist, error) {
+	return NewParser(slog.Default()).Parse(reader)
+}
+
+// Parse reads an HLS playlist from a reader and returns a Playlist struct
+func (p *Parser) Parse(reader io.Reader) (*Playlist, error) {
 	playlist := &Playlist{
 		Version:        3,
 		TargetDuration: 10,
@@ -48,10 +124,12 @@ func Parse(reader io.Reader) (*Playlist, error) {
 		return nil, fmt.Errorf("error scanning playlist: %w", err)
 	}
 
-	fmt.Printf("Parsing playlist with %d lines\n", len(lines))
-	for i, line := range lines {
-		fmt.Printf("Line %d: %s\n", i, line)
-	}
+	// Tags that belong to the next segment
+	var discontinuity, gap bool
+	var dateRanges []DateRange
+	var byteRange *ByteRange
+	// EXT-X-MAP applies to every segment after it, until the next one
+	var initMap *Map
 
 	// Process the lines
 	for i := 0; i < len(lines); i++ {
@@ -59,20 +137,43 @@ func Parse(reader io.Reader) (*Playlist, error) {
 
 		// Handle playlist headers
 		switch {
+		case line == "#EXT-X-DISCONTINUITY":
+			discontinuity = true
+		case line == "#EXT-X-GAP":
+			gap = true
+		case line == "#EXT-X-I-FRAMES-ONLY":
+			playlist.IFramesOnly = true
+		case strings.HasPrefix(line, "#EXT-X-BYTERANGE:"):
+			parsed, err := parseByteRange(strings.TrimPrefix(line, "#EXT-X-BYTERANGE:"))
+			if err != nil {
+				p.logger.Warn("Skipping invalid byte range", "value", line, "error", err)
+				break
+			}
+			byteRange = &parsed
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			parsed, err := parseMap(strings.TrimPrefix(line, "#EXT-X-MAP:"))
+			if err != nil {
+				p.logger.Warn("Skipping invalid map", "value", line, "error", err)
+				break
+			}
+			initMap = &parsed
+		case strings.HasPrefix(line, "#EXT-X-DATERANGE:"):
+			dateRange, err := parseDateRange(strings.TrimPrefix(line, "#EXT-X-DATERANGE:"))
+			if err != nil {
+				p.logger.Warn("Skipping invalid date range", "value", line, "error", err)
+				break
+			}
+			dateRanges = append(dateRanges, dateRange)
 		case strings.HasPrefix(line, "#EXT-X-VERSION:"):
 			fmt.Sscanf(line, "#EXT-X-VERSION:%d", &playlist.Version)
-			fmt.Printf("Found version: %d\n", playlist.Version)
 		case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"):
 			fmt.Sscanf(line, "#EXT-X-TARGETDURATION:%d", &playlist.TargetDuration)
-			fmt.Printf("Found target duration: %d\n", playlist.TargetDuration)
 		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
 			fmt.Sscanf(line, "#EXT-X-MEDIA-SEQUENCE:%d", &playlist.MediaSequence)
-			fmt.Printf("Found media sequence: %d\n", playlist.MediaSequence)
 		}
 
 		// Look for segment information
 		if strings.HasSuffix(line, ".ts") {
-			fmt.Printf("Found segment: %s\n", line)
 			// This is a segment filename, look for its metadata in previous lines
 			var segment Segment
 			segment.Filename = line
@@ -81,7 +182,6 @@ func Parse(reader io.Reader) (*Playlist, error) {
 			for j := i - 1; j >= 0; j-- {
 				if durationRegex.MatchString(lines[j]) {
 					fmt.Sscanf(lines[j], "#EXTINF:%f,", &segment.Duration)
-					fmt.Printf("Found duration for %s: %f\n", line, segment.Duration)
 					break
 				}
 			}
@@ -98,28 +198,43 @@ func Parse(reader io.Reader) (*Playlist, error) {
 							// If that fails, try parsing with a custom format that handles +0000 timezone
 							segment.DateTime, err = time.Parse("2006-01-02T15:04:05.999-0700", matches[1])
 							if err != nil {
-								fmt.Printf("Failed to parse date time %s: %v\n", matches[1], err)
+								p.logger.Warn("Failed to parse program date time", "segment", line, "value", matches[1], "error", err)
 								continue
 							}
 						}
 						segment.ProgramDateTime = matches[1]
-						fmt.Printf("Found date time for %s: %s\n", line, segment.ProgramDateTime)
 					}
 					break
 				}
 			}
 
 			// Only add the segment if we have all the required information
+			// A skipped segment's tags are kept for the next one, so a
+			// discontinuity isn't lost with it
 			if !segment.DateTime.IsZero() && segment.Duration > 0 {
-				fmt.Printf("Adding segment to playlist: %s\n", line)
+				segment.Discontinuity, segment.DateRanges, segment.Gap = discontinuity, dateRanges, gap
+				discontinuity, dateRanges, gap = false, nil, false
+				if byteRange != nil && byteRange.Offset < 0 {
+					// Without an offset the range follows on from the
+					// previous one of the same file
+					byteRange.Offset = 0
+					if n := len(playlist.Segments); n > 0 && playlist.Segments[n-1].Filename == segment.Filename && playlist.Segments[n-1].ByteRange != nil {
+						previous := playlist.Segments[n-1].ByteRange
+						byteRange.Offset = previous.Offset + previous.Length
+					}
+				}
+				segment.ByteRange, byteRange = byteRange, nil
+				segment.Map = initMap
 				playlist.Segments = append(playlist.Segments, segment)
 			} else {
-				fmt.Printf("Skipping segment %s: DateTime=%v, Duration=%f\n", line, segment.DateTime, segment.Duration)
+				p.logger.Warn("Skipping segment without a program date time or duration",
+					"segment", line, "date_time", segment.DateTime, "duration", segment.Duration)
 			}
 		}
 	}
 
-	fmt.Printf("Parsed playlist with %d segments\n", len(playlist.Segments))
+	p.logger.Debug("Parsed playlist", "lines", len(lines), "segments", len(playlist.Segments),
+		"media_sequence", playlist.MediaSequence)
 	return playlist, nil
 }
 
@@ -131,9 +246,29 @@ func (p *Playlist) String() string {
 	sb.WriteString(fmt.Sprintf("#EXT-X-VERSION:%d\n", p.Version))
 	sb.WriteString(fmt.Sprintf("#EXT-X-TARGETDURATION:%d\n", p.TargetDuration))
 	sb.WriteString(fmt.Sprintf("#EXT-X-MEDIA-SEQUENCE:%d\n", p.MediaSequence))
+	if p.IFramesOnly {
+		sb.WriteString("#EXT-X-I-FRAMES-ONLY\n")
+	}
 
+	var initMap *Map
 	for _, segment := range p.Segments {
+		if segment.Map != nil && !segment.Map.equal(initMap) {
+			sb.WriteString("#EXT-X-MAP:" + segment.Map.String() + "\n")
+		}
+		initMap = segment.Map
+		for _, dateRange := range segment.DateRanges {
+			sb.WriteString("#EXT-X-DATERANGE:" + dateRange.String() + "\n")
+		}
+		if segment.Discontinuity {
+			sb.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		if segment.Gap {
+			sb.WriteString("#EXT-X-GAP\n")
+		}
 		sb.WriteString(fmt.Sprintf("#EXTINF:%.6f,\n", segment.Duration))
+		if segment.ByteRange != nil {
+			sb.WriteString(fmt.Sprintf("#EXT-X-BYTERANGE:%d@%d\n", segment.ByteRange.Length, segment.ByteRange.Offset))
+		}
 		if segment.ProgramDateTime != "" {
 			sb.WriteString(fmt.Sprintf("#EXT-X-PROGRAM-DATE-TIME:%s\n", segment.ProgramDateTime))
 		}
@@ -143,9 +278,189 @@ func (p *Playlist) String() string {
 	return sb.String()
 }
 
+// Variant is a rendition listed in a master playlist
+type Variant struct {
+	URI string
+	// Bandwidth is the peak bit rate, in bits per second
+	Bandwidth int64
+	// Width and Height are the picture size, if known
+	Width  int
+	Height int
+}
+
+// attributes returns the attribute list describing the variant, without
+// its URI
+func (v Variant) attributes() string {
+	attributes := fmt.Sprintf("BANDWIDTH=%d", v.Bandwidth)
+	if v.Width > 0 && v.Height > 0 {
+		attributes += fmt.Sprintf(",RESOLUTION=%dx%d", v.Width, v.Height)
+	}
+	return attributes
+}
+
+// Master represents an HLS master playlist, which lists the renditions of
+// the same footage
+type Master struct {
+	Version  int
+	Variants []Variant
+	// IFrameVariants are I-frame playlists, for fast forward and scrubbing
+	IFrameVariants []Variant
+}
+
+// String returns the HLS master playlist as a string
+func (m *Master) String() string {
+	var sb strings.Builder
+
+	sb.WriteString("#EXTM3U\n")
+	sb.WriteString(fmt.Sprintf("#EXT-X-VERSION:%d\n", m.Version))
+	for _, variant := range m.Variants {
+		sb.WriteString("#EXT-X-STREAM-INF:" + variant.attributes() + "\n")
+		sb.WriteString(variant.URI + "\n")
+	}
+	for _, variant := range m.IFrameVariants {
+		sb.WriteString(fmt.Sprintf("#EXT-X-I-FRAME-STREAM-INF:%s,URI=%q\n", variant.attributes(), variant.URI))
+	}
+
+	return sb.String()
+}
+
 // Concat returns a new playlist with the additional segment
 func Concat(playlist *Playlist, segment Segment) *Playlist {
 	newPlaylist := *playlist
 	newPlaylist.Segments = append(newPlaylist.Segments, segment)
 	return &newPlaylist
 }
+
+// String returns the attribute list of the EXT-X-MAP tag
+func (m Map) String() string {
+	attributes := fmt.Sprintf("URI=%q", m.URI)
+	if m.ByteRange != nil {
+		attributes += fmt.Sprintf(",BYTERANGE=\"%d@%d\"", m.ByteRange.Length, m.ByteRange.Offset)
+	}
+	return attributes
+}
+
+// equal reports whether two maps name the same part of the same file
+func (m *Map) equal(other *Map) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	if m.ByteRange == nil || other.ByteRange == nil {
+		return m.URI == other.URI && m.ByteRange == other.ByteRange
+	}
+	return m.URI == other.URI && *m.ByteRange == *other.ByteRange
+}
+
+// parseMap parses the attribute list of an EXT-X-MAP tag
+func parseMap(list string) (Map, error) {
+	attributes, err := parseAttributes(list)
+	if err != nil {
+		return Map{}, err
+	}
+	initMap := Map{URI: attributes["URI"]}
+	if initMap.URI == "" {
+		return Map{}, fmt.Errorf("no URI")
+	}
+	if value, found := attributes["BYTERANGE"]; found {
+		byteRange, err := parseByteRange(value)
+		if err != nil {
+			return Map{}, fmt.Errorf("invalid BYTERANGE: %w", err)
+		}
+		byteRange.Offset = max(byteRange.Offset, 0)
+		initMap.ByteRange = &byteRange
+	}
+	return initMap, nil
+}
+
+// parseByteRange parses the value of an EXT-X-BYTERANGE tag, a length and
+// an optional offset such as 1024@376. The offset is -1 if it is left out.
+func parseByteRange(value string) (ByteRange, error) {
+	length, offset, found := strings.Cut(value, "@")
+	byteRange := ByteRange{Offset: -1}
+	if _, err := fmt.Sscanf(length, "%d", &byteRange.Length); err != nil || byteRange.Length <= 0 {
+		return ByteRange{}, fmt.Errorf("invalid length %q", length)
+	}
+	if found {
+		if _, err := fmt.Sscanf(offset, "%d", &byteRange.Offset); err != nil || byteRange.Offset < 0 {
+			return ByteRange{}, fmt.Errorf("invalid offset %q", offset)
+		}
+	}
+	return byteRange, nil
+}
+
+// String returns the attribute list of the EXT-X-DATERANGE tag, with client
+// attributes in name order
+func (d DateRange) String() string {
+	attributes := []string{
+		fmt.Sprintf("ID=%q", d.ID),
+		fmt.Sprintf("CLASS=%q", d.Class),
+		fmt.Sprintf("START-DATE=%q", d.Start.Format(DateTimeLayout)),
+	}
+	if !d.End.IsZero() {
+		attributes = append(attributes, fmt.Sprintf("END-DATE=%q", d.End.Format(DateTimeLayout)))
+	}
+	names := make([]string, 0, len(d.Attributes))
+	for name := range d.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		attributes = append(attributes, fmt.Sprintf("%s=%q", name, d.Attributes[name]))
+	}
+	return strings.Join(attributes, ",")
+}
+
+// parseDateRange parses the attribute list of an EXT-X-DATERANGE tag
+func parseDateRange(list string) (DateRange, error) {
+	attributes, err := parseAttributes(list)
+	if err != nil {
+		return DateRange{}, err
+	}
+	dateRange := DateRange{ID: attributes["ID"], Class: attributes["CLASS"]}
+	if dateRange.ID == "" {
+		return DateRange{}, fmt.Errorf("no ID")
+	}
+	if dateRange.Start, err = time.Parse(time.RFC3339, attributes["START-DATE"]); err != nil {
+		return DateRange{}, fmt.Errorf("invalid START-DATE: %w", err)
+	}
+	if end, found := attributes["END-DATE"]; found {
+		if dateRange.End, err = time.Parse(time.RFC3339, end); err != nil {
+			return DateRange{}, fmt.Errorf("invalid END-DATE: %w", err)
+		}
+	}
+	for name, value := range attributes {
+		if strings.HasPrefix(name, "X-") {
+			if dateRange.Attributes == nil {
+				dateRange.Attributes = make(map[string]string)
+			}
+			dateRange.Attributes[name] = value
+		}
+	}
+	return dateRange, nil
+}
+
+// parseAttributes parses an attribute list such as ID="a",DURATION=10 into
+// values by name, without the quotes of quoted strings
+func parseAttributes(list string) (map[string]string, error) {
+	attributes := make(map[string]string)
+	for list != "" {
+		name, rest, found := strings.Cut(list, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("attribute without a value in %q", list)
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted string in %q", rest)
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+			rest = "," + rest
+		}
+		attributes[name] = value
+		list = strings.TrimPrefix(rest, ",")
+	}
+	return attributes, nil
+}
@@ -1,6 +1,8 @@
 package playlist
 
 import (
+	"bytes"
+	"log/slog"
 	"strings"
 	"testing"
 	"time"
@@ -208,3 +210,175 @@ segment_002.ts`
 		}
 	}
 }
+
+func TestParser_LogsSkippedSegments(t *testing.T) {
+	input := `#EXTM3U
+#EXTINF:10.0,
+segment_1.ts`
+
+	var out bytes.Buffer
+	parser := NewParser(slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	playlist, err := parser.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if len(playlist.Segments) != 0 {
+		t.Errorf("Segments = %v, want none", playlist.Segments)
+	}
+	if logged := out.String(); !strings.Contains(logged, "level=WARN") || !strings.Contains(logged, "segment=segment_1.ts") {
+		t.Errorf("Logged %q, want a warning about segment_1.ts", logged)
+	}
+}
+
+func TestParse_Discontinuity(t *testing.T) {
+	// Setup
+	input := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2024-04-10T23:58:00Z
+segment_000.ts
+#EXT-X-DATERANGE:ID="gap-1",CLASS="recording-gap",START-DATE="2024-04-10T23:58:10.000Z",END-DATE="2024-04-10T23:58:40.000Z",X-REASON="clock_gap,pts_jump"
+#EXT-X-DISCONTINUITY
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2024-04-10T23:58:40Z
+segment_001.ts
+`
+
+	// Execute
+	playlist, err := Parse(strings.NewReader(input))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(playlist.Segments) != 2 {
+		t.Fatalf("Expected 2 segments, got %d", len(playlist.Segments))
+	}
+	if first := playlist.Segments[0]; first.Discontinuity || len(first.DateRanges) != 0 {
+		t.Errorf("first segment = %+v, want no discontinuity", first)
+	}
+	second := playlist.Segments[1]
+	if !second.Discontinuity || len(second.DateRanges) != 1 {
+		t.Fatalf("second segment = %+v, want a discontinuity and a date range", second)
+	}
+	gap := second.DateRanges[0]
+	if gap.ID != "gap-1" || gap.Class != "recording-gap" || gap.Attributes["X-REASON"] != "clock_gap,pts_jump" {
+		t.Errorf("date range = %+v", gap)
+	}
+	if gap.End.Sub(gap.Start) != 30*time.Second {
+		t.Errorf("date range lasts %v, want 30s", gap.End.Sub(gap.Start))
+	}
+	if got := playlist.String(); got != input {
+		t.Errorf("String() =\n%s\nwant\n%s", got, input)
+	}
+}
+
+func TestParse_IFramesOnly(t *testing.T) {
+	// Setup
+	input := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-I-FRAMES-ONLY
+#EXTINF:2.000000,
+#EXT-X-BYTERANGE:9400@376
+#EXT-X-PROGRAM-DATE-TIME:2024-04-10T23:58:00Z
+segment_000.ts
+#EXTINF:2.000000,
+#EXT-X-BYTERANGE:7144
+#EXT-X-PROGRAM-DATE-TIME:2024-04-10T23:58:02Z
+segment_000.ts
+`
+
+	// Execute
+	playlist, err := Parse(strings.NewReader(input))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !playlist.IFramesOnly || len(playlist.Segments) != 2 {
+		t.Fatalf("IFramesOnly = %v with %d segments, want true with 2", playlist.IFramesOnly, len(playlist.Segments))
+	}
+	if got := *playlist.Segments[0].ByteRange; got != (ByteRange{Length: 9400, Offset: 376}) {
+		t.Errorf("first byte range = %+v, want 9400@376", got)
+	}
+	// A range without an offset follows on from the one before it
+	if got := *playlist.Segments[1].ByteRange; got != (ByteRange{Length: 7144, Offset: 9776}) {
+		t.Errorf("second byte range = %+v, want 7144@9776", got)
+	}
+	if got, want := playlist.String(), strings.Replace(input, "7144\n", "7144@9776\n", 1); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParse_Map(t *testing.T) {
+	// Setup
+	input := `#EXTM3U
+#EXT-X-VERSION:5
+#EXT-X-TARGETDURATION:2
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-I-FRAMES-ONLY
+#EXT-X-MAP:URI="segment_000.ts",BYTERANGE="376@0"
+#EXTINF:2.000000,
+#EXT-X-BYTERANGE:9400@376
+#EXT-X-PROGRAM-DATE-TIME:2024-04-10T23:58:00Z
+segment_000.ts
+#EXTINF:2.000000,
+#EXT-X-BYTERANGE:7144@9776
+#EXT-X-PROGRAM-DATE-TIME:2024-04-10T23:58:02Z
+segment_000.ts
+#EXT-X-MAP:URI="segment_001.ts",BYTERANGE="376@0"
+#EXTINF:2.000000,
+#EXT-X-BYTERANGE:9400@376
+#EXT-X-PROGRAM-DATE-TIME:2024-04-10T23:58:04Z
+segment_001.ts
+`
+
+	// Execute
+	playlist, err := Parse(strings.NewReader(input))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(playlist.Segments) != 3 {
+		t.Fatalf("got %d segments, want 3", len(playlist.Segments))
+	}
+	// A map applies to every segment after it until the next one
+	for i, want := range []string{"segment_000.ts", "segment_000.ts", "segment_001.ts"} {
+		got := playlist.Segments[i].Map
+		if got == nil || got.URI != want || *got.ByteRange != (ByteRange{Length: 376}) {
+			t.Errorf("segment %d map = %+v, want 376@0 of %s", i, got, want)
+		}
+	}
+	if got := playlist.String(); got != input {
+		t.Errorf("String() =\n%s\nwant\n%s", got, input)
+	}
+}
+
+func TestMaster_String(t *testing.T) {
+	// Setup
+	master := &Master{
+		Version:        4,
+		Variants:       []Variant{{URI: "playlist.m3u8", Bandwidth: 2500000, Width: 1920, Height: 1080}},
+		IFrameVariants: []Variant{{URI: "iframes.m3u8", Bandwidth: 180000}},
+	}
+
+	// Execute
+	got := master.String()
+
+	// Assert
+	want := `#EXTM3U
+#EXT-X-VERSION:4
+#EXT-X-STREAM-INF:BANDWIDTH=2500000,RESOLUTION=1920x1080
+playlist.m3u8
+#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=180000,URI="iframes.m3u8"
+`
+	if got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+}
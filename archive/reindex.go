@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"archive/archiverepo"
+	"archive/logging"
+)
+
+// runReindex rebuilds the playlists of every archived hour, or just one,
+// from the segments that are really there. It prints a JSON report for
+// each hour and exits with an error status if any hour failed.
+func runReindex(loggers *logging.Loggers, args []string) {
+	flags := flag.NewFlagSet("reindex", flag.ExitOnError)
+	output := flags.String("output", os.Getenv("OUTPUT_DIR"), "archive directory or object store location")
+	hour := flags.String("hour", "", "only reindex this hour directory, such as 2025/04/11/18")
+	dryRun := flags.Bool("dry-run", false, "report what would change without writing anything")
+	flags.Parse(args)
+
+	ctx := context.Background()
+	archiveRepo := newRepositories(loggers).archive(*output)
+	hours := []string{*hour}
+	if *hour == "" {
+		var err error
+		hours, err = archiveRepo.Hours(ctx)
+		if err != nil {
+			log.Fatalf("Error: Unable to list archived hours: %v\n", err)
+		}
+	}
+
+	logger := loggers.For("main")
+	reports := []archiverepo.ReindexReport{}
+	failed := false
+	for _, hour := range hours {
+		report, err := archiveRepo.Reindex(ctx, hour, *dryRun)
+		if err != nil {
+			failed = true
+			logger.Error("Failed to reindex hour", "hour", hour, "error", err)
+			continue
+		}
+		reports = append(reports, report)
+	}
+
+	printJSON(reports)
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"archive/app"
+	"archive/metrics"
+)
+
+// archiveStatus tracks how archiving is going, for the metrics and the
+// readiness check
+type archiveStatus struct {
+	interval     time.Duration
+	segments     *metrics.Counter
+	flagged      *metrics.Counter
+	restarts     *metrics.CounterVec
+	bytes        *metrics.Counter
+	failures     *metrics.CounterVec
+	lag          *metrics.Gauge
+	lastSuccess  *metrics.Gauge
+	tickDuration *metrics.HistogramVec
+
+	mu          sync.Mutex
+	lastTick    time.Time
+	lastOK      time.Time
+	liveEnd     time.Time
+	lastFailure error
+}
+
+// newArchiveStatus registers the archive metrics for ticks every interval
+func newArchiveStatus(registry *metrics.Registry, interval time.Duration) *archiveStatus {
+	s := &archiveStatus{
+		interval:     interval,
+		segments:     registry.Counter("archive_segments_archived_total", "Segments copied into the archive.").With(),
+		flagged:      registry.Counter("archive_flagged_segments_total", "Segments archived despite problems found inspecting them.").With(),
+		restarts:     registry.Counter("archive_discontinuities_total", "Discontinuities marked in the archive, by what gave them away.", "reason"),
+		bytes:        registry.Counter("archive_bytes_written_total", "Bytes of segments written to the archive.").With(),
+		failures:     registry.Counter("archive_errors_total", "Failed archive operations by type.", "type"),
+		lag:          registry.Gauge("archive_lag_seconds", "How far the archive is behind the live playlist.").With(),
+		lastSuccess:  registry.Gauge("archive_last_success_timestamp_seconds", "Unix time of the last tick without errors.").With(),
+		tickDuration: registry.Histogram("archive_tick_duration_seconds", "How long archive ticks take.", metrics.DefaultBuckets, "result"),
+	}
+	registry.GaugeFunc("archive_live_age_seconds", "Time since the newest live segment ended; grows when the recorder stalls.", s.liveAge)
+	return s
+}
+
+// record updates the metrics with the result of a tick
+func (s *archiveStatus) record(result app.ArchiveResult, started time.Time, duration time.Duration) {
+	s.segments.Add(float64(result.ArchivedSegments))
+	s.flagged.Add(float64(result.Flagged()))
+	s.bytes.Add(float64(result.BytesWritten))
+	for _, segment := range result.Segments {
+		if segment.Status != app.SegmentArchived {
+			continue
+		}
+		for _, reason := range segment.Discontinuity {
+			s.restarts.With(reason).Inc()
+		}
+	}
+	for kind, count := range result.Failures {
+		s.failures.With(kind).Add(float64(count))
+	}
+	s.lag.Set(result.Lag().Seconds())
+
+	outcome := "ok"
+	if result.Error != nil {
+		outcome = "error"
+	}
+	s.tickDuration.With(outcome).Observe(duration.Seconds())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastTick = started
+	if !result.LiveEnd.IsZero() {
+		s.liveEnd = result.LiveEnd
+	}
+	if result.Error != nil {
+		s.lastFailure = result.Error
+		return
+	}
+	s.lastOK = started
+	s.lastSuccess.Set(float64(started.Unix()))
+}
+
+// liveAge returns the seconds since the newest live segment ended
+func (s *archiveStatus) liveAge() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.liveEnd.IsZero() {
+		return 0
+	}
+	return time.Since(s.liveEnd).Seconds()
+}
+
+// ready fails until a tick has succeeded, and again when none has for
+// three intervals
+func (s *archiveStatus) ready(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastOK.IsZero() {
+		if s.lastFailure != nil {
+			return fmt.Errorf("no successful archive yet: %w", s.lastFailure)
+		}
+		return fmt.Errorf("no successful archive yet")
+	}
+	if since := time.Since(s.lastOK); since > 3*s.interval {
+		return fmt.Errorf("last successful archive was %s ago: %v", since.Round(time.Second), s.lastFailure)
+	}
+	return nil
+}
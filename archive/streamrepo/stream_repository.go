@@ -1,43 +1,158 @@
 package streamrepo
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"time"
 
 	"archive/playlist"
 )
 
+// ErrSegmentReplaced is returned for a segment whose file no longer holds
+// the footage the playlist listed it for, because ffmpeg reused its name
+var ErrSegmentReplaced = errors.New("segment file was replaced")
+
+const (
+	// segmentAttempts is how many times a segment that changes while it is
+	// read is read again
+	segmentAttempts = 3
+	// segmentRetryDelay is how long to wait before reading it again
+	segmentRetryDelay = 200 * time.Millisecond
+)
+
 // StreamRepository reads a video stream from the file system
 type StreamRepository struct {
-	basePath string
+	basePath     string
+	playlistName string
+	parser       *playlist.Parser
+	logger       *slog.Logger
+	// listedAt is when the playlist last read was written, and listed the
+	// segments it listed by filename
+	listedAt time.Time
+	listed   map[string]playlist.Segment
+	// settled is set by Settled
+	settled bool
 }
 
 // New creates a new StreamRepository
-func New(basePath string) *StreamRepository {
+func New(basePath string, parser *playlist.Parser, logger *slog.Logger) *StreamRepository {
 	return &StreamRepository{
-		basePath: basePath,
+		basePath:     basePath,
+		playlistName: "playlist.m3u8",
+		parser:       parser,
+		logger:       logger,
 	}
 }
 
+// WithPlaylist returns a repository that reads the playlist with another
+// name in the same directory, for ffmpeg output with several playlists
+func (g *StreamRepository) WithPlaylist(name string) *StreamRepository {
+	repo := *g
+	repo.playlistName = name
+	return &repo
+}
+
+// Settled returns a repository for a directory ffmpeg no longer writes to,
+// such as an old recording copied for a backfill. Its segments can't have
+// been replaced, and copying may not have kept their modification times, so
+// they aren't checked against the playlist's.
+func (g *StreamRepository) Settled() *StreamRepository {
+	repo := *g
+	repo.settled = true
+	return &repo
+}
+
 // GetPlaylist reads the playlist from the filesystem
 func (g *StreamRepository) GetPlaylist() (*playlist.Playlist, error) {
-	playlistPath := filepath.Join(g.basePath, "playlist.m3u8")
+	playlistPath := filepath.Join(g.basePath, g.playlistName)
 	file, err := os.Open(playlistPath)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
-	return playlist.Parse(file)
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	g.logger.Debug("Reading recorder playlist", "path", playlistPath)
+	recorderPlaylist, err := g.parser.Parse(file)
+	if err != nil {
+		return nil, err
+	}
+	g.listedAt = info.ModTime()
+	g.listed = make(map[string]playlist.Segment, len(recorderPlaylist.Segments))
+	for _, segment := range recorderPlaylist.Segments {
+		g.listed[segment.Filename] = segment
+	}
+	return recorderPlaylist, nil
 }
 
-// GetSegment reads a segment from the filesystem
+// GetSegment reads a segment from the filesystem. ffmpeg reuses segment
+// names, so the segment is read into memory and checked against the
+// playlist that listed it: a file written after that playlist has been
+// replaced, and a file that changes while it is read is read again. Either
+// way the error wraps ErrSegmentReplaced if the footage can't be had.
 func (g *StreamRepository) GetSegment(filename string) (io.ReadCloser, error) {
 	segmentPath := filepath.Join(g.basePath, filename)
+	g.logger.Debug("Reading segment", "path", segmentPath)
+	var err error
+	for attempt := 1; attempt <= segmentAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(segmentRetryDelay)
+		}
+		var data []byte
+		data, err = g.readSegment(segmentPath, filename)
+		if err == nil {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		if !errors.Is(err, errSegmentChanged) {
+			return nil, err
+		}
+		g.logger.Warn("Segment changed while it was read", "path", segmentPath, "attempt", attempt)
+	}
+	return nil, fmt.Errorf("%w: %s kept changing while it was read: %w", ErrSegmentReplaced, filename, err)
+}
+
+// errSegmentChanged is returned for a segment that changed while it was
+// read, which is worth reading again
+var errSegmentChanged = errors.New("segment changed while it was read")
+
+// readSegment reads a segment file, checking that it was written before the
+// playlist that listed it, unless the directory is settled, and that it
+// didn't change while it was read
+func (g *StreamRepository) readSegment(segmentPath, filename string) ([]byte, error) {
 	file, err := os.Open(segmentPath)
 	if err != nil {
 		return nil, err
 	}
-	return file, nil
+	defer file.Close()
+
+	before, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if entry, found := g.listed[filename]; found && !g.settled && before.ModTime().After(g.listedAt) {
+		return nil, fmt.Errorf("%w: %s was written at %s, after the playlist listing it for %s",
+			ErrSegmentReplaced, filename, before.ModTime().UTC().Format(time.RFC3339Nano), entry.DateTime.UTC().Format(time.RFC3339))
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	after, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != before.Size() || after.Size() != before.Size() || !after.ModTime().Equal(before.ModTime()) {
+		return nil, fmt.Errorf("%w: read %d bytes of a file of %d, now %d", errSegmentChanged, len(data), before.Size(), after.Size())
+	}
+	return data, nil
 }
@@ -0,0 +1,78 @@
+package streamrepo
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"archive/playlist"
+)
+
+func TestStreamRepository_GetSegment(t *testing.T) {
+	// Setup
+	dir := t.TempDir()
+	written := time.Date(2025, 4, 11, 18, 0, 10, 0, time.UTC)
+	files := map[string]string{
+		"playlist.m3u8": `#EXTM3U
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:00Z
+segment_000.ts
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:10Z
+segment_001.ts
+`,
+		"segment_000.ts": "first",
+		"segment_001.ts": "second",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		if err := os.Chtimes(path, written, written); err != nil {
+			t.Fatalf("Chtimes failed: %v", err)
+		}
+	}
+	// ffmpeg starts again and reuses the name of the second segment
+	reused := filepath.Join(dir, "segment_001.ts")
+	if err := os.Chtimes(reused, written.Add(time.Minute), written.Add(time.Minute)); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	repo := New(dir, playlist.NewParser(slog.Default()), slog.Default())
+
+	// Execute
+	if _, err := repo.GetPlaylist(); err != nil {
+		t.Fatalf("GetPlaylist failed: %v", err)
+	}
+	first, firstErr := repo.GetSegment("segment_000.ts")
+	_, secondErr := repo.GetSegment("segment_001.ts")
+	// A copied directory may not have kept the times it was written at
+	settled := repo.Settled()
+	if _, err := settled.GetPlaylist(); err != nil {
+		t.Fatalf("GetPlaylist failed: %v", err)
+	}
+	copied, copiedErr := settled.GetSegment("segment_001.ts")
+
+	// Assert
+	if firstErr != nil {
+		t.Fatalf("GetSegment(segment_000.ts) failed: %v", firstErr)
+	}
+	defer first.Close()
+	if content, _ := io.ReadAll(first); string(content) != "first" {
+		t.Errorf("segment_000.ts = %q, want %q", content, "first")
+	}
+	if !errors.Is(secondErr, ErrSegmentReplaced) {
+		t.Errorf("GetSegment(segment_001.ts) error = %v, want %v", secondErr, ErrSegmentReplaced)
+	}
+	if copiedErr != nil {
+		t.Fatalf("GetSegment(segment_001.ts) of a settled directory failed: %v", copiedErr)
+	}
+	defer copied.Close()
+	if content, _ := io.ReadAll(copied); string(content) != "second" {
+		t.Errorf("settled segment_001.ts = %q, want %q", content, "second")
+	}
+}
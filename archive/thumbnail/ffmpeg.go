@@ -0,0 +1,47 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FFmpeg extracts frames by running ffmpeg once for every frame
+type FFmpeg struct {
+	// Path is the ffmpeg binary
+	Path string
+}
+
+// Extract seeks to every offset and decodes the frame there, letterboxed
+// to width by height
+func (f FFmpeg) Extract(ctx context.Context, path string, offsets []time.Duration, width, height int) ([]image.Image, error) {
+	scale := fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2",
+		width, height, width, height)
+	images := make([]image.Image, 0, len(offsets))
+	for _, offset := range offsets {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, f.Path,
+			"-hide_banner", "-loglevel", "error", "-nostdin",
+			"-ss", strconv.FormatFloat(offset.Seconds(), 'f', 3, 64),
+			"-i", path,
+			"-frames:v", "1",
+			"-vf", scale,
+			"-f", "image2pipe", "-c:v", "png", "pipe:1")
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("failed to extract the frame at %s of %s: %w: %s", offset, path, err, strings.TrimSpace(stderr.String()))
+		}
+		frame, err := png.Decode(&stdout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the frame at %s of %s: %w", offset, path, err)
+		}
+		images = append(images, frame)
+	}
+	return images, nil
+}
@@ -0,0 +1,299 @@
+// Package thumbnail builds sprite sheets of frames from archived hours and
+// WebVTT tracks that point players' seek bars at them
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"archive/archiverepo"
+	"archive/objectstore"
+	"archive/playlist"
+)
+
+const (
+	// VTTName is the name of the thumbnail track in an hour directory. It
+	// is written last, so an hour that has one has all its sprites.
+	VTTName = "thumbnails.vtt"
+	// TileWidth and TileHeight are the size of a thumbnail
+	TileWidth  = 160
+	TileHeight = 90
+	// sheetColumns and sheetRows are how many thumbnails a sprite sheet
+	// holds across and down
+	sheetColumns = 10
+	sheetRows    = 10
+	// jpegQuality is the quality sprite sheets are encoded at
+	jpegQuality = 75
+)
+
+// FrameExtractor decodes frames from a transport stream
+type FrameExtractor interface {
+	// Extract returns the frames at offsets from the start of the segment
+	// in the file at path, scaled to fit width by height
+	Extract(ctx context.Context, path string, offsets []time.Duration, width, height int) ([]image.Image, error)
+}
+
+// SpriteName returns the name of a sprite sheet in an hour directory
+func SpriteName(index int) string {
+	return fmt.Sprintf("thumbnails_%03d.jpg", index)
+}
+
+// Report says what generating an hour's thumbnails did
+type Report struct {
+	Hour string `json:"hour"`
+	// Frames is how many thumbnails the track has
+	Frames  int `json:"frames"`
+	Sprites int `json:"sprites"`
+	// Failed are the segments whose frames could not be extracted, which
+	// have no thumbnails
+	Failed []string `json:"failed,omitempty"`
+}
+
+// Generator builds the thumbnails of archived hours
+type Generator struct {
+	store     objectstore.Store
+	parser    *playlist.Parser
+	extractor FrameExtractor
+	interval  time.Duration
+	logger    *slog.Logger
+	// mu guards hours, the locks of the hours being generated, so two
+	// generations of an hour can't mix their sprites and tracks
+	mu    sync.Mutex
+	hours map[string]*hourLock
+}
+
+// hourLock is held while an hour's thumbnails are generated
+type hourLock struct {
+	sync.Mutex
+	// users is how many generations hold or wait for the lock
+	users int
+}
+
+// New returns a generator that takes a frame every interval of playlist
+// time from the hours in store
+func New(store objectstore.Store, parser *playlist.Parser, extractor FrameExtractor, interval time.Duration, logger *slog.Logger) *Generator {
+	return &Generator{
+		store:     store,
+		parser:    parser,
+		extractor: extractor,
+		interval:  interval,
+		logger:    logger,
+		hours:     make(map[string]*hourLock),
+	}
+}
+
+// Exists reports whether an hour has thumbnails
+func (g *Generator) Exists(ctx context.Context, hour string) (bool, error) {
+	_, err := g.store.Stat(ctx, path.Join(hour, VTTName))
+	if errors.Is(err, objectstore.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// frame is a thumbnail to take, at a time in the hour's playlist
+type frame struct {
+	start, end time.Duration
+	image      image.Image
+}
+
+// Generate takes a frame every interval of an hour's playlist, from
+// whichever segment plays at that time, and writes them as sprite sheets
+// with a WebVTT track whose cue times are playlist times. Placeholders for
+// missing footage get no thumbnails, and neither do segments whose frames
+// can't be extracted. Generations of the same hour take turns.
+func (g *Generator) Generate(ctx context.Context, hour string) (Report, error) {
+	defer g.lockHour(hour)()
+	report := Report{Hour: hour}
+	hourPlaylist, err := g.readPlaylist(ctx, hour)
+	if err != nil {
+		return report, err
+	}
+
+	var frames []frame
+	var offset time.Duration
+	for _, segment := range hourPlaylist.Segments {
+		start := offset
+		offset += time.Duration(segment.Duration * float64(time.Second))
+		if archiverepo.IsPlaceholder(segment) {
+			continue
+		}
+
+		// The frames due while the segment plays
+		var due []frame
+		var offsets []time.Duration
+		for t := (start + g.interval - 1) / g.interval * g.interval; t < offset; t += g.interval {
+			due = append(due, frame{start: t, end: t + g.interval})
+			offsets = append(offsets, t-start)
+		}
+		if len(due) == 0 {
+			continue
+		}
+		images, err := g.extract(ctx, path.Join(hour, segment.Filename), offsets)
+		if err != nil {
+			g.logger.Warn("Failed to extract frames", "hour", hour, "segment", segment.Filename, "error", err)
+			report.Failed = append(report.Failed, segment.Filename)
+			continue
+		}
+		for i := range due {
+			due[i].image = images[i]
+		}
+		frames = append(frames, due...)
+	}
+	for i := range frames {
+		frames[i].end = min(frames[i].end, offset)
+	}
+
+	vtt := &strings.Builder{}
+	vtt.WriteString("WEBVTT\n")
+	perSheet := sheetColumns * sheetRows
+	for first := 0; first < len(frames); first += perSheet {
+		sheet := frames[first:min(first+perSheet, len(frames))]
+		name := SpriteName(report.Sprites)
+		if err := g.writeSprite(ctx, path.Join(hour, name), sheet); err != nil {
+			return report, err
+		}
+		for i, f := range sheet {
+			x, y := i%sheetColumns*TileWidth, i/sheetColumns*TileHeight
+			fmt.Fprintf(vtt, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n", cueTime(f.start), cueTime(f.end), name, x, y, TileWidth, TileHeight)
+		}
+		report.Sprites++
+	}
+	report.Frames = len(frames)
+
+	key := path.Join(hour, VTTName)
+	if err := g.store.Put(ctx, key, strings.NewReader(vtt.String())); err != nil {
+		return report, fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	g.logger.Info("Generated thumbnails", "hour", hour, "frames", report.Frames, "sprites", report.Sprites,
+		"failed_segments", len(report.Failed))
+	return report, nil
+}
+
+// lockHour waits for any other generation of an hour to finish and returns
+// the function that lets the next one go
+func (g *Generator) lockHour(hour string) func() {
+	g.mu.Lock()
+	lock, found := g.hours[hour]
+	if !found {
+		lock = &hourLock{}
+		g.hours[hour] = lock
+	}
+	lock.users++
+	g.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if lock.users--; lock.users == 0 {
+			delete(g.hours, hour)
+		}
+	}
+}
+
+// readPlaylist reads the playlist of an hour directory
+func (g *Generator) readPlaylist(ctx context.Context, hour string) (*playlist.Playlist, error) {
+	key := path.Join(hour, archiverepo.PlaylistName)
+	file, err := g.store.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	defer file.Close()
+	hourPlaylist, err := g.parser.Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", key, err)
+	}
+	return hourPlaylist, nil
+}
+
+// extract copies a segment out of the store into a temporary file, since
+// decoders want to seek, and extracts frames from it
+func (g *Generator) extract(ctx context.Context, key string, offsets []time.Duration) ([]image.Image, error) {
+	content, err := g.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	file, err := os.CreateTemp("", "archive-thumbnail-*.ts")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	_, err = io.Copy(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy segment: %w", err)
+	}
+
+	images, err := g.extractor.Extract(ctx, file.Name(), offsets, TileWidth, TileHeight)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) != len(offsets) {
+		return nil, fmt.Errorf("extracted %d frames, want %d", len(images), len(offsets))
+	}
+	return images, nil
+}
+
+// writeSprite draws frames into a sprite sheet, left to right and top to
+// bottom, and writes it as a JPEG
+func (g *Generator) writeSprite(ctx context.Context, key string, frames []frame) error {
+	rows := (len(frames) + sheetColumns - 1) / sheetColumns
+	columns := min(len(frames), sheetColumns)
+	sheet := image.NewRGBA(image.Rect(0, 0, columns*TileWidth, rows*TileHeight))
+	for i, f := range frames {
+		tile := image.Rect(0, 0, TileWidth, TileHeight).Add(image.Pt(i%sheetColumns*TileWidth, i/sheetColumns*TileHeight))
+		draw.Draw(sheet, tile, fit(f.image), image.Point{}, draw.Src)
+	}
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, sheet, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	if err := g.store.Put(ctx, key, &encoded); err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	return nil
+}
+
+// fit scales an image to the size of a tile, nearest neighbour, unless it
+// is that size already
+func fit(src image.Image) image.Image {
+	bounds := src.Bounds()
+	if bounds.Dx() == TileWidth && bounds.Dy() == TileHeight {
+		return src
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, TileWidth, TileHeight))
+	if bounds.Empty() {
+		return dst
+	}
+	for y := 0; y < TileHeight; y++ {
+		for x := 0; x < TileWidth; x++ {
+			dst.Set(x, y, src.At(bounds.Min.X+x*bounds.Dx()/TileWidth, bounds.Min.Y+y*bounds.Dy()/TileHeight))
+		}
+	}
+	return dst
+}
+
+// cueTime formats a time in the playlist as a WebVTT timestamp
+func cueTime(t time.Duration) string {
+	t = t.Round(time.Millisecond)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", int(t.Hours()), int(t.Minutes())%60, int(t.Seconds())%60, t.Milliseconds()%1000)
+}
@@ -0,0 +1,213 @@
+package thumbnail_test
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"archive/objectstore"
+	"archive/playlist"
+	"archive/thumbnail"
+)
+
+// fakeExtractor returns grey frames, failing for segments holding
+// "corrupt"
+type fakeExtractor struct {
+	offsets [][]time.Duration
+}
+
+func (f *fakeExtractor) Extract(ctx context.Context, path string, offsets []time.Duration, width, height int) ([]image.Image, error) {
+	f.offsets = append(f.offsets, offsets)
+	if content, err := os.ReadFile(path); err != nil || string(content) == "corrupt" {
+		return nil, errors.New("invalid data found when processing input")
+	}
+	images := make([]image.Image, len(offsets))
+	for i := range offsets {
+		// A frame of another size, which has to be scaled to fit
+		frame := image.NewGray(image.Rect(0, 0, 320, 180))
+		for j := range frame.Pix {
+			frame.Pix[j] = 128
+		}
+		images[i] = frame
+	}
+	return images, nil
+}
+
+func TestGenerator_Generate(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	store := objectstore.NewMemory()
+	hour := "2025/04/11/18"
+	for key, content := range map[string]string{
+		hour + "/playlist.m3u8": `#EXTM3U
+#EXT-X-VERSION:8
+#EXT-X-TARGETDURATION:10
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:00Z
+segment_000.ts
+#EXT-X-GAP
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:10Z
+gap.ts
+#EXTINF:7.5,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:20Z
+segment_002.ts
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:27.500Z
+segment_003.ts
+`,
+		hour + "/segment_000.ts": "video",
+		hour + "/segment_002.ts": "video",
+		hour + "/segment_003.ts": "corrupt",
+	} {
+		if err := store.Put(ctx, key, strings.NewReader(content)); err != nil {
+			t.Fatalf("Put(%s) failed: %v", key, err)
+		}
+	}
+	extractor := &fakeExtractor{}
+	generator := thumbnail.New(store, playlist.NewParser(slog.Default()), extractor, 5*time.Second, slog.Default())
+
+	// Execute
+	report, err := generator.Generate(ctx, hour)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if report.Frames != 4 || report.Sprites != 1 || strings.Join(report.Failed, ",") != "segment_003.ts" {
+		t.Errorf("report = %+v, want 4 frames in 1 sprite and segment_003.ts failed", report)
+	}
+	if got, want := len(extractor.offsets), 3; got != want {
+		t.Fatalf("Extract called %d times, want %d", got, want)
+	}
+	// Frames are due every 5s of playlist time, wherever segments start
+	if got := extractor.offsets[1]; len(got) != 2 || got[0] != 0 || got[1] != 5*time.Second {
+		t.Errorf("segment_002.ts offsets = %v, want [0s 5s]", got)
+	}
+	if got := extractor.offsets[2]; len(got) != 2 || got[0] != 2500*time.Millisecond {
+		t.Errorf("segment_003.ts offsets = %v, want [2.5s 7.5s]", got)
+	}
+
+	vtt := readObject(t, store, hour+"/"+thumbnail.VTTName)
+	want := `WEBVTT
+
+00:00:00.000 --> 00:00:05.000
+thumbnails_000.jpg#xywh=0,0,160,90
+
+00:00:05.000 --> 00:00:10.000
+thumbnails_000.jpg#xywh=160,0,160,90
+
+00:00:20.000 --> 00:00:25.000
+thumbnails_000.jpg#xywh=320,0,160,90
+
+00:00:25.000 --> 00:00:30.000
+thumbnails_000.jpg#xywh=480,0,160,90
+`
+	if vtt != want {
+		t.Errorf("track =\n%s\nwant\n%s", vtt, want)
+	}
+	sprite, err := jpeg.Decode(strings.NewReader(readObject(t, store, hour+"/"+thumbnail.SpriteName(0))))
+	if err != nil {
+		t.Fatalf("Failed to decode sprite: %v", err)
+	}
+	if size := sprite.Bounds().Size(); size != image.Pt(640, 90) {
+		t.Errorf("sprite size = %v, want 640x90", size)
+	}
+	if exists, err := generator.Exists(ctx, hour); !exists || err != nil {
+		t.Errorf("Exists = %v, %v, want true", exists, err)
+	}
+}
+
+// blockingExtractor counts how many extractions run at once, holding each
+// until release is closed
+type blockingExtractor struct {
+	fakeExtractor
+	mu      sync.Mutex
+	running int
+	most    int
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingExtractor) Extract(ctx context.Context, path string, offsets []time.Duration, width, height int) ([]image.Image, error) {
+	b.mu.Lock()
+	b.running++
+	b.most = max(b.most, b.running)
+	b.mu.Unlock()
+	b.started <- struct{}{}
+	<-b.release
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.running--
+	return b.fakeExtractor.Extract(ctx, path, offsets, width, height)
+}
+
+func TestGenerator_Generate_OneAtATimePerHour(t *testing.T) {
+	// Setup
+	ctx := context.Background()
+	store := objectstore.NewMemory()
+	hour := "2025/04/11/18"
+	for key, content := range map[string]string{
+		hour + "/playlist.m3u8": `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXTINF:10.0,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:00Z
+segment_000.ts
+`,
+		hour + "/segment_000.ts": "video",
+	} {
+		if err := store.Put(ctx, key, strings.NewReader(content)); err != nil {
+			t.Fatalf("Put(%s) failed: %v", key, err)
+		}
+	}
+	extractor := &blockingExtractor{started: make(chan struct{}, 2), release: make(chan struct{})}
+	generator := thumbnail.New(store, playlist.NewParser(slog.Default()), extractor, 5*time.Second, slog.Default())
+
+	// Execute
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := generator.Generate(ctx, hour)
+			errs <- err
+		}()
+	}
+	<-extractor.started
+	// Give the second generation the chance to start extracting too
+	time.Sleep(50 * time.Millisecond)
+	close(extractor.release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+	}
+
+	// Assert
+	if extractor.most != 1 {
+		t.Errorf("%d generations of the hour ran at once, want 1", extractor.most)
+	}
+}
+
+// readObject reads an object from a store
+func readObject(t *testing.T, store objectstore.Store, key string) string {
+	t.Helper()
+	file, err := store.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%s) failed: %v", key, err)
+	}
+	defer file.Close()
+	var content strings.Builder
+	if _, err := io.Copy(&content, file); err != nil {
+		t.Fatalf("Failed to read %s: %v", key, err)
+	}
+	return content.String()
+}
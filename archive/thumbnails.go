@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"archive/archiverepo"
+	"archive/logging"
+	"archive/thumbnail"
+)
+
+// runThumbnails builds the seek bar thumbnails of every archived hour that
+// has none yet, or just one hour, and prints a JSON report. It exits with an
+// error status if any hour or segment failed.
+func runThumbnails(loggers *logging.Loggers, args []string) {
+	flags := flag.NewFlagSet("thumbnails", flag.ExitOnError)
+	output := flags.String("output", os.Getenv("OUTPUT_DIR"), "archive directory or object store location")
+	hour := flags.String("hour", "", "only build thumbnails for this hour directory, such as 2025/04/11/18")
+	interval := flags.Duration("interval", 10*time.Second, "time between thumbnails")
+	ffmpeg := flags.String("ffmpeg", "ffmpeg", "ffmpeg binary used to extract frames")
+	force := flags.Bool("force", false, "rebuild thumbnails of hours that already have them")
+	flags.Parse(args)
+
+	if *interval <= 0 {
+		log.Fatalf("Error: invalid interval %s: use a duration such as 10s\n", *interval)
+	}
+	ctx := context.Background()
+	repos := newRepositories(loggers)
+	store := repos.store(*output)
+	generator := thumbnail.New(store, repos.parser, thumbnail.FFmpeg{Path: *ffmpeg}, *interval, loggers.For("thumbnail"))
+	hours := []string{*hour}
+	if *hour == "" {
+		var err error
+		hours, err = archiverepo.New(store, repos.parser, loggers.For("archiverepo")).Hours(ctx)
+		if err != nil {
+			log.Fatalf("Error: Unable to list archived hours: %v\n", err)
+		}
+	}
+
+	logger := loggers.For("main")
+	reports := []thumbnail.Report{}
+	failed := false
+	for _, hour := range hours {
+		if !*force {
+			exists, err := generator.Exists(ctx, hour)
+			if err != nil {
+				failed = true
+				logger.Error("Failed to check for thumbnails", "hour", hour, "error", err)
+				continue
+			}
+			if exists {
+				continue
+			}
+		}
+		report, err := generator.Generate(ctx, hour)
+		if err != nil {
+			failed = true
+			logger.Error("Failed to build thumbnails", "hour", hour, "error", err)
+			continue
+		}
+		if len(report.Failed) > 0 {
+			failed = true
+		}
+		reports = append(reports, report)
+	}
+
+	printJSON(reports)
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// thumbnailGenerator configures the daemon's thumbnails for the archive at
+// location. THUMBNAIL_INTERVAL is the time between thumbnails, 10s by
+// default, or off to build none. THUMBNAIL_FFMPEG is the ffmpeg binary used
+// to extract frames. It returns nil if thumbnails are off.
+func thumbnailGenerator(repos repositories, location string) *thumbnail.Generator {
+	if os.Getenv("THUMBNAIL_INTERVAL") == "off" {
+		return nil
+	}
+	interval := durationEnv("THUMBNAIL_INTERVAL", 10*time.Second)
+	ffmpeg := os.Getenv("THUMBNAIL_FFMPEG")
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+	return thumbnail.New(repos.store(location), repos.parser, thumbnail.FFmpeg{Path: ffmpeg}, interval,
+		repos.loggers.For("thumbnail"))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"archive/archiverepo"
+	"archive/logging"
+)
+
+// runVerify inspects the segments of every archived hour, or just one, and
+// prints a JSON report of the segments with problems. It exits with an
+// error status if any segment has problems.
+func runVerify(loggers *logging.Loggers, args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	output := flags.String("output", os.Getenv("OUTPUT_DIR"), "archive directory or object store location")
+	hour := flags.String("hour", "", "only verify this hour directory, such as 2025/04/11/18")
+	flags.Parse(args)
+
+	ctx := context.Background()
+	archiveRepo := newRepositories(loggers).archive(*output)
+	hours := []string{*hour}
+	if *hour == "" {
+		var err error
+		hours, err = archiveRepo.Hours(ctx)
+		if err != nil {
+			log.Fatalf("Error: Unable to list archived hours: %v\n", err)
+		}
+	}
+
+	logger := loggers.For("main")
+	reports := []archiverepo.VerifyReport{}
+	failed := false
+	for _, hour := range hours {
+		report, err := archiveRepo.Verify(ctx, hour)
+		if err != nil {
+			failed = true
+			logger.Error("Failed to verify hour", "hour", hour, "error", err)
+			continue
+		}
+		if len(report.Problems) > 0 {
+			failed = true
+			logger.Warn("Hour has segments with problems", "hour", hour, "segments", report.Segments, "problems", len(report.Problems))
+		}
+		reports = append(reports, report)
+	}
+
+	printJSON(reports)
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"videoserver/catalog"
+)
+
+// archiveAPI serves the archive catalog as JSON
+type archiveAPI struct {
+	catalog *catalog.Catalog
+	access  bookingAccess
+}
+
+// listDays responds with every day that has archived footage
+func (a archiveAPI) listDays(w http.ResponseWriter, r *http.Request) {
+	days, err := a.catalog.Days(r.Context())
+	if err != nil {
+		log.Printf("Failed to list archive days: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]any{"days": days})
+}
+
+// listHours responds with the archived hours on the requested date
+func (a archiveAPI) listHours(w http.ResponseWriter, r *http.Request) {
+	date, err := time.Parse(time.DateOnly, r.PathValue("date"))
+	if err != nil {
+		http.Error(w, "Bad Request: date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	hours, err := a.catalog.Hours(r.Context(), date)
+	if err != nil {
+		log.Printf("Failed to list archive hours for %s: %v\n", r.PathValue("date"), err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	// Restricted users can't open the master playlists and thumbnails of
+	// whole hours, so they play the hour playlists instead
+	if _, restricted := a.access.restricted(r); restricted {
+		for i := range hours {
+			hours[i].Master, hours[i].Thumbnails = "", ""
+		}
+	}
+	writeJSON(w, map[string]any{"date": r.PathValue("date"), "hours": hours})
+}
+
+// resolve redirects to the archive playlist holding the time in the t query
+// parameter, with the seek offset as a media fragment. Clients that accept
+// JSON get the position itself instead.
+func (a archiveAPI) resolve(w http.ResponseWriter, r *http.Request) {
+	t, err := a.catalog.ParseTime(r.URL.Query().Get("t"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: t: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	position, err := a.catalog.At(r.Context(), t)
+	if errors.Is(err, catalog.ErrNotArchived) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to resolve archive time %s: %v\n", t.Format(time.RFC3339), err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if _, restricted := a.access.restricted(r); restricted {
+		position.Master, position.Thumbnails = "", ""
+	}
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		writeJSON(w, position)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("%s#t=%.3f", position.Playlist, position.Offset), http.StatusFound)
+}
+
+// writeJSON writes value as a JSON response body
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Failed to write JSON response: %v\n", err)
+	}
+}
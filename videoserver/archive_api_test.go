@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"archive/objectstore"
+	"videoserver/catalog"
+)
+
+func TestArchiveAPI_Resolve(t *testing.T) {
+	// Setup
+	basePath := t.TempDir()
+	dir := filepath.Join(basePath, "2025", "01", "05", "12")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	archivePlaylist := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-01-05T12:59:00.000+0000
+segment_000.ts
+`
+	if err := os.WriteFile(filepath.Join(dir, "playlist.m3u8"), []byte(archivePlaylist), 0644); err != nil {
+		t.Fatal(err)
+	}
+	api := archiveAPI{catalog: catalog.New(objectstore.NewFilesystem(basePath), time.UTC)}
+
+	// Execute
+	recorder := httptest.NewRecorder()
+	api.resolve(recorder, httptest.NewRequest("GET", "/api/archive/at?t=2025-01-05T12:59:04Z", nil))
+
+	// Assert
+	if recorder.Code != http.StatusFound {
+		t.Fatalf("Status = %d, want %d", recorder.Code, http.StatusFound)
+	}
+	if location := recorder.Header().Get("Location"); location != "/archive/2025/01/05/12/playlist.m3u8#t=4.000" {
+		t.Errorf("Location = %s", location)
+	}
+
+	// Execute as JSON
+	request := httptest.NewRequest("GET", "/api/archive/at?t=2025-01-05T12:59:04Z", nil)
+	request.Header.Set("Accept", "application/json")
+	recorder = httptest.NewRecorder()
+	api.resolve(recorder, request)
+
+	// Assert
+	var position catalog.Position
+	if err := json.NewDecoder(recorder.Body).Decode(&position); err != nil {
+		t.Fatalf("Failed to decode position: %v", err)
+	}
+	if position.Playlist != "/archive/2025/01/05/12/playlist.m3u8" || position.Offset != 4 {
+		t.Errorf("Position = %+v", position)
+	}
+}
+
+func TestArchiveAPI_Resolve_NotArchived(t *testing.T) {
+	api := archiveAPI{catalog: catalog.New(objectstore.NewFilesystem(t.TempDir()), time.UTC)}
+
+	recorder := httptest.NewRecorder()
+	api.resolve(recorder, httptest.NewRequest("GET", "/api/archive/at?t=2025-01-05T12:59:04Z", nil))
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, want %d", recorder.Code, http.StatusNotFound)
+	}
+}
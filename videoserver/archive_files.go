@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"archive/objectstore"
+	"videoserver/audit"
+)
+
+// contentTypes maps the extensions of files in the archive to their types
+var contentTypes = map[string]string{
+	".m3u8": "application/vnd.apple.mpegurl",
+	".ts":   "video/mp2t",
+	".vtt":  "text/vtt",
+	".jpg":  "image/jpeg",
+}
+
+// archiveFiles serves the objects in the archive store, with support for
+// range requests. Request paths are object keys.
+type archiveFiles struct {
+	store objectstore.Store
+}
+
+func (a archiveFiles) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if hour, err := hourOfKey(key); err == nil {
+		audit.SetRange(r.Context(), hour, hour.Add(time.Hour))
+	}
+	info, err := a.store.Stat(r.Context(), key)
+	if errors.Is(err, objectstore.ErrNotExist) || (err == nil && info.IsDir) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to stat archive object %s: %v\n", key, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if contentType, found := contentTypes[path.Ext(key)]; found {
+		w.Header().Set("Content-Type", contentType)
+	}
+	content := objectstore.NewReadSeeker(r.Context(), a.store, info)
+	defer content.Close()
+	http.ServeContent(w, r, info.Name(), info.ModTime, content)
+}
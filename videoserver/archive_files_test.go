@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"archive/objectstore"
+)
+
+func TestArchiveFiles_ServeHTTP(t *testing.T) {
+	// Setup
+	store := objectstore.NewMemory()
+	if err := store.Put(context.Background(), "2025/04/11/00/segment_000.ts", strings.NewReader("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	handler := http.StripPrefix("/archive", archiveFiles{store: store})
+
+	// Execute
+	request := httptest.NewRequest("GET", "/archive/2025/04/11/00/segment_000.ts", nil)
+	request.Header.Set("Range", "bytes=4-6")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	// Assert
+	if recorder.Code != http.StatusPartialContent {
+		t.Fatalf("Status = %d, want %d", recorder.Code, http.StatusPartialContent)
+	}
+	if body := recorder.Body.String(); body != "456" {
+		t.Errorf("Body = %q, want %q", body, "456")
+	}
+	if got := recorder.Header().Get("Content-Type"); got != "video/mp2t" {
+		t.Errorf("Content-Type = %s, want video/mp2t", got)
+	}
+}
+
+func TestArchiveFiles_ServeHTTP_NotFound(t *testing.T) {
+	store := objectstore.NewMemory()
+	if err := store.Put(context.Background(), "2025/04/11/00/segment_000.ts", strings.NewReader("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	handler := http.StripPrefix("/archive", archiveFiles{store: store})
+
+	for _, target := range []string{"/archive/2025/04/11/00/missing.ts", "/archive/2025/04/11"} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", target, nil))
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("%s: Status = %d, want %d", target, recorder.Code, http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// currentName is the file entries are appended to
+	currentName = "audit.log"
+	// rotatedLayout names rotated files after the time they were rotated
+	rotatedLayout = "audit-20060102T150405.000000000Z.log"
+)
+
+// Entry records one request for footage
+type Entry struct {
+	Time   time.Time  `json:"time"`
+	User   string     `json:"user,omitempty"`
+	Via    string     `json:"via,omitempty"`
+	IP     string     `json:"ip"`
+	Method string     `json:"method"`
+	Route  string     `json:"route"`
+	Path   string     `json:"path"`
+	Status int        `json:"status"`
+	Bytes  int64      `json:"bytes"`
+	From   *time.Time `json:"from,omitempty"`
+	To     *time.Time `json:"to,omitempty"`
+}
+
+// Overlaps reports whether the entry's footage overlaps the range from-to.
+// Live footage is recorded as a single instant.
+func (e Entry) Overlaps(from, to time.Time) bool {
+	if e.From == nil || e.To == nil {
+		return false
+	}
+	if e.From.Equal(*e.To) {
+		return !e.From.Before(from) && e.From.Before(to)
+	}
+	return e.From.Before(to) && e.To.After(from)
+}
+
+// Log appends entries as JSON lines to audit.log in a directory, rotating
+// it once it grows past a size and keeping a number of rotated files
+type Log struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+}
+
+// Open opens the audit log in dir
+func Open(dir string, maxSize int64, maxFiles int) (*Log, error) {
+	l := &Log{dir: dir, maxSize: maxSize, maxFiles: maxFiles}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Write appends an entry
+func (l *Log) Write(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	return err
+}
+
+// Close closes the current file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// open opens the current file for appending
+func (l *Log) open() error {
+	file, err := os.OpenFile(filepath.Join(l.dir, currentName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+// rotate renames the current file after the time and removes the oldest
+// rotated files beyond maxFiles
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	rotated := filepath.Join(l.dir, time.Now().UTC().Format(rotatedLayout))
+	if err := os.Rename(filepath.Join(l.dir, currentName), rotated); err != nil {
+		return err
+	}
+	if err := l.open(); err != nil {
+		return err
+	}
+
+	files, err := rotatedFiles(l.dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > l.maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// rotatedFiles returns the rotated files in dir, oldest first
+func rotatedFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "audit-*.log"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Query returns the entries in the audit log in dir whose footage overlaps
+// the range from-to, oldest first, optionally only those of one user
+func Query(dir string, from, to time.Time, user string) ([]Entry, error) {
+	files, err := rotatedFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, filepath.Join(dir, currentName))
+
+	entries := []Entry{}
+	for _, path := range files {
+		err := readEntries(path, func(entry Entry) {
+			if entry.Overlaps(from, to) && (user == "" || entry.User == user) {
+				entries = append(entries, entry)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// readEntries calls found with every entry in a file. A missing file has no
+// entries, and lines that can't be parsed are skipped.
+func readEntries(path string, found func(Entry)) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			continue
+		}
+		found(entry)
+	}
+	return scanner.Err()
+}
+
+type contextKey struct{}
+
+// WithEntry returns a copy of ctx carrying an entry that handlers further
+// down fill in
+func WithEntry(ctx context.Context, entry *Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, entry)
+}
+
+// SetUser records who made the request, and how they signed in
+func SetUser(ctx context.Context, user, via string) {
+	if entry, ok := ctx.Value(contextKey{}).(*Entry); ok {
+		entry.User, entry.Via = user, via
+	}
+}
+
+// SetRange records the footage a request was for
+func SetRange(ctx context.Context, from, to time.Time) {
+	if entry, ok := ctx.Value(contextKey{}).(*Entry); ok {
+		from, to = from.UTC(), to.UTC()
+		entry.From, entry.To = &from, &to
+	}
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLog_WriteAndQuery(t *testing.T) {
+	// Setup
+	dir := t.TempDir()
+	auditLog, err := Open(dir, 1<<20, 5)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer auditLog.Close()
+	hour := time.Date(2025, 4, 11, 18, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{User: "coach", Path: "/archive/2025/04/11/18/segment_000.ts"},
+		{User: "viewer", Path: "/archive/2025/04/11/19/segment_000.ts"},
+		{User: "viewer", Path: "/stream/playlist.m3u8"},
+		{User: "admin", Path: "/api/users"},
+	}
+	ranges := [][2]time.Time{
+		{hour, hour.Add(time.Hour)},
+		{hour.Add(time.Hour), hour.Add(2 * time.Hour)},
+		{hour.Add(30 * time.Minute), hour.Add(30 * time.Minute)},
+	}
+	for i := range entries {
+		ctx := WithEntry(context.Background(), &entries[i])
+		if i < len(ranges) {
+			SetRange(ctx, ranges[i][0], ranges[i][1])
+		}
+		if err := auditLog.Write(entries[i]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	// Execute
+	found, err := Query(dir, hour, hour.Add(time.Hour), "")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(found) != 2 || found[0].User != "coach" || found[1].Path != "/stream/playlist.m3u8" {
+		t.Errorf("Query = %+v, want the coach's archive and the viewer's live access", found)
+	}
+	onlyCoach, err := Query(dir, hour, hour.Add(time.Hour), "coach")
+	if err != nil || len(onlyCoach) != 1 {
+		t.Errorf("Query for coach = %+v, %v, want one entry", onlyCoach, err)
+	}
+}
+
+func TestLog_Rotates(t *testing.T) {
+	// Setup
+	dir := t.TempDir()
+	auditLog, err := Open(dir, 500, 2)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer auditLog.Close()
+	hour := time.Date(2025, 4, 11, 18, 0, 0, 0, time.UTC)
+	end := hour.Add(time.Hour)
+
+	// Execute
+	for i := 0; i < 20; i++ {
+		entry := Entry{User: "viewer", Path: "/archive/2025/04/11/18/playlist.m3u8", From: &hour, To: &end}
+		if err := auditLog.Write(entry); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	// Assert
+	rotated, err := rotatedFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 {
+		t.Errorf("rotated files = %v, want 2", rotated)
+	}
+	info, err := os.Stat(filepath.Join(dir, currentName))
+	if err != nil || info.Size() > 500 {
+		t.Errorf("current file = %v, %v, want at most 500 bytes", info, err)
+	}
+	found, err := Query(dir, hour, end, "")
+	if err != nil || len(found) == 0 || len(found) >= 20 {
+		t.Errorf("Query = %d entries, %v, want the entries still kept", len(found), err)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"videoserver/audit"
+	"videoserver/catalog"
+)
+
+const (
+	// auditMaxSize is the size the audit log is rotated at
+	auditMaxSize = 16 << 20
+	// auditMaxFiles is how many rotated audit logs are kept
+	auditMaxFiles = 30
+)
+
+// auditDir returns the audit log directory from AUDIT_DIR, defaulting to
+// /data/audit
+func auditDir() string {
+	if dir, found := os.LookupEnv("AUDIT_DIR"); found {
+		return dir
+	}
+	return "/data/audit"
+}
+
+// countingWriter records the status and size of a response
+type countingWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *countingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *countingWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *countingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// newAuditMiddleware returns a middleware that records who requested which
+// footage in the audit log. The auth middleware and the handlers fill in
+// the user and the footage's time range.
+func newAuditMiddleware(auditLog *audit.Log, clients clientIPs) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			entry := &audit.Entry{
+				Time:   time.Now().UTC(),
+				IP:     clients.of(r),
+				Method: r.Method,
+				Route:  r.Pattern,
+				Path:   r.URL.Path,
+			}
+			counter := &countingWriter{ResponseWriter: w}
+			handler.ServeHTTP(counter, r.WithContext(audit.WithEntry(r.Context(), entry)))
+
+			entry.Status, entry.Bytes = counter.status, counter.bytes
+			if entry.Status == 0 {
+				entry.Status = http.StatusOK
+			}
+			if err := auditLog.Write(*entry); err != nil {
+				log.Printf("Failed to write audit log: %v\n", err)
+			}
+		})
+	}
+}
+
+// liveFootage records requests for the live stream as footage of the time
+// they were made
+func liveFootage(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		audit.SetRange(r.Context(), now, now)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// parseAuditRange returns the range of footage to look up access to: a
+// whole local hour, or from-to
+func parseAuditRange(hour, from, to string, location *time.Location) (time.Time, time.Time, error) {
+	if hour != "" {
+		start, err := catalog.ParseTime(hour, location)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("hour: %w", err)
+		}
+		start = time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), 0, 0, 0, start.Location())
+		return start, start.Add(time.Hour), nil
+	}
+
+	start, err := catalog.ParseTime(from, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("from: %w", err)
+	}
+	end, err := catalog.ParseTime(to, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("to: %w", err)
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, errors.New("to must be after from")
+	}
+	return start, end, nil
+}
+
+// auditAPI lets admins look up who accessed footage
+type auditAPI struct {
+	dir      string
+	location *time.Location
+}
+
+// query handles GET /api/audit with the hour, or from and to, parameters
+// and an optional user parameter
+func (a auditAPI) query(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, to, err := parseAuditRange(query.Get("hour"), query.Get("from"), query.Get("to"), a.location)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := audit.Query(a.dir, from, to, query.Get("user"))
+	if err != nil {
+		log.Printf("Failed to query audit log: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]any{"from": from.UTC(), "to": to.UTC(), "entries": entries})
+}
+
+// runAudit prints who accessed footage in an hour or time range
+func runAudit(args []string) {
+	flags := flag.NewFlagSet("audit", flag.ExitOnError)
+	dir := flags.String("dir", auditDir(), "audit log directory")
+	hour := flags.String("hour", "", "local hour to look up, such as 2006-01-02T15:00")
+	fromFlag := flags.String("from", "", "start of the footage (RFC3339, or local time such as 2006-01-02T15:04)")
+	toFlag := flags.String("to", "", "end of the footage (RFC3339, or local time such as 2006-01-02T15:04)")
+	user := flags.String("user", "", "only show this user's access")
+	flags.Parse(args)
+
+	location := clubLocation()
+	from, to, err := parseAuditRange(*hour, *fromFlag, *toFlag, location)
+	if err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+	entries, err := audit.Query(*dir, from, to, *user)
+	if err != nil {
+		log.Fatalf("Error: Unable to query audit log: %v\n", err)
+	}
+
+	for _, entry := range entries {
+		user := entry.User
+		if user == "" {
+			user = "-"
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\t%d\t%d\t%s %s\n",
+			entry.Time.In(location).Format(time.RFC3339), user, entry.Via, entry.IP,
+			entry.Status, entry.Bytes, entry.Method, entry.Path)
+	}
+}
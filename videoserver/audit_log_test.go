@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"videoserver/audit"
+	"videoserver/auth"
+)
+
+func TestAuditMiddleware_RecordsUserRangeAndBytes(t *testing.T) {
+	// Setup
+	dir := t.TempDir()
+	auditLog, err := audit.Open(dir, 1<<20, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer auditLog.Close()
+	users, err := auth.OpenStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Add("viewer", "viewer password", auth.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	requireRole := newAuthMiddleware(users, auth.NewSessions(time.Hour), openTestTokens(t), newSignInGuard(nil))
+	audited := newAuditMiddleware(auditLog, clientIPs{})
+	mux := http.NewServeMux()
+	mux.Handle("GET /archive/", audited(requireRole(auth.RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hour := time.Date(2025, 4, 11, 18, 0, 0, 0, time.UTC)
+		audit.SetRange(r.Context(), hour, hour.Add(time.Hour))
+		w.Write([]byte("segment"))
+	}))))
+
+	// Execute
+	request := httptest.NewRequest("GET", "/archive/2025/04/11/18/segment_000.ts", nil)
+	request.RemoteAddr = "203.0.113.5:4321"
+	request.SetBasicAuth("viewer", "viewer password")
+	mux.ServeHTTP(httptest.NewRecorder(), request)
+
+	// Assert
+	hour := time.Date(2025, 4, 11, 18, 0, 0, 0, time.UTC)
+	entries, err := audit.Query(dir, hour, hour.Add(time.Hour), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("entries = %+v, want one", entries)
+	}
+	entry := entries[0]
+	if entry.User != "viewer" || entry.Via != "basic auth" || entry.IP != "203.0.113.5" ||
+		entry.Route != "GET /archive/" || entry.Status != http.StatusOK || entry.Bytes != int64(len("segment")) {
+		t.Errorf("entry = %+v", entry)
+	}
+}
+
+func TestParseAuditRange_LocalHour(t *testing.T) {
+	location := time.FixedZone("CEST", 2*60*60)
+
+	from, to, err := parseAuditRange("2025-04-11T20:30", "", "", location)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, 4, 11, 18, 0, 0, 0, time.UTC); !from.Equal(want) || !to.Equal(want.Add(time.Hour)) {
+		t.Errorf("parseAuditRange = %v - %v, want the hour from %v", from, to, want)
+	}
+}
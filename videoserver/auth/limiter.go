@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// pruneThreshold is how many keys a limiter holds before it forgets the
+	// ones that no longer matter
+	pruneThreshold = 1024
+	// maxKeys is how many keys a limiter holds at most. Past that, it
+	// forgets the key whose window started first.
+	maxKeys = 16384
+)
+
+// Limiter locks a key, such as a client IP or a user name, out for a while
+// once it has failed to sign in too often within a window
+type Limiter struct {
+	maxFailures int
+	window      time.Duration
+	lockout     time.Duration
+	now         func() time.Time
+	maxKeys     int
+	mu          sync.Mutex
+	keys        map[string]*failures
+}
+
+// failures are the recent failed sign ins of a key
+type failures struct {
+	count       int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+// NewLimiter returns a limiter that locks a key out for lockout after
+// maxFailures failures within window
+func NewLimiter(maxFailures int, window, lockout time.Duration) *Limiter {
+	return &Limiter{
+		maxFailures: maxFailures,
+		window:      window,
+		lockout:     lockout,
+		now:         time.Now,
+		maxKeys:     maxKeys,
+		keys:        make(map[string]*failures),
+	}
+}
+
+// Locked returns how long a key is still locked out for, or zero if it may
+// try to sign in
+func (l *Limiter) Locked(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, found := l.keys[key]
+	if !found {
+		return 0
+	}
+	return max(entry.lockedUntil.Sub(l.now()), 0)
+}
+
+// Fail records a failed sign in and reports whether it locked the key out
+func (l *Limiter) Fail(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if len(l.keys) >= pruneThreshold {
+		l.prune(now)
+	}
+
+	entry, found := l.keys[key]
+	if !found && len(l.keys) >= l.maxKeys {
+		l.forgetOldest()
+	}
+	if !found || now.Sub(entry.windowStart) >= l.window {
+		entry = &failures{windowStart: now, lockedUntil: entry.lockedUntilOrZero()}
+		l.keys[key] = entry
+	}
+	entry.count++
+	if entry.count < l.maxFailures {
+		return false
+	}
+	// Start counting again once the lockout is over
+	entry.count = 0
+	entry.windowStart = now
+	entry.lockedUntil = now.Add(l.lockout)
+	return true
+}
+
+// Succeed forgets the failures of a key after it signed in
+func (l *Limiter) Succeed(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if entry, found := l.keys[key]; found && !entry.lockedUntil.After(l.now()) {
+		delete(l.keys, key)
+	}
+}
+
+// prune forgets keys whose window and lockout are both over
+func (l *Limiter) prune(now time.Time) {
+	for key, entry := range l.keys {
+		if now.Sub(entry.windowStart) >= l.window && !entry.lockedUntil.After(now) {
+			delete(l.keys, key)
+		}
+	}
+}
+
+// forgetOldest forgets the key whose window started first
+func (l *Limiter) forgetOldest() {
+	var oldest string
+	var oldestStart time.Time
+	for key, entry := range l.keys {
+		if oldestStart.IsZero() || entry.windowStart.Before(oldestStart) {
+			oldest, oldestStart = key, entry.windowStart
+		}
+	}
+	delete(l.keys, oldest)
+}
+
+// lockedUntilOrZero returns when the failures' lockout ends, on a nil
+// entry too
+func (f *failures) lockedUntilOrZero() time.Time {
+	if f == nil {
+		return time.Time{}
+	}
+	return f.lockedUntil
+}
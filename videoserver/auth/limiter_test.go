@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter_LocksOutAfterTooManyFailures(t *testing.T) {
+	// Setup
+	now := time.Date(2025, 4, 11, 12, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(3, time.Minute, 10*time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	// Execute
+	for i := 0; i < 2; i++ {
+		if limiter.Fail("10.0.0.1") {
+			t.Fatalf("Fail %d locked out early", i+1)
+		}
+	}
+
+	// Assert
+	if wait := limiter.Locked("10.0.0.1"); wait != 0 {
+		t.Errorf("Locked = %s before the limit, want 0", wait)
+	}
+	if !limiter.Fail("10.0.0.1") {
+		t.Error("Fail at the limit did not lock out")
+	}
+	if wait := limiter.Locked("10.0.0.1"); wait != 10*time.Minute {
+		t.Errorf("Locked = %s, want 10m", wait)
+	}
+	if wait := limiter.Locked("10.0.0.2"); wait != 0 {
+		t.Errorf("Locked = %s for another key, want 0", wait)
+	}
+
+	// Succeeding doesn't lift a lockout
+	limiter.Succeed("10.0.0.1")
+	if wait := limiter.Locked("10.0.0.1"); wait == 0 {
+		t.Error("Succeed lifted the lockout")
+	}
+	now = now.Add(10 * time.Minute)
+	if wait := limiter.Locked("10.0.0.1"); wait != 0 {
+		t.Errorf("Locked = %s after the lockout, want 0", wait)
+	}
+}
+
+func TestLimiter_FailuresOutsideTheWindowDontCount(t *testing.T) {
+	// Setup
+	now := time.Date(2025, 4, 11, 12, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(2, time.Minute, time.Hour)
+	limiter.now = func() time.Time { return now }
+
+	// Execute
+	limiter.Fail("coach")
+	now = now.Add(2 * time.Minute)
+	locked := limiter.Fail("coach")
+
+	// Assert
+	if locked {
+		t.Error("Fail locked out with failures in separate windows")
+	}
+}
+
+func TestLimiter_HoldsAtMostMaxKeys(t *testing.T) {
+	// Setup
+	now := time.Date(2025, 4, 11, 12, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(1, time.Hour, time.Hour)
+	limiter.now = func() time.Time { return now }
+	limiter.maxKeys = 3
+
+	// Execute
+	for _, key := range []string{"a", "b", "c", "d"} {
+		limiter.Fail(key)
+		now = now.Add(time.Minute)
+	}
+
+	// Assert
+	if len(limiter.keys) != 3 {
+		t.Errorf("len(keys) = %d, want 3", len(limiter.keys))
+	}
+	if wait := limiter.Locked("a"); wait != 0 {
+		t.Errorf("Locked(a) = %s, want the oldest key forgotten", wait)
+	}
+	if wait := limiter.Locked("d"); wait == 0 {
+		t.Error("the newest key is not locked out")
+	}
+}
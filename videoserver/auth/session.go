@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// SessionCookie is the name of the cookie holding the session token
+const SessionCookie = "court6_session"
+
+// Session is a signed in browser
+type Session struct {
+	Token     string
+	User      string
+	CSRFToken string
+	Expires   time.Time
+}
+
+// CheckCSRF reports whether token is the session's CSRF token
+func (s Session) CheckCSRF(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.CSRFToken)) == 1
+}
+
+// Sessions keeps the signed in browsers in memory. Restarting the server
+// signs everyone out.
+type Sessions struct {
+	ttl      time.Duration
+	now      func() time.Time
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+// NewSessions returns sessions that expire ttl after signing in
+func NewSessions(ttl time.Duration) *Sessions {
+	return &Sessions{
+		ttl:      ttl,
+		now:      time.Now,
+		sessions: make(map[string]Session),
+	}
+}
+
+// Create signs a user in
+func (s *Sessions) Create(user string) Session {
+	session := Session{
+		Token:     randomToken(),
+		User:      user,
+		CSRFToken: randomToken(),
+		Expires:   s.now().Add(s.ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Forget sessions that expired without signing out
+	for token, existing := range s.sessions {
+		if !s.now().Before(existing.Expires) {
+			delete(s.sessions, token)
+		}
+	}
+	s.sessions[session.Token] = session
+	return session
+}
+
+// Get returns the unexpired session with the given token
+func (s *Sessions) Get(token string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, found := s.sessions[token]
+	if !found {
+		return Session{}, false
+	}
+	if !s.now().Before(session.Expires) {
+		delete(s.sessions, token)
+		return Session{}, false
+	}
+	return session, true
+}
+
+// Delete signs a session out
+func (s *Sessions) Delete(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+// randomToken returns 32 random bytes, URL-safe base64 encoded
+func randomToken() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
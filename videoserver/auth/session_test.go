@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessions_Expire(t *testing.T) {
+	// Setup
+	now := time.Date(2025, 4, 11, 12, 0, 0, 0, time.UTC)
+	sessions := NewSessions(time.Hour)
+	sessions.now = func() time.Time { return now }
+
+	// Execute
+	session := sessions.Create("coach")
+
+	// Assert
+	if got, ok := sessions.Get(session.Token); !ok || got.User != "coach" {
+		t.Errorf("Get = %+v, %v, want the coach's session", got, ok)
+	}
+	if session.Token == session.CSRFToken {
+		t.Error("the CSRF token is the session token")
+	}
+	now = now.Add(time.Hour)
+	if _, ok := sessions.Get(session.Token); ok {
+		t.Error("Get returned an expired session")
+	}
+}
+
+func TestSession_CheckCSRF(t *testing.T) {
+	session := NewSessions(time.Hour).Create("coach")
+
+	if !session.CheckCSRF(session.CSRFToken) {
+		t.Error("CheckCSRF rejected the session's token")
+	}
+	if session.CheckCSRF("") || session.CheckCSRF(session.Token) {
+		t.Error("CheckCSRF accepted the wrong token")
+	}
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"videoserver/jsonfile"
+)
+
+var (
+	// ErrUserExists is returned when adding a user whose name is taken
+	ErrUserExists = errors.New("user already exists")
+	// ErrUnknownUser is returned when changing a user that does not exist
+	ErrUnknownUser = errors.New("unknown user")
+)
+
+// dummyHash is compared against when a user does not exist, so that signing
+// in as an unknown user takes as long as using a wrong password
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("court6"), bcrypt.DefaultCost)
+
+// Store keeps users and their bcrypt password hashes in a JSON file. Changes
+// made to the file by another process, such as the users command, are picked
+// up on the next sign in.
+type Store struct {
+	users *jsonfile.Store[User]
+}
+
+// OpenStore opens the user file at path. A missing file is an empty store.
+func OpenStore(path string) (*Store, error) {
+	users, err := jsonfile.Open(path, func(user User) string { return user.Name }, func(a, b User) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Store{users: users}, nil
+}
+
+// Authenticate returns the user with the given name and password
+func (s *Store) Authenticate(name, password string) (User, bool) {
+	user, found := s.users.Get(name)
+	if !found {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return User{}, false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return User{}, false
+	}
+	return user, true
+}
+
+// Get returns the user with the given name
+func (s *Store) Get(name string) (User, bool) {
+	return s.users.Get(name)
+}
+
+// List returns every user, sorted by name
+func (s *Store) List() []User {
+	return s.users.List()
+}
+
+// Add creates a user
+func (s *Store) Add(name, password string, role Role) error {
+	if name == "" {
+		return errors.New("user name is empty")
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return s.users.Update(func(users map[string]User) error {
+		if _, found := users[name]; found {
+			return fmt.Errorf("%w: %s", ErrUserExists, name)
+		}
+		users[name] = User{Name: name, PasswordHash: hash, Role: role, Created: time.Now().UTC()}
+		return nil
+	})
+}
+
+// Remove deletes a user
+func (s *Store) Remove(name string) error {
+	return s.users.Update(func(users map[string]User) error {
+		if _, found := users[name]; !found {
+			return fmt.Errorf("%w: %s", ErrUnknownUser, name)
+		}
+		delete(users, name)
+		return nil
+	})
+}
+
+// SetPassword replaces a user's password
+func (s *Store) SetPassword(name, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.change(name, func(user *User) { user.PasswordHash = hash })
+}
+
+// SetRole changes a user's role
+func (s *Store) SetRole(name string, role Role) error {
+	return s.change(name, func(user *User) { user.Role = role })
+}
+
+// SetEmail changes the address a user books the court with, or clears it
+// if email is empty
+func (s *Store) SetEmail(name, email string) error {
+	return s.change(name, func(user *User) { user.Email = email })
+}
+
+// change applies a change to an existing user and saves it
+func (s *Store) change(name string, change func(user *User)) error {
+	return s.users.Update(func(users map[string]User) error {
+		user, found := users[name]
+		if !found {
+			return fmt.Errorf("%w: %s", ErrUnknownUser, name)
+		}
+		change(&user)
+		users[name] = user
+		return nil
+	})
+}
+
+// hashPassword returns the bcrypt hash of a password
+func hashPassword(password string) (string, error) {
+	if len(password) < 8 {
+		return "", errors.New("password must be at least 8 characters")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStore_AddAuthenticateRemove(t *testing.T) {
+	// Setup
+	path := filepath.Join(t.TempDir(), "users.json")
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore failed: %v", err)
+	}
+
+	// Execute
+	if err := store.Add("coach", "correct horse", RoleCoach); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	// Assert
+	user, ok := store.Authenticate("coach", "correct horse")
+	if !ok || user.Role != RoleCoach {
+		t.Errorf("Authenticate = %+v, %v, want the coach", user, ok)
+	}
+	if _, ok := store.Authenticate("coach", "wrong horse"); ok {
+		t.Error("Authenticate accepted a wrong password")
+	}
+	if _, ok := store.Authenticate("nobody", "correct horse"); ok {
+		t.Error("Authenticate accepted an unknown user")
+	}
+	if err := store.Add("coach", "another password", RoleViewer); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Add duplicate = %v, want %v", err, ErrUserExists)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "correct horse") {
+		t.Error("user file contains the plaintext password")
+	}
+
+	if err := store.Remove("coach"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, ok := store.Authenticate("coach", "correct horse"); ok {
+		t.Error("Authenticate accepted a removed user")
+	}
+	if err := store.Remove("coach"); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("Remove missing = %v, want %v", err, ErrUnknownUser)
+	}
+}
+
+func TestStore_PicksUpChangesFromOtherProcesses(t *testing.T) {
+	// Setup
+	path := filepath.Join(t.TempDir(), "users.json")
+	server, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Execute
+	if err := cli.Add("viewer", "password1", RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.Authenticate("viewer", "password1"); !ok {
+		t.Fatal("Authenticate rejected a user added by another store")
+	}
+	if err := cli.SetPassword("viewer", "password2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.SetRole("viewer", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.SetEmail("viewer", "viewer@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Assert
+	if _, ok := server.Authenticate("viewer", "password1"); ok {
+		t.Error("Authenticate accepted a reset password")
+	}
+	user, ok := server.Authenticate("viewer", "password2")
+	if !ok || user.Role != RoleAdmin || user.Email != "viewer@example.com" {
+		t.Errorf("Authenticate = %+v, %v, want the admin with their email address", user, ok)
+	}
+}
+
+func TestStore_RejectsShortPasswords(t *testing.T) {
+	store, err := OpenStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Add("viewer", "short", RoleViewer); err == nil {
+		t.Error("Add accepted a short password")
+	}
+}
+
+func TestRole_Allows(t *testing.T) {
+	tests := []struct {
+		role     Role
+		required Role
+		expected bool
+	}{
+		{RoleViewer, RoleViewer, true},
+		{RoleViewer, RoleCoach, false},
+		{RoleCoach, RoleViewer, true},
+		{RoleCoach, RoleAdmin, false},
+		{RoleAdmin, RoleCoach, true},
+		{Role(""), RoleViewer, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.Allows(tt.required); got != tt.expected {
+			t.Errorf("%q.Allows(%q) = %v, want %v", tt.role, tt.required, got, tt.expected)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	if role, err := ParseRole("coach"); err != nil || role != RoleCoach {
+		t.Errorf("ParseRole(coach) = %q, %v", role, err)
+	}
+	if _, err := ParseRole("owner"); err == nil {
+		t.Error("ParseRole(owner) succeeded, want an error")
+	}
+}
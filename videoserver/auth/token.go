@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"videoserver/jsonfile"
+)
+
+// ErrUnknownToken is returned when changing a token that does not exist
+var ErrUnknownToken = errors.New("unknown token")
+
+// tokenPrefix starts every API token, so that leaked tokens are easy to spot
+const tokenPrefix = "c6_"
+
+// lastUsedInterval limits how often a token's last use is written to disk
+const lastUsedInterval = time.Minute
+
+// Scope is what an API token may be used for
+type Scope string
+
+const (
+	// ScopeRead can read the stream, the archive and its catalog
+	ScopeRead Scope = "read"
+	// ScopeExport can also export clips
+	ScopeExport Scope = "export"
+	// ScopeAdmin can also manage users and tokens
+	ScopeAdmin Scope = "admin"
+)
+
+// scopeRoles maps each scope to the role it acts as
+var scopeRoles = map[Scope]Role{
+	ScopeRead:   RoleViewer,
+	ScopeExport: RoleCoach,
+	ScopeAdmin:  RoleAdmin,
+}
+
+// ParseScope parses the name of a scope
+func ParseScope(name string) (Scope, error) {
+	scope := Scope(name)
+	if _, found := scopeRoles[scope]; !found {
+		return "", fmt.Errorf("unknown scope %q: use read, export or admin", name)
+	}
+	return scope, nil
+}
+
+// Role returns the role a token with the scope acts as
+func (s Scope) Role() Role {
+	return scopeRoles[s]
+}
+
+// Token is an API token that lets a script act for a user. Only a hash of
+// the token's secret is kept.
+type Token struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	User     string     `json:"user"`
+	Scope    Scope      `json:"scope"`
+	Hash     string     `json:"hash"`
+	Created  time.Time  `json:"created"`
+	LastUsed *time.Time `json:"last_used,omitempty"`
+	Revoked  *time.Time `json:"revoked,omitempty"`
+}
+
+// TokenStore keeps API tokens in a JSON file, oldest first
+type TokenStore struct {
+	tokens *jsonfile.Store[Token]
+	now    func() time.Time
+}
+
+// OpenTokenStore opens the token file at path. A missing file is an empty
+// store.
+func OpenTokenStore(path string) (*TokenStore, error) {
+	tokens, err := jsonfile.Open(path, func(token Token) string { return token.ID }, compareTokens)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenStore{tokens: tokens, now: time.Now}, nil
+}
+
+// Create makes a token for a user and returns its secret, which is not
+// stored and can't be shown again
+func (s *TokenStore) Create(user, name string, scope Scope) (string, Token, error) {
+	if _, err := ParseScope(string(scope)); err != nil {
+		return "", Token{}, err
+	}
+	id := randomHex(4)
+	secret := tokenPrefix + id + "_" + randomToken()
+	token := Token{
+		ID:      id,
+		Name:    name,
+		User:    user,
+		Scope:   scope,
+		Hash:    hashToken(secret),
+		Created: s.now().UTC(),
+	}
+
+	err := s.tokens.Update(func(tokens map[string]Token) error {
+		if _, found := tokens[id]; found {
+			return fmt.Errorf("token id %s is taken, try again", id)
+		}
+		tokens[id] = token
+		return nil
+	})
+	if err != nil {
+		return "", Token{}, err
+	}
+	return secret, token, nil
+}
+
+// Authenticate returns the unrevoked token with the given secret and
+// records that it was used
+func (s *TokenStore) Authenticate(secret string) (Token, bool) {
+	id, _, found := strings.Cut(strings.TrimPrefix(secret, tokenPrefix), "_")
+	if !found || !strings.HasPrefix(secret, tokenPrefix) {
+		return Token{}, false
+	}
+
+	token, found := s.tokens.Get(id)
+	if !found || token.Revoked != nil {
+		return Token{}, false
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(token.Hash)) != 1 {
+		return Token{}, false
+	}
+
+	now := s.now().UTC()
+	if token.LastUsed == nil || now.Sub(*token.LastUsed) >= lastUsedInterval {
+		token.LastUsed = &now
+		err := s.tokens.Update(func(tokens map[string]Token) error {
+			if latest, found := tokens[id]; found {
+				latest.LastUsed = &now
+				tokens[id] = latest
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to record the use of token %s: %v\n", id, err)
+		}
+	}
+	return token, true
+}
+
+// List returns every token, oldest first
+func (s *TokenStore) List() []Token {
+	return s.tokens.List()
+}
+
+// Revoke stops a token from working. Revoked tokens are kept so that their
+// history can still be listed.
+func (s *TokenStore) Revoke(id string) error {
+	return s.tokens.Update(func(tokens map[string]Token) error {
+		token, found := tokens[id]
+		if !found {
+			return fmt.Errorf("%w: %s", ErrUnknownToken, id)
+		}
+		if token.Revoked == nil {
+			now := s.now().UTC()
+			token.Revoked = &now
+			tokens[id] = token
+		}
+		return nil
+	})
+}
+
+// compareTokens orders tokens oldest first
+func compareTokens(a, b Token) int {
+	if c := a.Created.Compare(b.Created); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// hashToken returns the SHA-256 hash of a token's secret. Secrets are long
+// and random, so they don't need a slow hash like passwords do.
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenStore_CreateAuthenticateRevoke(t *testing.T) {
+	// Setup
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	store, err := OpenTokenStore(path)
+	if err != nil {
+		t.Fatalf("OpenTokenStore failed: %v", err)
+	}
+	now := time.Date(2025, 4, 11, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	// Execute
+	secret, created, err := store.Create("coach", "nightly backup", ScopeRead)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Assert
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Error("token file contains the secret")
+	}
+	now = now.Add(time.Hour)
+	token, ok := store.Authenticate(secret)
+	if !ok || token.User != "coach" || token.Scope.Role() != RoleViewer {
+		t.Fatalf("Authenticate = %+v, %v, want the coach's read token", token, ok)
+	}
+	if token.LastUsed == nil || !token.LastUsed.Equal(now) {
+		t.Errorf("LastUsed = %v, want %v", token.LastUsed, now)
+	}
+	if _, ok := store.Authenticate(secret + "x"); ok {
+		t.Error("Authenticate accepted a wrong secret")
+	}
+	if _, ok := store.Authenticate("Basic " + secret); ok {
+		t.Error("Authenticate accepted a malformed secret")
+	}
+
+	// Execute
+	if err := store.Revoke(created.ID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+
+	// Assert
+	if _, ok := store.Authenticate(secret); ok {
+		t.Error("Authenticate accepted a revoked token")
+	}
+	tokens := store.List()
+	if len(tokens) != 1 || tokens[0].Revoked == nil {
+		t.Errorf("List = %+v, want the revoked token", tokens)
+	}
+	if err := store.Revoke("missing"); !errors.Is(err, ErrUnknownToken) {
+		t.Errorf("Revoke missing = %v, want %v", err, ErrUnknownToken)
+	}
+}
+
+func TestTokenStore_RevocationFromAnotherProcess(t *testing.T) {
+	// Setup
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	server, err := OpenTokenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := OpenTokenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, token, err := cli.Create("admin", "", ScopeAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.Authenticate(secret); !ok {
+		t.Fatal("Authenticate rejected a token created by another store")
+	}
+
+	// Execute
+	if err := cli.Revoke(token.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Assert
+	if _, ok := server.Authenticate(secret); ok {
+		t.Error("Authenticate accepted a token revoked by another store")
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	if scope, err := ParseScope("export"); err != nil || scope.Role() != RoleCoach {
+		t.Errorf("ParseScope(export) = %q, %v", scope, err)
+	}
+	if _, err := ParseScope("write"); err == nil {
+		t.Error("ParseScope(write) succeeded, want an error")
+	}
+}
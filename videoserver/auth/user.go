@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Role is what a user is allowed to do. Each role can do everything the
+// roles below it can.
+type Role string
+
+const (
+	// RoleViewer can watch the live stream and the archive
+	RoleViewer Role = "viewer"
+	// RoleCoach can also export clips
+	RoleCoach Role = "coach"
+	// RoleAdmin can also manage users
+	RoleAdmin Role = "admin"
+)
+
+// roleRanks orders the roles from least to most privileged
+var roleRanks = map[Role]int{
+	RoleViewer: 1,
+	RoleCoach:  2,
+	RoleAdmin:  3,
+}
+
+// ParseRole parses the name of a role
+func ParseRole(name string) (Role, error) {
+	role := Role(name)
+	if _, found := roleRanks[role]; !found {
+		return "", fmt.Errorf("unknown role %q: use viewer, coach or admin", name)
+	}
+	return role, nil
+}
+
+// Allows reports whether the role may do what the required role may
+func (r Role) Allows(required Role) bool {
+	return roleRanks[r] >= roleRanks[required]
+}
+
+// User is someone who can sign in to the videoserver
+type User struct {
+	Name         string    `json:"name"`
+	PasswordHash string    `json:"password_hash"`
+	Role         Role      `json:"role"`
+	Created      time.Time `json:"created"`
+	// Email is the address the user books the court with, which is what
+	// matches them to the players of bookings
+	Email string `json:"email,omitempty"`
+}
+
+type contextKey struct{}
+
+// WithUser returns a copy of ctx carrying the signed in user
+func WithUser(ctx context.Context, user User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// UserFromContext returns the signed in user carried by ctx
+func UserFromContext(ctx context.Context) (User, bool) {
+	user, ok := ctx.Value(contextKey{}).(User)
+	return user, ok
+}
@@ -0,0 +1,195 @@
+// Package booking keeps the court bookings imported from the club's
+// calendar, so recordings can be found by the booking they were made
+// during and who played in it
+package booking
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"videoserver/jsonfile"
+)
+
+// Booking is a court booked from Start until End
+type Booking struct {
+	// ID is the calendar event's UID, followed by the start of the
+	// occurrence for recurring bookings
+	ID       string    `json:"id"`
+	Summary  string    `json:"summary"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Players  []Player  `json:"players"`
+	Location string    `json:"location,omitempty"`
+}
+
+// Player is someone who booked the court or was invited to play
+type Player struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// String returns the player's name, or their email address if the calendar
+// has no name
+func (p Player) String() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Email
+}
+
+// HasPlayer reports whether someone, by name or email address, played in
+// the booking, ignoring case
+func (b Booking) HasPlayer(name string) bool {
+	return name != "" && slices.ContainsFunc(b.Players, func(player Player) bool {
+		return strings.EqualFold(player.Name, name) || strings.EqualFold(player.Email, name)
+	})
+}
+
+// HasEmail reports whether the player with an email address played in the
+// booking, ignoring case
+func (b Booking) HasEmail(email string) bool {
+	return email != "" && slices.ContainsFunc(b.Players, func(player Player) bool {
+		return strings.EqualFold(player.Email, email)
+	})
+}
+
+// Overlaps reports whether the booking overlaps the time from start to end
+func (b Booking) Overlaps(start, end time.Time) bool {
+	return end.After(b.Start) && start.Before(b.End)
+}
+
+// Filter picks bookings. Zero fields match every booking.
+type Filter struct {
+	// From and To limit the bookings to the ones overlapping that time
+	From time.Time
+	To   time.Time
+	// Query matches words in the summary, the location or the players,
+	// ignoring case
+	Query  string
+	Player string
+	// Email only matches players by email address, for deciding whose
+	// footage someone may see
+	Email string
+}
+
+// matches reports whether a booking passes the filter
+func (f Filter) matches(b Booking) bool {
+	if (!f.From.IsZero() && !b.End.After(f.From)) || (!f.To.IsZero() && !b.Start.Before(f.To)) {
+		return false
+	}
+	if f.Player != "" && !b.HasPlayer(f.Player) {
+		return false
+	}
+	if f.Email != "" && !b.HasEmail(f.Email) {
+		return false
+	}
+	text := strings.ToLower(b.Summary + " " + b.Location)
+	for _, player := range b.Players {
+		text += " " + strings.ToLower(player.Name+" "+player.Email)
+	}
+	for _, word := range strings.Fields(strings.ToLower(f.Query)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// ImportReport says what importing a calendar changed
+type ImportReport struct {
+	Bookings  int `json:"bookings"`
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Cancelled int `json:"cancelled"`
+	// Removed counts bookings that dropped out of the calendar
+	Removed int `json:"removed"`
+}
+
+// Store keeps bookings in a JSON file, earliest first
+type Store struct {
+	bookings *jsonfile.Store[Booking]
+}
+
+// Open opens the booking file at path. A missing file is an empty store.
+func Open(path string) (*Store, error) {
+	bookings, err := jsonfile.Open(path, func(b Booking) string { return b.ID }, compare)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{bookings: bookings}, nil
+}
+
+// Import adds and updates the bookings of a calendar and deletes the ones
+// it cancels. Calendars often only hold recent and upcoming bookings, so
+// bookings before the calendar's first are kept, but ones between its first
+// and last that it no longer has are removed.
+func (s *Store) Import(calendar Calendar) (ImportReport, error) {
+	report := ImportReport{Bookings: len(calendar.Bookings)}
+	err := s.bookings.Update(func(bookings map[string]Booking) error {
+		listed := make(map[string]bool, len(calendar.Bookings))
+		var first, last time.Time
+		for _, b := range calendar.Bookings {
+			listed[b.ID] = true
+			if first.IsZero() || b.Start.Before(first) {
+				first = b.Start
+			}
+			if b.Start.After(last) {
+				last = b.Start
+			}
+			existing, found := bookings[b.ID]
+			switch {
+			case !found:
+				report.Added++
+			case !equal(existing, b):
+				report.Updated++
+			}
+			bookings[b.ID] = b
+		}
+		for _, id := range calendar.Cancelled {
+			if _, found := bookings[id]; found {
+				delete(bookings, id)
+				report.Cancelled++
+			}
+		}
+		for id, b := range bookings {
+			if !listed[id] && len(listed) > 0 && !b.Start.Before(first) && !b.Start.After(last) {
+				delete(bookings, id)
+				report.Removed++
+			}
+		}
+		return nil
+	})
+	return report, err
+}
+
+// Get returns the booking with an ID
+func (s *Store) Get(id string) (Booking, bool) {
+	return s.bookings.Get(id)
+}
+
+// List returns the bookings that pass a filter, latest first
+func (s *Store) List(filter Filter) []Booking {
+	bookings := []Booking{}
+	sorted := s.bookings.List()
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if filter.matches(sorted[i]) {
+			bookings = append(bookings, sorted[i])
+		}
+	}
+	return bookings
+}
+
+// compare orders bookings earliest first
+func compare(a, b Booking) int {
+	if c := a.Start.Compare(b.Start); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// equal reports whether two bookings are the same
+func equal(a, b Booking) bool {
+	return a.ID == b.ID && a.Summary == b.Summary && a.Start.Equal(b.Start) && a.End.Equal(b.End) &&
+		slices.Equal(a.Players, b.Players) && a.Location == b.Location
+}
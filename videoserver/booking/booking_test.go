@@ -0,0 +1,65 @@
+package booking
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_Import(t *testing.T) {
+	// Setup
+	path := filepath.Join(t.TempDir(), "bookings.json")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	at := func(day, hour int) time.Time { return time.Date(2025, 4, day, hour, 0, 0, 0, time.UTC) }
+	anna := Player{Name: "Anna Smith", Email: "anna@example.com"}
+	ben := Player{Name: "Ben Jones", Email: "ben@example.com"}
+	old := Booking{ID: "old", Summary: "Singles", Start: at(1, 9), End: at(1, 10), Players: []Player{anna}}
+	doubles := Booking{ID: "doubles", Summary: "Doubles", Start: at(11, 19), End: at(11, 20), Players: []Player{anna, ben}}
+	dropped := Booking{ID: "dropped", Summary: "Singles", Start: at(12, 9), End: at(12, 10), Players: []Player{ben}}
+	training := Booking{ID: "training", Summary: "Junior training", Start: at(13, 17), End: at(13, 18), Players: []Player{}}
+
+	// Execute
+	if _, err := store.Import(Calendar{Bookings: []Booking{old, doubles, dropped, training}}); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	doubles.Summary = "Doubles final"
+	report, err := store.Import(Calendar{Bookings: []Booking{doubles, training}, Cancelled: []string{"training", "unknown"}})
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if want := (ImportReport{Bookings: 2, Updated: 1, Cancelled: 1, Removed: 1}); report != want {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+	// The booking before the calendar's first is kept
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	all := reopened.List(Filter{})
+	if len(all) != 2 || all[0].ID != "doubles" || all[0].Summary != "Doubles final" || all[1].ID != "old" {
+		t.Errorf("List = %+v, want the doubles final then the old booking", all)
+	}
+	if found := store.List(Filter{Player: "BEN@example.com"}); len(found) != 1 || found[0].ID != "doubles" {
+		t.Errorf("List by player = %+v, want the doubles", found)
+	}
+	if found := store.List(Filter{Email: "ANNA@example.com"}); len(found) != 2 {
+		t.Errorf("List by email = %+v, want both of Anna's bookings", found)
+	}
+	if found := store.List(Filter{Email: "Anna Smith"}); len(found) != 0 {
+		t.Errorf("List by email = %+v, want names not to match", found)
+	}
+	if found := store.List(Filter{Query: "smith singles"}); len(found) != 1 || found[0].ID != "old" {
+		t.Errorf("List by query = %+v, want the old booking", found)
+	}
+	if found := store.List(Filter{From: at(11, 19).Add(30 * time.Minute), To: at(12, 0)}); len(found) != 1 || found[0].ID != "doubles" {
+		t.Errorf("List by range = %+v, want the doubles", found)
+	}
+	if b, found := store.Get("doubles"); !found || !b.HasPlayer("anna smith") || b.HasPlayer("carla") {
+		t.Errorf("Get = %+v, %v, want the doubles with Anna but not Carla", b, found)
+	}
+}
@@ -0,0 +1,429 @@
+package booking
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxOccurrences limits how many bookings one recurring event may expand to
+// within the expanded range
+const maxOccurrences = 1000
+
+// Calendar is what a calendar feed says about the court's bookings
+type Calendar struct {
+	Bookings []Booking
+	// Cancelled are the IDs of bookings the calendar cancelled
+	Cancelled []string
+}
+
+// event is a VEVENT of a calendar
+type event struct {
+	uid          string
+	summary      string
+	location     string
+	cancelled    bool
+	start        time.Time
+	end          time.Time
+	duration     time.Duration
+	allDay       bool
+	players      []Player
+	rule         string
+	exceptions   []time.Time
+	recurrenceID time.Time
+}
+
+// property is a content line of a calendar, like
+// DTSTART;TZID=Europe/Berlin:20250411T190000
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// ParseICS reads the bookings of an iCalendar feed (RFC 5545). Times without
+// a zone are in location, as are times with a TZID that is not a known time
+// zone name. Daily and weekly recurring bookings are expanded from from up to
+// until; other recurrences only have their first booking.
+func ParseICS(r io.Reader, location *time.Location, from, until time.Time) (Calendar, error) {
+	properties, err := readProperties(r)
+	if err != nil {
+		return Calendar{}, err
+	}
+
+	var events []event
+	var current *event
+	depth := 0
+	for _, p := range properties {
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT") && current == nil:
+			current = &event{}
+			depth = 0
+		case current == nil:
+		case p.name == "BEGIN":
+			depth++
+		case p.name == "END" && depth > 0:
+			depth--
+		case p.name == "END":
+			if current.start.IsZero() {
+				return Calendar{}, fmt.Errorf("event %q has no DTSTART", current.uid)
+			}
+			if current.uid == "" {
+				current.uid = current.start.UTC().Format("20060102T150405Z")
+			}
+			events = append(events, *current)
+			current = nil
+		case depth == 0:
+			if err := current.set(p, location); err != nil {
+				return Calendar{}, fmt.Errorf("failed to read %s of event %q: %w", p.name, current.uid, err)
+			}
+		}
+	}
+	if current != nil {
+		return Calendar{}, fmt.Errorf("event %q is not ended", current.uid)
+	}
+
+	// Recurring events come first so the occurrences they override are
+	// known
+	sort.SliceStable(events, func(i, j int) bool { return events[i].recurrenceID.IsZero() && !events[j].recurrenceID.IsZero() })
+	bookings := make(map[string]Booking)
+	var cancelled []string
+	for _, e := range events {
+		if !e.recurrenceID.IsZero() {
+			id := occurrenceID(e.uid, e.recurrenceID)
+			if e.cancelled {
+				delete(bookings, id)
+				cancelled = append(cancelled, id)
+				continue
+			}
+			b := e.booking(e.start)
+			b.ID = id
+			bookings[id] = b
+			continue
+		}
+		for _, start := range e.exceptions {
+			cancelled = append(cancelled, occurrenceID(e.uid, start))
+		}
+		for _, start := range e.occurrences(from, until) {
+			b := e.booking(start)
+			if e.rule == "" {
+				b.ID = e.uid
+			}
+			if e.cancelled {
+				cancelled = append(cancelled, b.ID)
+				continue
+			}
+			bookings[b.ID] = b
+		}
+	}
+
+	calendar := Calendar{Bookings: make([]Booking, 0, len(bookings)), Cancelled: cancelled}
+	for _, b := range bookings {
+		calendar.Bookings = append(calendar.Bookings, b)
+	}
+	sort.Slice(calendar.Bookings, func(i, j int) bool {
+		if !calendar.Bookings[i].Start.Equal(calendar.Bookings[j].Start) {
+			return calendar.Bookings[i].Start.Before(calendar.Bookings[j].Start)
+		}
+		return calendar.Bookings[i].ID < calendar.Bookings[j].ID
+	})
+	return calendar, nil
+}
+
+// readProperties reads the content lines of a calendar, joining folded
+// lines
+func readProperties(r io.Reader) ([]property, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read calendar: %w", err)
+	}
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, fmt.Errorf("not an iCalendar file")
+	}
+
+	properties := make([]property, 0, len(lines))
+	for _, line := range lines {
+		p, err := parseProperty(line)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, p)
+	}
+	return properties, nil
+}
+
+// parseProperty splits a content line into its name, parameters and value
+func parseProperty(line string) (property, error) {
+	quoted := false
+	var parts []string
+	begin := 0
+	for i, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ';' && !quoted:
+			parts = append(parts, line[begin:i])
+			begin = i + 1
+		case c == ':' && !quoted:
+			parts = append(parts, line[begin:i])
+			p := property{name: strings.ToUpper(parts[0]), params: make(map[string]string), value: line[i+1:]}
+			for _, param := range parts[1:] {
+				name, value, _ := strings.Cut(param, "=")
+				p.params[strings.ToUpper(name)] = strings.Trim(value, `"`)
+			}
+			return p, nil
+		}
+	}
+	return property{}, fmt.Errorf("invalid calendar line %q", line)
+}
+
+// set reads a property of the event
+func (e *event) set(p property, location *time.Location) error {
+	var err error
+	switch p.name {
+	case "UID":
+		e.uid = p.value
+	case "SUMMARY":
+		e.summary = unescape(p.value)
+	case "LOCATION":
+		e.location = unescape(p.value)
+	case "STATUS":
+		e.cancelled = strings.EqualFold(p.value, "CANCELLED")
+	case "DTSTART":
+		e.start, e.allDay, err = parseTime(p, location)
+	case "DTEND":
+		e.end, _, err = parseTime(p, location)
+	case "DURATION":
+		e.duration, err = parseDuration(p.value)
+	case "RRULE":
+		e.rule = p.value
+	case "EXDATE":
+		for _, value := range strings.Split(p.value, ",") {
+			var t time.Time
+			if t, _, err = parseTime(property{params: p.params, value: value}, location); err != nil {
+				return err
+			}
+			e.exceptions = append(e.exceptions, t)
+		}
+	case "RECURRENCE-ID":
+		e.recurrenceID, _, err = parseTime(p, location)
+	case "ORGANIZER", "ATTENDEE":
+		player := Player{Name: p.params["CN"]}
+		if email, found := strings.CutPrefix(strings.ToLower(p.value), "mailto:"); found {
+			player.Email = email
+		}
+		if player != (Player{}) && !e.hasPlayer(player) {
+			e.players = append(e.players, player)
+		}
+	}
+	return err
+}
+
+// hasPlayer reports whether the event already has a player, by email
+// address if it is known or else by name
+func (e *event) hasPlayer(player Player) bool {
+	for _, existing := range e.players {
+		if (player.Email != "" && existing.Email == player.Email) || (player.Email == "" && existing.Name == player.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// length returns how long each occurrence of the event is
+func (e event) length() time.Duration {
+	switch {
+	case !e.end.IsZero():
+		return e.end.Sub(e.start)
+	case e.duration > 0:
+		return e.duration
+	case e.allDay:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// booking returns the occurrence of the event starting at start
+func (e event) booking(start time.Time) Booking {
+	players := e.players
+	if players == nil {
+		players = []Player{}
+	}
+	return Booking{
+		ID:       occurrenceID(e.uid, start),
+		Summary:  e.summary,
+		Start:    start.UTC(),
+		End:      start.Add(e.length()).UTC(),
+		Players:  players,
+		Location: e.location,
+	}
+}
+
+// occurrenceID returns the booking ID of the occurrence of an event starting
+// at start
+func occurrenceID(uid string, start time.Time) string {
+	return uid + "/" + start.UTC().Format("20060102T150405Z")
+}
+
+// occurrences returns the starts of the event's occurrences from from up to
+// until, without the ones the event excludes. Occurrences before from still
+// count towards the rule's COUNT.
+func (e event) occurrences(from, until time.Time) []time.Time {
+	rule := make(map[string]string)
+	for _, part := range strings.Split(e.rule, ";") {
+		name, value, _ := strings.Cut(part, "=")
+		rule[strings.ToUpper(name)] = strings.ToUpper(value)
+	}
+	interval, err := strconv.Atoi(rule["INTERVAL"])
+	if err != nil || interval < 1 {
+		interval = 1
+	}
+	count, err := strconv.Atoi(rule["COUNT"])
+	if err != nil || count < 1 {
+		count = 0
+	}
+	if value, found := rule["UNTIL"]; found {
+		if t, _, err := parseTime(property{value: value}, e.start.Location()); err == nil && (until.IsZero() || t.Before(until)) {
+			until = t
+		}
+	}
+
+	// Weekly bookings repeat on the days of BYDAY, or else on the day they
+	// started, counting weeks from Monday
+	var days []int
+	switch rule["FREQ"] {
+	case "DAILY":
+		days = []int{0}
+	case "WEEKLY":
+		for _, day := range strings.Split(rule["BYDAY"], ",") {
+			if offset := strings.Index("MOTUWETHFRSASU", day); day != "" && offset%2 == 0 && len(day) == 2 {
+				days = append(days, offset/2)
+			}
+		}
+		if len(days) == 0 {
+			days = []int{weekdayOffset(e.start)}
+		}
+		sort.Ints(days)
+	default:
+		return []time.Time{e.start}
+	}
+
+	// Periods are days or weeks, counted from the day the event started or
+	// the Monday of its week
+	step, shift := interval, 0
+	if rule["FREQ"] == "WEEKLY" {
+		step, shift = 7*interval, weekdayOffset(e.start)
+	}
+	var starts []time.Time
+	y, m, d := e.start.Date()
+	h, minute, s := e.start.Clock()
+	for n, counted := 0, 0; ; n++ {
+		for _, day := range days {
+			start := time.Date(y, m, d+n*step+day-shift, h, minute, s, e.start.Nanosecond(), e.start.Location())
+			if start.Before(e.start) {
+				continue
+			}
+			if (!until.IsZero() && start.After(until)) || (count > 0 && counted == count) || len(starts) == maxOccurrences {
+				return e.withoutExceptions(starts)
+			}
+			counted++
+			if start.Before(from) {
+				continue
+			}
+			starts = append(starts, start)
+		}
+	}
+}
+
+// withoutExceptions returns the starts without the event's EXDATEs
+func (e event) withoutExceptions(starts []time.Time) []time.Time {
+	kept := starts[:0]
+	for _, start := range starts {
+		excluded := false
+		for _, exception := range e.exceptions {
+			excluded = excluded || exception.Equal(start)
+		}
+		if !excluded {
+			kept = append(kept, start)
+		}
+	}
+	return kept
+}
+
+// weekdayOffset returns how many days t is after the Monday of its week
+func weekdayOffset(t time.Time) int {
+	return (int(t.Weekday()) + 6) % 7
+}
+
+// parseTime reads a DATE or DATE-TIME value, reporting whether it is a date
+func parseTime(p property, location *time.Location) (time.Time, bool, error) {
+	if p.params["VALUE"] == "DATE" || len(p.value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", p.value, location)
+		return t, true, err
+	}
+	if strings.HasSuffix(p.value, "Z") {
+		t, err := time.Parse("20060102T150405Z", p.value)
+		return t, false, err
+	}
+	if tzid := p.params["TZID"]; tzid != "" {
+		if zone, err := time.LoadLocation(tzid); err == nil {
+			location = zone
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", p.value, location)
+	return t, false, err
+}
+
+// parseDuration reads a DURATION value like PT1H30M or P1D
+func parseDuration(value string) (time.Duration, error) {
+	rest, found := strings.CutPrefix(strings.TrimPrefix(value, "+"), "P")
+	if !found || rest == "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour, 'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+	var duration time.Duration
+	inTime := false
+	number := ""
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case c == 'T':
+			inTime = true
+		case c >= '0' && c <= '9':
+			number += string(c)
+		default:
+			n, err := strconv.Atoi(number)
+			unit, known := units[c]
+			if err != nil || !known || (c == 'M' && !inTime) {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			duration += time.Duration(n) * unit
+			number = ""
+		}
+	}
+	if number != "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return duration, nil
+}
+
+// unescape decodes the escapes of a TEXT value
+func unescape(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
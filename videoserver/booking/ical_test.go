@@ -0,0 +1,157 @@
+package booking
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseICS(t *testing.T) {
+	// Setup
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed := strings.ReplaceAll(`BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Club//Court Bookings//EN
+BEGIN:VEVENT
+UID:single@club
+SUMMARY:Court 6 - Doubles\, Smith / Jones
+LOCATION:Court 6
+DTSTART:20250411T170000Z
+DTEND:20250411T183000Z
+ORGANIZER;CN=Anna Smith:mailto:Anna@Example.com
+ATTENDEE;CN="Jones, Ben";ROLE=REQ-PARTICIPANT:mailto:ben@example.com
+ATTENDEE;CN=Anna Smith:mailto:anna@example.com
+BEGIN:VALARM
+TRIGGER:-PT15M
+DESCRIPTION:Reminder
+END:VALARM
+END:VEVENT
+BEGIN:VEVENT
+UID:training@club
+SUMMARY:Junior training
+DTSTART;TZID=Europe/Berlin:20250317T180000
+DURATION:PT1H
+RRULE:FREQ=WEEKLY;BYDAY=MO,TH;COUNT=5
+EXDATE;TZID=Europe/Berlin:20250320T180000
+ATTENDEE;CN=Coach Carla:mailto:carla@example.com
+END:VEVENT
+BEGIN:VEVENT
+UID:training@club
+RECURRENCE-ID;TZID=Europe/Berlin:20250327T180000
+SUMMARY:Junior training (moved)
+DTSTART;TZID=Europe/Berlin:20250327T190000
+DTEND;TZID=Europe/Berlin:20250327T200000
+END:VEVENT
+BEGIN:VEVENT
+UID:training@club
+RECURRENCE-ID;TZID=Europe/Berlin:20250331T180000
+STATUS:CANCELLED
+DTSTART;TZID=Europe/Berlin:20250331T180000
+END:VEVENT
+BEGIN:VEVENT
+UID:cancelled@club
+STATUS:CANCELLED
+SUMMARY:Singles
+DTSTART:20250412T090000
+DTEND:20250412T100000
+END:VEVENT
+BEGIN:VEVENT
+UID:folded@club
+SUMMARY:Club
+  tournament
+DTSTART;VALUE=DATE:20250413
+END:VEVENT
+END:VCALENDAR
+`, "\n", "\r\n")
+
+	// Execute
+	calendar, err := ParseICS(strings.NewReader(feed), berlin, time.Time{}, time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("ParseICS failed: %v", err)
+	}
+	var got []string
+	for _, b := range calendar.Bookings {
+		got = append(got, b.ID+" "+b.Start.In(berlin).Format("Mon 2006-01-02 15:04")+"-"+b.End.In(berlin).Format("15:04")+" "+b.Summary)
+	}
+	want := []string{
+		// The weekly training is kept at 18:00 over the change to summer time
+		"training@club/20250317T170000Z Mon 2025-03-17 18:00-19:00 Junior training",
+		"training@club/20250324T170000Z Mon 2025-03-24 18:00-19:00 Junior training",
+		"training@club/20250327T170000Z Thu 2025-03-27 19:00-20:00 Junior training (moved)",
+		"single@club Fri 2025-04-11 19:00-20:30 Court 6 - Doubles, Smith / Jones",
+		"folded@club Sun 2025-04-13 00:00-00:00 Club tournament",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("bookings =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	single := calendar.Bookings[3]
+	if len(single.Players) != 2 || single.Players[0] != (Player{Name: "Anna Smith", Email: "anna@example.com"}) ||
+		single.Players[1] != (Player{Name: "Jones, Ben", Email: "ben@example.com"}) || single.Location != "Court 6" {
+		t.Errorf("players = %+v at %q, want Anna Smith and Ben Jones at Court 6", single.Players, single.Location)
+	}
+	wantCancelled := "training@club/20250320T170000Z cancelled@club training@club/20250331T160000Z"
+	if got := strings.Join(calendar.Cancelled, " "); got != wantCancelled {
+		t.Errorf("Cancelled = %s, want %s", got, wantCancelled)
+	}
+}
+
+func TestParseICS_LongRunningSeries(t *testing.T) {
+	// Setup
+	feed := `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:daily@club
+DTSTART:20190107T170000Z
+DURATION:PT1H
+RRULE:FREQ=DAILY
+END:VEVENT
+BEGIN:VEVENT
+UID:weekdays@club
+DTSTART:20190107T180000Z
+DURATION:PT1H
+RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;COUNT=2000
+END:VEVENT
+END:VCALENDAR
+`
+	from := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)
+
+	// Execute
+	calendar, err := ParseICS(strings.NewReader(feed), time.UTC, from, until)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("ParseICS failed: %v", err)
+	}
+	var daily, weekdays int
+	for _, b := range calendar.Bookings {
+		if b.Start.Before(from) || b.Start.After(until) {
+			t.Errorf("booking %s outside of %v to %v", b.ID, from, until)
+		}
+		if strings.HasPrefix(b.ID, "daily@club/") {
+			daily++
+		} else {
+			weekdays++
+		}
+	}
+	if daily != 7 || weekdays != 5 {
+		t.Errorf("daily, weekdays = %d, %d, want 7, 5", daily, weekdays)
+	}
+}
+
+func TestParseICS_Invalid(t *testing.T) {
+	for name, feed := range map[string]string{
+		"not a calendar": "hello",
+		"no start":       "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:a\nEND:VEVENT\nEND:VCALENDAR\n",
+		"bad duration":   "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:a\nDTSTART:20250411T170000Z\nDURATION:1H\nEND:VEVENT\nEND:VCALENDAR\n",
+		"not ended":      "BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:a\nDTSTART:20250411T170000Z\n",
+	} {
+		if _, err := ParseICS(strings.NewReader(feed), time.UTC, time.Time{}, time.Time{}); err == nil {
+			t.Errorf("ParseICS accepted %s", name)
+		}
+	}
+}
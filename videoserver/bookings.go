@@ -0,0 +1,430 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"archive/archiverepo"
+	"archive/playlist"
+	"videoserver/audit"
+	"videoserver/auth"
+	"videoserver/booking"
+	"videoserver/catalog"
+)
+
+const (
+	// bookingHistory and bookingHorizon are how far back and ahead recurring
+	// bookings are expanded
+	bookingHistory = 365 * 24 * time.Hour
+	bookingHorizon = 60 * 24 * time.Hour
+	// defaultBookingResults and maxBookingResults limit how many bookings a
+	// search returns
+	defaultBookingResults = 50
+	maxBookingResults     = 500
+)
+
+// bookingsFile returns the booking file from BOOKINGS_FILE, defaulting to
+// /data/bookings.json
+func bookingsFile() string {
+	if path, found := os.LookupEnv("BOOKINGS_FILE"); found {
+		return path
+	}
+	return "/data/bookings.json"
+}
+
+// openBookings opens the booking file
+func openBookings() *booking.Store {
+	bookings, err := booking.Open(bookingsFile())
+	if err != nil {
+		log.Fatalf("Error: Unable to open BOOKINGS_FILE: %v\n", err)
+	}
+	return bookings
+}
+
+// bookingsCourt returns the court from BOOKINGS_COURT. When it is set, only
+// bookings whose location names the court are imported.
+func bookingsCourt() string {
+	return os.Getenv("BOOKINGS_COURT")
+}
+
+// bookingsRefresh returns how often the server imports BOOKINGS_URL from
+// BOOKINGS_REFRESH, defaulting to 15 minutes
+func bookingsRefresh() time.Duration {
+	value, found := os.LookupEnv("BOOKINGS_REFRESH")
+	if !found {
+		return 15 * time.Minute
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		log.Fatalf("Error: invalid BOOKINGS_REFRESH %q\n", value)
+	}
+	return interval
+}
+
+// restrictToBookings reports whether viewers only see the footage of their
+// own bookings, which RESTRICT_TO_BOOKINGS=true turns on
+func restrictToBookings() bool {
+	return os.Getenv("RESTRICT_TO_BOOKINGS") == "true"
+}
+
+// readCalendar reads the iCalendar feed at an http(s) URL or in a file
+func readCalendar(ctx context.Context, source string, location *time.Location, now time.Time) (booking.Calendar, error) {
+	var content io.ReadCloser
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return booking.Calendar{}, fmt.Errorf("failed to request calendar: %w", err)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return booking.Calendar{}, fmt.Errorf("failed to fetch calendar: %w", err)
+		}
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			return booking.Calendar{}, fmt.Errorf("failed to fetch calendar: %s", response.Status)
+		}
+		content = response.Body
+	} else {
+		file, err := os.Open(source)
+		if err != nil {
+			return booking.Calendar{}, fmt.Errorf("failed to open calendar: %w", err)
+		}
+		content = file
+	}
+	defer content.Close()
+	return booking.ParseICS(content, location, now.Add(-bookingHistory), now.Add(bookingHorizon))
+}
+
+// importBookings imports the bookings of the court from a calendar feed.
+// Without a court, every booking is imported.
+func importBookings(ctx context.Context, bookings *booking.Store, source, court string, location *time.Location, now time.Time) (booking.ImportReport, error) {
+	calendar, err := readCalendar(ctx, source, location, now)
+	if err != nil {
+		return booking.ImportReport{}, err
+	}
+	if court != "" {
+		onCourt := calendar.Bookings[:0]
+		for _, b := range calendar.Bookings {
+			if strings.Contains(strings.ToLower(b.Location), strings.ToLower(court)) {
+				onCourt = append(onCourt, b)
+			}
+		}
+		calendar.Bookings = onCourt
+	}
+	return bookings.Import(calendar)
+}
+
+// refreshBookings imports a calendar feed every interval
+func refreshBookings(bookings *booking.Store, source, court string, location *time.Location, interval time.Duration) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		report, err := importBookings(ctx, bookings, source, court, location, time.Now())
+		cancel()
+		if err != nil {
+			log.Printf("Failed to import bookings from %s: %v\n", source, err)
+		} else if report.Added+report.Updated+report.Cancelled+report.Removed > 0 {
+			log.Printf("Imported bookings: %d added, %d updated, %d cancelled, %d removed\n",
+				report.Added, report.Updated, report.Cancelled, report.Removed)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// runBookings imports court bookings from an iCalendar feed and lists them
+func runBookings(args []string) {
+	flags := flag.NewFlagSet("bookings", flag.ExitOnError)
+	file := flags.String("file", bookingsFile(), "booking file")
+	court := flags.String("court", bookingsCourt(), "only import bookings whose location names this court")
+	query := flags.String("q", "", "only list bookings matching these words")
+	player := flags.String("player", "", "only list bookings of this player's name or email address")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: videoserver bookings [flags] import FILE|URL|list")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	bookings, err := booking.Open(*file)
+	if err != nil {
+		log.Fatalf("Error: Unable to open bookings: %v\n", err)
+	}
+
+	location := clubLocation()
+	command, sources := flags.Arg(0), flags.Args()[min(1, flags.NArg()):]
+	switch {
+	case command == "import" && len(sources) == 1:
+		report, err := importBookings(context.Background(), bookings, sources[0], *court, location, time.Now())
+		if err != nil {
+			log.Fatalf("Error: Unable to import bookings: %v\n", err)
+		}
+		fmt.Printf("Imported %d bookings: %d added, %d updated, %d cancelled, %d removed\n",
+			report.Bookings, report.Added, report.Updated, report.Cancelled, report.Removed)
+	case command == "list" && len(sources) == 0:
+		for _, b := range bookings.List(booking.Filter{Query: *query, Player: *player}) {
+			players := make([]string, len(b.Players))
+			for i, p := range b.Players {
+				players[i] = p.String()
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\n", b.ID, b.Start.In(location).Format(time.RFC3339),
+				b.End.In(location).Format(time.RFC3339), b.Summary, strings.Join(players, ", "))
+		}
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// bookingAccess limits viewers to the footage of the bookings they played
+// in when it is enabled, going by the email address in their user record.
+// Coaches and admins see all footage.
+type bookingAccess struct {
+	enabled  bool
+	bookings *booking.Store
+	catalog  *catalog.Catalog
+	now      func() time.Time
+}
+
+// restricted returns the signed in user and whether their footage is
+// limited to their bookings
+func (a bookingAccess) restricted(r *http.Request) (auth.User, bool) {
+	user, _ := auth.UserFromContext(r.Context())
+	return user, a.enabled && !user.Role.Allows(auth.RoleCoach)
+}
+
+// bookingsOf returns the bookings overlapping from-to that a user played
+// in. Users without an email address aren't matched to any booking.
+func (a bookingAccess) bookingsOf(user auth.User, from, to time.Time) []booking.Booking {
+	if user.Email == "" {
+		return nil
+	}
+	return a.bookings.List(booking.Filter{From: from, To: to, Email: user.Email})
+}
+
+// allows reports whether a user played in a booking overlapping the footage
+// from-to
+func (a bookingAccess) allows(user auth.User, from, to time.Time) bool {
+	return len(a.bookingsOf(user, from, to)) > 0
+}
+
+// during reports whether one of the bookings overlaps the footage from-to
+func during(bookings []booking.Booking, from, to time.Time) bool {
+	return slices.ContainsFunc(bookings, func(b booking.Booking) bool {
+		return b.Overlaps(from, to)
+	})
+}
+
+// archive wraps the archive file server, only serving restricted users the
+// segments recorded during their bookings. Their hour playlists have gaps
+// in place of other footage, so the hour keeps its timeline. Thumbnails,
+// I-frame and master playlists cover the whole hour, so they don't get
+// them.
+func (a bookingAccess) archive(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, restricted := a.restricted(r)
+		if !restricted {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		key := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		hour, err := hourOfKey(key)
+		var bookings []booking.Booking
+		if err == nil {
+			bookings = a.bookingsOf(user, hour, hour.Add(time.Hour))
+		}
+		if len(bookings) == 0 {
+			http.Error(w, "Forbidden: footage outside your bookings", http.StatusForbidden)
+			return
+		}
+		switch name := path.Base(key); {
+		case name == archiverepo.GapName || name == archiverepo.SlateName:
+			handler.ServeHTTP(w, r)
+		case name == archiverepo.PlaylistName:
+			a.hourPlaylist(w, r, bookings, hour)
+		case path.Ext(key) == ".ts":
+			a.segment(w, r, handler, bookings, hour, key)
+		default:
+			http.Error(w, "Forbidden: only the footage of your bookings is available", http.StatusForbidden)
+		}
+	})
+}
+
+// hourPlaylist responds with the playlist of an hour, with gaps in place of
+// the footage outside a user's bookings during it
+func (a bookingAccess) hourPlaylist(w http.ResponseWriter, r *http.Request, bookings []booking.Booking, hour time.Time) {
+	hourPlaylist, err := a.catalog.Playlist(r.Context(), hour)
+	if errors.Is(err, catalog.ErrNotArchived) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read the playlist of %s: %v\n", archiverepo.HourPath(hour), err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	for i, entry := range hourPlaylist.Segments {
+		segment := catalog.Segment{DateTime: entry.DateTime, Duration: entry.Duration}
+		if archiverepo.IsPlaceholder(entry) || during(bookings, segment.DateTime, segment.End()) {
+			continue
+		}
+		hourPlaylist.Segments[i].Filename, hourPlaylist.Segments[i].Gap = archiverepo.GapName, true
+		hourPlaylist.Version = max(hourPlaylist.Version, playlist.GapVersion)
+	}
+	w.Header().Set("Content-Type", contentTypes[".m3u8"])
+	w.Write([]byte(hourPlaylist.String()))
+}
+
+// segment serves an archived segment of an hour if it was recorded during
+// one of a user's bookings in it
+func (a bookingAccess) segment(w http.ResponseWriter, r *http.Request, handler http.Handler, bookings []booking.Booking, hour time.Time, key string) {
+	hourPlaylist, err := a.catalog.Playlist(r.Context(), hour)
+	if errors.Is(err, catalog.ErrNotArchived) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read the playlist of %s: %v\n", archiverepo.HourPath(hour), err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	hourPath := archiverepo.HourPath(hour)
+	for _, entry := range hourPlaylist.Segments {
+		segment := catalog.Segment{DateTime: entry.DateTime, Duration: entry.Duration}
+		if path.Join(hourPath, entry.Filename) == key && !archiverepo.IsPlaceholder(entry) && during(bookings, segment.DateTime, segment.End()) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+	}
+	http.Error(w, "Forbidden: footage outside your bookings", http.StatusForbidden)
+}
+
+// live wraps the live stream, only serving restricted users while one of
+// their bookings is on
+func (a bookingAccess) live(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, restricted := a.restricted(r); restricted {
+			now := a.now()
+			if !a.allows(user, now, now) {
+				http.Error(w, "Forbidden: the live stream is only open during your bookings", http.StatusForbidden)
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// bookingsAPI finds recordings by the court booking they were made during
+type bookingsAPI struct {
+	bookings *booking.Store
+	catalog  *catalog.Catalog
+	access   bookingAccess
+	now      func() time.Time
+}
+
+// bookingSummary is a booking with its times in the club's time zone, how
+// much of it was recorded, and the playlist of its footage
+type bookingSummary struct {
+	booking.Booking
+	LocalStart string  `json:"localStart"`
+	LocalEnd   string  `json:"localEnd"`
+	Recorded   float64 `json:"recorded"`
+	Playlist   string  `json:"playlist"`
+}
+
+// list handles GET /api/bookings, searching bookings by the words in the q
+// parameter, a player's name or email address, and the from and to
+// parameters, latest first. Restricted users only find their own bookings.
+func (a bookingsAPI) list(w http.ResponseWriter, r *http.Request) {
+	filter := booking.Filter{Query: r.FormValue("q"), Player: r.FormValue("player")}
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := r.FormValue(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := a.catalog.ParseTime(value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad Request: %s: %v", bound.name, err), http.StatusBadRequest)
+			return
+		}
+		*bound.t = t
+	}
+	limit := defaultBookingResults
+	if value := r.FormValue("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxBookingResults {
+			http.Error(w, fmt.Sprintf("Bad Request: limit must be from 1 to %d", maxBookingResults), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	if user, restricted := a.access.restricted(r); restricted {
+		if user.Email == "" || (filter.Player != "" && !strings.EqualFold(filter.Player, user.Email)) {
+			writeJSON(w, []bookingSummary{})
+			return
+		}
+		filter.Player, filter.Email = "", user.Email
+	}
+
+	found := a.bookings.List(filter)
+	summaries := make([]bookingSummary, 0, min(limit, len(found)))
+	for _, b := range found[:min(limit, len(found))] {
+		segments, err := a.catalog.Segments(r.Context(), b.Start, b.End)
+		if err != nil {
+			log.Printf("Failed to list the segments of booking %s: %v\n", b.ID, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		var recorded float64
+		for _, segment := range segments {
+			recorded += segment.Duration
+		}
+		location := a.catalog.Location()
+		summaries = append(summaries, bookingSummary{
+			Booking:    b,
+			LocalStart: b.Start.In(location).Format(time.RFC3339),
+			LocalEnd:   b.End.In(location).Format(time.RFC3339),
+			Recorded:   recorded,
+			Playlist:   "/api/bookings/" + url.PathEscape(b.ID) + "/playlist.m3u8",
+		})
+	}
+	writeJSON(w, summaries)
+}
+
+// playlist handles GET /api/bookings/{id}/playlist.m3u8 with a playlist of
+// the footage archived during a booking. Restricted users can only play
+// their own bookings.
+func (a bookingsAPI) playlist(w http.ResponseWriter, r *http.Request) {
+	b, found := a.bookings.Get(r.PathValue("id"))
+	if user, restricted := a.access.restricted(r); !found || (restricted && !b.HasEmail(user.Email)) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	audit.SetRange(r.Context(), b.Start, b.End)
+
+	segments, err := a.catalog.Segments(r.Context(), b.Start, b.End)
+	if err != nil {
+		log.Printf("Failed to list the segments of booking %s: %v\n", b.ID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if len(segments) == 0 {
+		http.Error(w, "Not Found: no footage was archived during this booking", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypes[".m3u8"])
+	w.Write([]byte(segmentPlaylist(segments, "/archive/", !b.End.After(a.now()))))
+}
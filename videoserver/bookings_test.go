@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"archive/objectstore"
+	"videoserver/auth"
+	"videoserver/booking"
+	"videoserver/bookmark"
+	"videoserver/catalog"
+)
+
+func TestBookingsAPI(t *testing.T) {
+	// Setup
+	basePath := t.TempDir()
+	dir := filepath.Join(basePath, "2025", "04", "11", "19")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"playlist.m3u8": `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T19:29:50.000+0000
+segment_000.ts
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T19:30:00.000+0000
+segment_001.ts
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T19:30:10.000+0000
+segment_002.ts
+`,
+		"segment_000.ts":     "0",
+		"segment_001.ts":     "1",
+		"segment_002.ts":     "2",
+		"thumbnails.vtt":     "WEBVTT\n",
+		"thumbnails_000.jpg": "jpeg",
+		"iframes.m3u8":       "#EXTM3U\n",
+		"master.m3u8":        "#EXTM3U\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	calendar := filepath.Join(t.TempDir(), "bookings.ics")
+	if err := os.WriteFile(calendar, []byte(`BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:doubles@club
+SUMMARY:Doubles
+LOCATION:Court 6
+DTSTART:20250411T193000Z
+DTEND:20250411T203000Z
+ATTENDEE;CN=Anna Smith:mailto:anna@example.com
+ATTENDEE;CN=Ben Jones:mailto:ben@example.com
+END:VEVENT
+BEGIN:VEVENT
+UID:singles@club
+SUMMARY:Singles
+LOCATION:Court 6
+DTSTART:20250411T180000Z
+DTEND:20250411T193000Z
+ATTENDEE;CN=Carla:mailto:carla@example.com
+END:VEVENT
+BEGIN:VEVENT
+UID:other@club
+SUMMARY:Singles
+LOCATION:Court 2
+DTSTART:20250411T193000Z
+DTEND:20250411T203000Z
+ATTENDEE;CN=Anna Smith:mailto:anna@example.com
+END:VEVENT
+END:VCALENDAR
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := booking.Open(filepath.Join(t.TempDir(), "bookings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := func() time.Time { return time.Date(2025, 4, 11, 20, 0, 0, 0, time.UTC) }
+	report, err := importBookings(context.Background(), store, calendar, "court 6", time.UTC, now())
+	if err != nil {
+		t.Fatalf("importBookings failed: %v", err)
+	}
+	if report.Added != 2 {
+		t.Errorf("report = %+v, want the 2 bookings of court 6 added", report)
+	}
+
+	archiveStore := objectstore.NewFilesystem(basePath)
+	cat := catalog.New(archiveStore, time.UTC)
+	access := bookingAccess{enabled: true, bookings: store, catalog: cat, now: now}
+	bookings := bookingsAPI{bookings: store, catalog: cat, access: access, now: now}
+	marks, err := bookmark.Open(filepath.Join(t.TempDir(), "bookmarks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, at := range []time.Time{time.Date(2025, 4, 11, 19, 0, 0, 0, time.UTC), time.Date(2025, 4, 11, 19, 45, 0, 0, time.UTC)} {
+		if _, err := marks.Create(at, "coach", []string{"rally"}, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bookmarks := bookmarksAPI{bookmarks: marks, catalog: cat, access: access, now: now}
+	live := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("live")) })
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/bookings", bookings.list)
+	mux.HandleFunc("GET /api/bookings/{id}/playlist.m3u8", bookings.playlist)
+	mux.HandleFunc("GET /api/bookmarks", bookmarks.list)
+	mux.Handle("GET /archive/", http.StripPrefix("/archive", access.archive(archiveFiles{store: archiveStore})))
+	mux.Handle("GET /stream/", access.live(live))
+	as := func(user auth.User, target string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", target, nil)
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, request.WithContext(auth.WithUser(request.Context(), user)))
+		return recorder
+	}
+	anna := auth.User{Name: "anna", Email: "ANNA@example.com", Role: auth.RoleViewer}
+	carla := auth.User{Name: "carla", Email: "carla@example.com", Role: auth.RoleViewer}
+	// Only the email address in the user record matches someone to a
+	// booking, never their user name
+	unmatched := auth.User{Name: "Carla", Role: auth.RoleViewer}
+	coach := auth.User{Name: "coach", Role: auth.RoleCoach}
+
+	// Execute
+	listed := as(coach, "/api/bookings?q=doubles")
+
+	// Assert
+	var summaries []bookingSummary
+	if err := json.Unmarshal(listed.Body.Bytes(), &summaries); err != nil {
+		t.Fatalf("GET /api/bookings = %d %s", listed.Code, listed.Body.String())
+	}
+	if len(summaries) != 1 || summaries[0].ID != "doubles@club" || summaries[0].Recorded != 20 ||
+		summaries[0].Playlist != "/api/bookings/doubles@club/playlist.m3u8" {
+		t.Fatalf("GET /api/bookings?q=doubles = %+v, want the doubles with 20s recorded", summaries)
+	}
+	playlist := as(coach, summaries[0].Playlist)
+	if body := playlist.Body.String(); playlist.Code != http.StatusOK || !strings.Contains(body, "/archive/2025/04/11/19/segment_001.ts") ||
+		strings.Contains(body, "segment_000.ts") || strings.Contains(body, "#EXT-X-ENDLIST") {
+		t.Errorf("playlist = %d\n%s\nwant segment_001.ts onwards, still open", playlist.Code, body)
+	}
+
+	// Restricted viewers only find and play their own bookings
+	if own := as(carla, "/api/bookings"); !strings.Contains(own.Body.String(), "singles@club") || strings.Contains(own.Body.String(), "doubles@club") {
+		t.Errorf("GET /api/bookings as Carla = %s, want only the singles", own.Body.String())
+	}
+	if others := as(carla, "/api/bookings?player=anna@example.com"); strings.TrimSpace(others.Body.String()) != "[]" {
+		t.Errorf("GET /api/bookings?player=anna as Carla = %s, want none", others.Body.String())
+	}
+	if none := as(unmatched, "/api/bookings"); strings.TrimSpace(none.Body.String()) != "[]" {
+		t.Errorf("GET /api/bookings as a user without an email address = %s, want none", none.Body.String())
+	}
+	if forbidden := as(carla, summaries[0].Playlist); forbidden.Code != http.StatusNotFound {
+		t.Errorf("playlist of someone else's booking = %d, want %d", forbidden.Code, http.StatusNotFound)
+	}
+	for _, tc := range []struct {
+		user auth.User
+		path string
+		want int
+	}{
+		{anna, "/archive/2025/04/11/19/segment_001.ts", http.StatusOK},
+		{anna, "/archive/2025/04/11/19/segment_000.ts", http.StatusForbidden},
+		{anna, "/archive/2025/04/11/19/playlist.m3u8", http.StatusOK},
+		{anna, "/archive/2025/04/11/19/thumbnails.vtt", http.StatusForbidden},
+		{anna, "/archive/2025/04/11/19/thumbnails_000.jpg", http.StatusForbidden},
+		{anna, "/archive/2025/04/11/19/iframes.m3u8", http.StatusForbidden},
+		{anna, "/archive/2025/04/11/19/master.m3u8", http.StatusForbidden},
+		{coach, "/archive/2025/04/11/19/thumbnails.vtt", http.StatusOK},
+		{carla, "/archive/2025/04/11/19/segment_000.ts", http.StatusOK},
+		{carla, "/archive/2025/04/11/19/segment_001.ts", http.StatusForbidden},
+		{unmatched, "/archive/2025/04/11/19/segment_000.ts", http.StatusForbidden},
+		{coach, "/archive/2025/04/11/19/segment_000.ts", http.StatusOK},
+		{anna, "/archive/2025/04/11/18/playlist.m3u8", http.StatusForbidden},
+		{anna, "/stream/live.m3u8", http.StatusOK},
+		{carla, "/stream/live.m3u8", http.StatusForbidden},
+	} {
+		if got := as(tc.user, tc.path); got.Code != tc.want {
+			t.Errorf("GET %s as %s = %d, want %d", tc.path, tc.user.Name, got.Code, tc.want)
+		}
+	}
+
+	// Restricted viewers only see the bookmarks made during their bookings
+	for _, tc := range []struct {
+		user auth.User
+		want int
+	}{{anna, 1}, {carla, 1}, {coach, 2}} {
+		var listed []bookmarkSummary
+		if err := json.Unmarshal(as(tc.user, "/api/bookmarks").Body.Bytes(), &listed); err != nil || len(listed) != tc.want {
+			t.Errorf("GET /api/bookmarks as %s = %d bookmarks, want %d", tc.user.Name, len(listed), tc.want)
+		}
+	}
+
+	// Restricted viewers' hour playlists have gaps in place of footage
+	// outside their bookings
+	hourPlaylist := as(anna, "/archive/2025/04/11/19/playlist.m3u8").Body.String()
+	if !strings.Contains(hourPlaylist, "#EXT-X-GAP\n#EXTINF:10.000000,\n#EXT-X-PROGRAM-DATE-TIME:2025-04-11T19:29:50.000+0000\ngap.ts\n") ||
+		!strings.Contains(hourPlaylist, "\nsegment_001.ts\n") || strings.Contains(hourPlaylist, "segment_000.ts") {
+		t.Errorf("hour playlist as anna =\n%s\nwant a gap in place of segment_000.ts", hourPlaylist)
+	}
+}
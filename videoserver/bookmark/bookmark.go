@@ -0,0 +1,161 @@
+// Package bookmark keeps the moments people tagged in the footage, such as
+// a great rally, so they can be found again
+package bookmark
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"videoserver/jsonfile"
+)
+
+// ErrUnknown is returned when changing a bookmark that does not exist
+var ErrUnknown = errors.New("unknown bookmark")
+
+// maxNoteLength limits how long a bookmark's note may be
+const maxNoteLength = 1000
+
+// Bookmark is a moment in the footage, at the PROGRAM-DATE-TIME of the
+// archive playlists
+type Bookmark struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Author  string    `json:"author"`
+	Tags    []string  `json:"tags"`
+	Note    string    `json:"note,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// HasTag reports whether the bookmark has a tag, ignoring case
+func (b Bookmark) HasTag(tag string) bool {
+	return slices.Contains(b.Tags, normalizeTag(tag))
+}
+
+// Filter picks bookmarks. Zero fields match every bookmark.
+type Filter struct {
+	// From and To limit the bookmarks to moments from From until before To
+	From time.Time
+	To   time.Time
+	Tag  string
+	// Author limits the bookmarks to the ones a user made
+	Author string
+}
+
+// matches reports whether a bookmark passes the filter
+func (f Filter) matches(b Bookmark) bool {
+	return (f.From.IsZero() || !b.Time.Before(f.From)) &&
+		(f.To.IsZero() || b.Time.Before(f.To)) &&
+		(f.Tag == "" || b.HasTag(f.Tag)) &&
+		(f.Author == "" || b.Author == f.Author)
+}
+
+// Store keeps bookmarks in a JSON file, in the order of their moments
+type Store struct {
+	bookmarks *jsonfile.Store[Bookmark]
+	now       func() time.Time
+}
+
+// Open opens the bookmark file at path. A missing file is an empty store.
+func Open(path string) (*Store, error) {
+	bookmarks, err := jsonfile.Open(path, func(b Bookmark) string { return b.ID }, compare)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{bookmarks: bookmarks, now: time.Now}, nil
+}
+
+// Create adds a bookmark by author at a time, giving it an ID. Tags are
+// kept in lower case, without duplicates.
+func (s *Store) Create(t time.Time, author string, tags []string, note string) (Bookmark, error) {
+	if t.IsZero() {
+		return Bookmark{}, errors.New("bookmark has no time")
+	}
+	if author == "" {
+		return Bookmark{}, errors.New("bookmark has no author")
+	}
+	if len(note) > maxNoteLength {
+		return Bookmark{}, fmt.Errorf("note is longer than %d characters", maxNoteLength)
+	}
+	bookmark := Bookmark{
+		ID:      randomID(),
+		Time:    t.UTC(),
+		Author:  author,
+		Tags:    []string{},
+		Note:    strings.TrimSpace(note),
+		Created: s.now().UTC(),
+	}
+	for _, tag := range tags {
+		if tag = normalizeTag(tag); tag != "" && !slices.Contains(bookmark.Tags, tag) {
+			bookmark.Tags = append(bookmark.Tags, tag)
+		}
+	}
+	sort.Strings(bookmark.Tags)
+
+	err := s.bookmarks.Update(func(bookmarks map[string]Bookmark) error {
+		if _, found := bookmarks[bookmark.ID]; found {
+			return fmt.Errorf("bookmark id %s is taken, try again", bookmark.ID)
+		}
+		bookmarks[bookmark.ID] = bookmark
+		return nil
+	})
+	if err != nil {
+		return Bookmark{}, err
+	}
+	return bookmark, nil
+}
+
+// Get returns the bookmark with an ID
+func (s *Store) Get(id string) (Bookmark, bool) {
+	return s.bookmarks.Get(id)
+}
+
+// List returns the bookmarks that pass a filter, in the order of their
+// moments
+func (s *Store) List(filter Filter) []Bookmark {
+	bookmarks := []Bookmark{}
+	for _, bookmark := range s.bookmarks.List() {
+		if filter.matches(bookmark) {
+			bookmarks = append(bookmarks, bookmark)
+		}
+	}
+	return bookmarks
+}
+
+// Delete removes a bookmark
+func (s *Store) Delete(id string) error {
+	return s.bookmarks.Update(func(bookmarks map[string]Bookmark) error {
+		if _, found := bookmarks[id]; !found {
+			return fmt.Errorf("%w: %s", ErrUnknown, id)
+		}
+		delete(bookmarks, id)
+		return nil
+	})
+}
+
+// compare orders bookmarks by their moments
+func compare(a, b Bookmark) int {
+	if c := a.Time.Compare(b.Time); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// normalizeTag returns a tag as it is stored
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// randomID returns a random bookmark ID
+func randomID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,66 @@
+package bookmark
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_CreateListDelete(t *testing.T) {
+	// Setup
+	path := filepath.Join(t.TempDir(), "bookmarks.json")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	rally := time.Date(2025, 4, 11, 19, 42, 10, 0, time.UTC)
+
+	// Execute
+	created, err := store.Create(rally, "coach", []string{"Great Rally", " serve ", "great rally", ""}, " watch the footwork ")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := store.Create(rally.Add(-time.Hour), "player", []string{"serve"}, ""); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := store.Create(rally, "", nil, ""); err == nil {
+		t.Error("Create accepted a bookmark without an author")
+	}
+
+	// Assert
+	if got, want := fmt.Sprint(created.Tags), "[great rally serve]"; got != want {
+		t.Errorf("Tags = %s, want %s", got, want)
+	}
+	if created.Note != "watch the footwork" || created.ID == "" {
+		t.Errorf("bookmark = %+v, want a trimmed note and an ID", created)
+	}
+	// Another process sees the bookmarks
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if all := reopened.List(Filter{}); len(all) != 2 || all[0].Author != "player" || all[1].ID != created.ID {
+		t.Errorf("List = %+v, want the player's then the coach's bookmark", all)
+	}
+	if tagged := store.List(Filter{Tag: "Great Rally"}); len(tagged) != 1 || tagged[0].ID != created.ID {
+		t.Errorf("List by tag = %+v, want the coach's bookmark", tagged)
+	}
+	if inRange := store.List(Filter{From: rally.Add(-time.Minute), To: rally.Add(time.Minute), Author: "player"}); len(inRange) != 0 {
+		t.Errorf("List by range and author = %+v, want none", inRange)
+	}
+
+	// Execute
+	if err := reopened.Delete(created.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// Assert
+	if _, found := store.Get(created.ID); found {
+		t.Error("Get found a deleted bookmark")
+	}
+	if err := store.Delete(created.ID); !errors.Is(err, ErrUnknown) {
+		t.Errorf("Delete again = %v, want %v", err, ErrUnknown)
+	}
+}
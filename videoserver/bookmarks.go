@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"videoserver/audit"
+	"videoserver/auth"
+	"videoserver/bookmark"
+	"videoserver/catalog"
+)
+
+const (
+	// defaultClipMargin is how much footage a bookmark's clip has on each
+	// side of its moment unless asked otherwise
+	defaultClipMargin = 30 * time.Second
+	// maxClipMargin limits how much footage a bookmark's clip may have on
+	// each side of its moment
+	maxClipMargin = 10 * time.Minute
+)
+
+// bookmarksFile returns the bookmark file from BOOKMARKS_FILE, defaulting to
+// /data/bookmarks.json
+func bookmarksFile() string {
+	if path, found := os.LookupEnv("BOOKMARKS_FILE"); found {
+		return path
+	}
+	return "/data/bookmarks.json"
+}
+
+// openBookmarks opens the bookmark file
+func openBookmarks() *bookmark.Store {
+	bookmarks, err := bookmark.Open(bookmarksFile())
+	if err != nil {
+		log.Fatalf("Error: Unable to open BOOKMARKS_FILE: %v\n", err)
+	}
+	return bookmarks
+}
+
+// bookmarksAPI lets viewers tag moments in the footage and find them again
+type bookmarksAPI struct {
+	bookmarks *bookmark.Store
+	catalog   *catalog.Catalog
+	access    bookingAccess
+	now       func() time.Time
+}
+
+// bookmarkSummary is a bookmark with its time in the club's time zone and
+// the playlist of the footage around it
+type bookmarkSummary struct {
+	bookmark.Bookmark
+	Local string `json:"local"`
+	Clip  string `json:"clip"`
+}
+
+// summarize returns a bookmark as the API shows it
+func (a bookmarksAPI) summarize(b bookmark.Bookmark) bookmarkSummary {
+	return bookmarkSummary{
+		Bookmark: b,
+		Local:    b.Time.In(a.catalog.Location()).Format(time.RFC3339),
+		Clip:     "/api/bookmarks/" + b.ID + "/clip.m3u8",
+	}
+}
+
+// list handles GET /api/bookmarks, optionally limited to the moments
+// between the from and to parameters, a tag, or an author. Restricted users
+// only get the bookmarks made during their bookings.
+func (a bookmarksAPI) list(w http.ResponseWriter, r *http.Request) {
+	filter := bookmark.Filter{Tag: r.FormValue("tag"), Author: r.FormValue("author")}
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := r.FormValue(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := a.catalog.ParseTime(value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad Request: %s: %v", bound.name, err), http.StatusBadRequest)
+			return
+		}
+		*bound.t = t
+	}
+
+	user, restricted := a.access.restricted(r)
+	summaries := []bookmarkSummary{}
+	for _, b := range a.bookmarks.List(filter) {
+		if restricted && !a.access.allows(user, b.Time, b.Time) {
+			continue
+		}
+		summaries = append(summaries, a.summarize(b))
+	}
+	writeJSON(w, summaries)
+}
+
+// create handles POST /api/bookmarks, bookmarking the moment in the t
+// parameter for the signed in user with the comma separated tags parameter
+// and the note parameter
+func (a bookmarksAPI) create(w http.ResponseWriter, r *http.Request) {
+	t, err := a.catalog.ParseTime(r.FormValue("t"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: t: %v", err), http.StatusBadRequest)
+		return
+	}
+	var tags []string
+	for _, value := range r.Form["tags"] {
+		tags = append(tags, strings.Split(value, ",")...)
+	}
+	user, _ := auth.UserFromContext(r.Context())
+
+	created, err := a.bookmarks.Create(t, user.Name, tags, r.FormValue("note"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: %v", err), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, a.summarize(created))
+}
+
+// delete handles DELETE /api/bookmarks/{id}. Only the author of a bookmark
+// and admins may delete it.
+func (a bookmarksAPI) delete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	existing, found := a.bookmarks.Get(id)
+	if !found {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	user, _ := auth.UserFromContext(r.Context())
+	if existing.Author != user.Name && !user.Role.Allows(auth.RoleAdmin) {
+		http.Error(w, "Forbidden: only the author or an admin may delete a bookmark", http.StatusForbidden)
+		return
+	}
+
+	err := a.bookmarks.Delete(id)
+	if errors.Is(err, bookmark.ErrUnknown) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to delete bookmark %s: %v\n", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clip handles GET /api/bookmarks/{id}/clip.m3u8 with a playlist of the
+// archived footage around a bookmark, from the before parameter ahead of
+// its moment to the after parameter past it
+func (a bookmarksAPI) clip(w http.ResponseWriter, r *http.Request) {
+	b, found := a.bookmarks.Get(r.PathValue("id"))
+	if !found {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	margins := [2]time.Duration{defaultClipMargin, defaultClipMargin}
+	for i, name := range []string{"before", "after"} {
+		value := r.FormValue(name)
+		if value == "" {
+			continue
+		}
+		margin, err := time.ParseDuration(value)
+		if err != nil || margin < 0 || margin > maxClipMargin {
+			http.Error(w, fmt.Sprintf("Bad Request: %s must be a duration up to %s", name, maxClipMargin), http.StatusBadRequest)
+			return
+		}
+		margins[i] = margin
+	}
+	from, to := b.Time.Add(-margins[0]), b.Time.Add(margins[1])
+	if !to.After(from) {
+		to = from.Add(time.Second)
+	}
+	if user, restricted := a.access.restricted(r); restricted && !a.access.allows(user, from, to) {
+		http.Error(w, "Forbidden: footage outside your bookings", http.StatusForbidden)
+		return
+	}
+	audit.SetRange(r.Context(), from, to)
+
+	segments, err := a.catalog.Segments(r.Context(), from, to)
+	if err != nil {
+		log.Printf("Failed to list the segments of bookmark %s: %v\n", b.ID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if len(segments) == 0 {
+		http.Error(w, "Not Found: no footage was archived around this bookmark", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypes[".m3u8"])
+	w.Write([]byte(segmentPlaylist(segments, "/archive/", !to.After(a.now()))))
+}
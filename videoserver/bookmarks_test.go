@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"archive/objectstore"
+	"videoserver/auth"
+	"videoserver/bookmark"
+	"videoserver/catalog"
+)
+
+func TestBookmarksAPI(t *testing.T) {
+	// Setup
+	basePath := t.TempDir()
+	dir := filepath.Join(basePath, "2025", "04", "11", "19")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"playlist.m3u8": `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T19:41:50.000+0000
+segment_000.ts
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T19:42:00.000+0000
+segment_001.ts
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T19:42:10.000+0000
+segment_002.ts
+`,
+		"segment_000.ts": "0",
+		"segment_001.ts": "1",
+		"segment_002.ts": "2",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store, err := bookmark.Open(filepath.Join(t.TempDir(), "bookmarks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bookmarks := bookmarksAPI{
+		bookmarks: store,
+		catalog:   catalog.New(objectstore.NewFilesystem(basePath), time.UTC),
+		now:       func() time.Time { return time.Date(2025, 4, 12, 9, 0, 0, 0, time.UTC) },
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/bookmarks", bookmarks.list)
+	mux.HandleFunc("POST /api/bookmarks", bookmarks.create)
+	mux.HandleFunc("DELETE /api/bookmarks/{id}", bookmarks.delete)
+	mux.HandleFunc("GET /api/bookmarks/{id}/clip.m3u8", bookmarks.clip)
+	as := func(user auth.User, method, target string, form url.Values) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, request.WithContext(auth.WithUser(request.Context(), user)))
+		return recorder
+	}
+	player := auth.User{Name: "player", Role: auth.RoleViewer}
+	other := auth.User{Name: "other", Role: auth.RoleCoach}
+
+	// Execute
+	created := as(player, "POST", "/api/bookmarks", url.Values{"t": {"2025-04-11T19:42:10Z"}, "tags": {"great rally,Serve"}, "note": {"watch this"}})
+
+	// Assert
+	if created.Code != http.StatusCreated {
+		t.Fatalf("POST /api/bookmarks = %d %s", created.Code, created.Body.String())
+	}
+	var summary bookmarkSummary
+	if err := json.Unmarshal(created.Body.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Author != "player" || strings.Join(summary.Tags, ",") != "great rally,serve" || summary.Clip != "/api/bookmarks/"+summary.ID+"/clip.m3u8" {
+		t.Errorf("created = %+v", summary)
+	}
+	if invalid := as(player, "POST", "/api/bookmarks", url.Values{"t": {"yesterday"}}); invalid.Code != http.StatusBadRequest {
+		t.Errorf("POST with an invalid time = %d, want %d", invalid.Code, http.StatusBadRequest)
+	}
+
+	listed := as(other, "GET", "/api/bookmarks?tag=serve&from=2025-04-11T19:00", nil)
+	if !strings.Contains(listed.Body.String(), summary.ID) {
+		t.Errorf("GET /api/bookmarks = %s, want the bookmark", listed.Body.String())
+	}
+	if none := as(other, "GET", "/api/bookmarks?tag=lob", nil); strings.TrimSpace(none.Body.String()) != "[]" {
+		t.Errorf("GET /api/bookmarks?tag=lob = %s, want none", none.Body.String())
+	}
+
+	// The clip runs from 5s before the moment to 5s after
+	clip := as(other, "GET", summary.Clip+"?before=5s&after=5s", nil)
+	if body := clip.Body.String(); clip.Code != http.StatusOK || !strings.Contains(body, "/archive/2025/04/11/19/segment_001.ts") ||
+		!strings.Contains(body, "/archive/2025/04/11/19/segment_002.ts") || strings.Contains(body, "segment_000.ts") ||
+		!strings.Contains(body, "#EXT-X-ENDLIST") {
+		t.Errorf("clip = %d\n%s\nwant segment_001.ts and segment_002.ts", clip.Code, body)
+	}
+	if tooLong := as(other, "GET", summary.Clip+"?before=1h", nil); tooLong.Code != http.StatusBadRequest {
+		t.Errorf("clip with a long margin = %d, want %d", tooLong.Code, http.StatusBadRequest)
+	}
+
+	// Only the author or an admin may delete it
+	if forbidden := as(other, "DELETE", "/api/bookmarks/"+summary.ID, nil); forbidden.Code != http.StatusForbidden {
+		t.Errorf("DELETE by someone else = %d, want %d", forbidden.Code, http.StatusForbidden)
+	}
+	if deleted := as(player, "DELETE", "/api/bookmarks/"+summary.ID, nil); deleted.Code != http.StatusNoContent {
+		t.Errorf("DELETE by the author = %d, want %d", deleted.Code, http.StatusNoContent)
+	}
+	if missing := as(player, "GET", summary.Clip, nil); missing.Code != http.StatusNotFound {
+		t.Errorf("clip of a deleted bookmark = %d, want %d", missing.Code, http.StatusNotFound)
+	}
+}
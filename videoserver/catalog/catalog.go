@@ -0,0 +1,477 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"time"
+
+	"archive/archiverepo"
+	"archive/objectstore"
+	"archive/playlist"
+	"archive/thumbnail"
+)
+
+// ErrNotArchived is returned when there is no archived footage at a time
+var ErrNotArchived = errors.New("no archived footage at this time")
+
+// gapTolerance is how far apart two consecutive segments may be before the
+// space between them is reported as a gap
+const gapTolerance = time.Second
+
+// localLayouts are the wall-clock formats accepted for times in the club's
+// time zone, in addition to RFC3339
+var localLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// Catalog lists the days and hours recorded in the archive. The archive is
+// stored in UTC; days and hours are presented in the club's time zone.
+type Catalog struct {
+	store    objectstore.Store
+	location *time.Location
+}
+
+// New creates a new Catalog for the archive in store that presents days and
+// hours in location
+func New(store objectstore.Store, location *time.Location) *Catalog {
+	return &Catalog{
+		store:    store,
+		location: location,
+	}
+}
+
+// Store returns the object store holding the archive
+func (c *Catalog) Store() objectstore.Store {
+	return c.store
+}
+
+// Location returns the club time zone the catalog presents times in
+func (c *Catalog) Location() *time.Location {
+	return c.location
+}
+
+// ParseTime parses an RFC3339 time, or a wall-clock time such as
+// 2025-04-12T09:00 in the club's time zone
+func (c *Catalog) ParseTime(value string) (time.Time, error) {
+	return ParseTime(value, c.location)
+}
+
+// ParseTime parses an RFC3339 time, or a local time without an offset in
+// the given location
+func ParseTime(value string, location *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use RFC3339 or a local time such as 2006-01-02T15:04", value)
+}
+
+// Day is a single day in the club's time zone and the local hours recorded
+// on it
+type Day struct {
+	Date  string `json:"date"`
+	Hours []int  `json:"hours"`
+}
+
+// Gap is a stretch of time inside an hour that has no footage
+type Gap struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration float64   `json:"duration"`
+}
+
+// Hour describes a single archived hour and its playlist
+type Hour struct {
+	Start    time.Time `json:"start"`
+	Local    string    `json:"local"`
+	Path     string    `json:"path"`
+	Playlist string    `json:"playlist"`
+	Segments int       `json:"segments"`
+	Duration float64   `json:"duration"`
+	Size     int64     `json:"size"`
+	Gaps     []Gap     `json:"gaps"`
+	// Master is the master playlist listing the hour's playlist with its
+	// I-frame playlist, for scrubbing in native players, if it has one yet
+	Master string `json:"master,omitempty"`
+	// Thumbnails is the WebVTT track of seek bar thumbnails, if the hour
+	// has them yet
+	Thumbnails string `json:"thumbnails,omitempty"`
+}
+
+// Segment is a single archived segment
+type Segment struct {
+	Key      string
+	URI      string
+	DateTime time.Time
+	Duration float64
+	Size     int64
+	// Discontinuity is set when the archive marked the segment as not
+	// continuing the one before it, such as after the recorder restarted
+	Discontinuity bool
+}
+
+// End returns the time the segment's footage ends
+func (s Segment) End() time.Time {
+	return s.DateTime.Add(time.Duration(s.Duration * float64(time.Second)))
+}
+
+// Days returns every local day that has at least one archived hour, oldest
+// first
+func (c *Catalog) Days(ctx context.Context) ([]Day, error) {
+	days := []Day{}
+
+	years, err := c.numericDirs(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, year := range years {
+		months, err := c.numericDirs(ctx, year)
+		if err != nil {
+			return nil, err
+		}
+		for _, month := range months {
+			dates, err := c.numericDirs(ctx, month)
+			if err != nil {
+				return nil, err
+			}
+			for _, date := range dates {
+				hours, err := c.hoursOn(ctx, date)
+				if err != nil {
+					return nil, err
+				}
+				for _, hour := range hours {
+					local := hour.In(c.location)
+					localDate := local.Format(time.DateOnly)
+					if len(days) == 0 || days[len(days)-1].Date != localDate {
+						days = append(days, Day{Date: localDate, Hours: []int{}})
+					}
+					// The hour the clocks go back repeats a local hour,
+					// which is listed once
+					day := &days[len(days)-1]
+					if n := len(day.Hours); n == 0 || day.Hours[n-1] != local.Hour() {
+						day.Hours = append(day.Hours, local.Hour())
+					}
+				}
+			}
+		}
+	}
+
+	return days, nil
+}
+
+// Hours returns the details of every archived hour on the given local date.
+// A local day spans 23 or 25 UTC hours when the clocks change.
+func (c *Catalog) Hours(ctx context.Context, date time.Time) ([]Hour, error) {
+	// Days are stepped in the calendar, not in 24 hour slots
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, c.location)
+	end := time.Date(date.Year(), date.Month(), date.Day()+1, 0, 0, 0, 0, c.location)
+
+	hours := []Hour{}
+	for t := start.UTC().Truncate(time.Hour); t.Before(end); t = t.Add(time.Hour) {
+		if t.Before(start) {
+			continue
+		}
+		hour, err := c.Hour(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		if hour != nil {
+			hours = append(hours, *hour)
+		}
+	}
+	return hours, nil
+}
+
+// Hour returns the details of the archived hour containing t, or nil if
+// that hour has not been archived
+func (c *Catalog) Hour(ctx context.Context, t time.Time) (*Hour, error) {
+	t = t.UTC().Truncate(time.Hour)
+	hourPath, archivePlaylist, err := c.readPlaylist(ctx, t)
+	if err != nil || archivePlaylist == nil {
+		return nil, err
+	}
+	sizes, err := c.sizes(ctx, hourPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Placeholders the archive filled gaps with are not footage
+	recorded := slices.DeleteFunc(slices.Clone(archivePlaylist.Segments), archiverepo.IsPlaceholder)
+	hour := &Hour{
+		Start:    t,
+		Local:    t.In(c.location).Format(time.RFC3339),
+		Path:     hourPath,
+		Playlist: "/archive/" + hourPath + "/" + archiverepo.PlaylistName,
+		Segments: len(recorded),
+		Gaps:     findGaps(recorded),
+	}
+	for _, segment := range recorded {
+		hour.Duration += segment.Duration
+		hour.Size += sizes[segment.Filename]
+	}
+	hour.Master, hour.Thumbnails = extras(hourPath, sizes)
+
+	return hour, nil
+}
+
+// Position is a point in an archived hour's playlist
+type Position struct {
+	Time     time.Time `json:"time"`
+	Playlist string    `json:"playlist"`
+	Segment  string    `json:"segment"`
+	Offset   float64   `json:"offset"`
+	// Master and Thumbnails are the hour's master playlist and WebVTT track
+	// of seek bar thumbnails, if it has them yet
+	Master     string `json:"master,omitempty"`
+	Thumbnails string `json:"thumbnails,omitempty"`
+}
+
+// At resolves a time to the archive playlist of its hour and the offset in
+// seconds to seek to from the start of that playlist. A time that falls in a
+// gap, or in a placeholder the archive filled one with, resolves to the
+// footage that follows it, which may be in the next hour.
+func (c *Catalog) At(ctx context.Context, t time.Time) (*Position, error) {
+	hourPath, archivePlaylist, err := c.readPlaylist(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	if archivePlaylist == nil {
+		return nil, ErrNotArchived
+	}
+	position, err := c.position(ctx, t, hourPath, archivePlaylist)
+	if position != nil || err != nil {
+		return position, err
+	}
+
+	// The hour's footage ended before t, so it resumes in the next hour if
+	// anywhere
+	hourPath, archivePlaylist, err = c.readPlaylist(ctx, t.Add(time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	if archivePlaylist != nil {
+		if position, err = c.position(ctx, t, hourPath, archivePlaylist); position != nil || err != nil {
+			return position, err
+		}
+	}
+	return nil, ErrNotArchived
+}
+
+// position finds t in the archive playlist of an hour, or returns nil if
+// the hour's footage ends before it
+func (c *Catalog) position(ctx context.Context, t time.Time, hourPath string, archivePlaylist *playlist.Playlist) (*Position, error) {
+	offset := 0.0
+	for _, entry := range archivePlaylist.Segments {
+		segment := Segment{DateTime: entry.DateTime, Duration: entry.Duration}
+		if t.Before(segment.End()) && !archiverepo.IsPlaceholder(entry) {
+			position := &Position{
+				Time:     t.UTC(),
+				Playlist: "/archive/" + hourPath + "/" + archiverepo.PlaylistName,
+				Segment:  entry.Filename,
+				Offset:   offset,
+			}
+			if t.After(segment.DateTime) {
+				position.Offset += t.Sub(segment.DateTime).Seconds()
+			}
+			sizes, err := c.sizes(ctx, hourPath)
+			if err != nil {
+				return nil, err
+			}
+			position.Master, position.Thumbnails = extras(hourPath, sizes)
+			return position, nil
+		}
+		offset += entry.Duration
+	}
+	return nil, nil
+}
+
+// Playlist returns the archive playlist of the hour containing t
+func (c *Catalog) Playlist(ctx context.Context, t time.Time) (*playlist.Playlist, error) {
+	_, archivePlaylist, err := c.readPlaylist(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	if archivePlaylist == nil {
+		return nil, ErrNotArchived
+	}
+	return archivePlaylist, nil
+}
+
+// Segments returns the archived segments that overlap the range from-to,
+// in playback order. Segments whose files are missing are left out.
+func (c *Catalog) Segments(ctx context.Context, from, to time.Time) ([]Segment, error) {
+	segments := []Segment{}
+	// The last segment of the hour before from may run on into it
+	first := from.UTC().Truncate(time.Hour)
+	for t := first.Add(-time.Hour); t.Before(to); t = t.Add(time.Hour) {
+		hourPath, archivePlaylist, err := c.readPlaylist(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		if archivePlaylist == nil {
+			continue
+		}
+		entries := slices.DeleteFunc(archivePlaylist.Segments, archiverepo.IsPlaceholder)
+		if t.Before(first) {
+			if len(entries) == 0 {
+				continue
+			}
+			last := entries[len(entries)-1]
+			if !(Segment{DateTime: last.DateTime, Duration: last.Duration}).End().After(from) {
+				continue
+			}
+			entries = entries[len(entries)-1:]
+		}
+		sizes, err := c.sizes(ctx, hourPath)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			size, found := sizes[entry.Filename]
+			if !found {
+				continue
+			}
+			segment := Segment{
+				Key:           path.Join(hourPath, entry.Filename),
+				URI:           "/archive/" + hourPath + "/" + entry.Filename,
+				DateTime:      entry.DateTime.UTC(),
+				Duration:      entry.Duration,
+				Size:          size,
+				Discontinuity: entry.Discontinuity,
+			}
+			if !segment.DateTime.Before(to) || !segment.End().After(from) {
+				continue
+			}
+			segments = append(segments, segment)
+		}
+	}
+	return segments, nil
+}
+
+// readPlaylist reads the archive playlist of the hour containing t and
+// returns it with the hour's path relative to the archive root. The
+// playlist is nil if the hour has not been archived.
+func (c *Catalog) readPlaylist(ctx context.Context, t time.Time) (string, *playlist.Playlist, error) {
+	hourPath := archiverepo.HourPath(t)
+
+	file, err := c.store.Get(ctx, path.Join(hourPath, archiverepo.PlaylistName))
+	if err != nil {
+		if errors.Is(err, objectstore.ErrNotExist) {
+			return hourPath, nil, nil
+		}
+		return hourPath, nil, err
+	}
+	defer file.Close()
+
+	archivePlaylist, err := playlist.Parse(file)
+	if err != nil {
+		return hourPath, nil, fmt.Errorf("failed to parse playlist %s: %w", hourPath, err)
+	}
+	return hourPath, archivePlaylist, nil
+}
+
+// sizes returns the size of every object in an hour directory by name, with
+// a single listing rather than one request per segment
+func (c *Catalog) sizes(ctx context.Context, hourPath string) (map[string]int64, error) {
+	infos, err := c.store.List(ctx, hourPath)
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[string]int64, len(infos))
+	for _, info := range infos {
+		if !info.IsDir {
+			sizes[info.Name()] = info.Size
+		}
+	}
+	return sizes, nil
+}
+
+// extras returns the URLs of the master playlist and the thumbnail track of
+// an hour directory holding the objects in sizes, or empty strings for the
+// ones that haven't been built yet
+func extras(hourPath string, sizes map[string]int64) (master, thumbnails string) {
+	if _, found := sizes[archiverepo.MasterName]; found {
+		master = "/archive/" + hourPath + "/" + archiverepo.MasterName
+	}
+	if _, found := sizes[thumbnail.VTTName]; found {
+		thumbnails = "/archive/" + hourPath + "/" + thumbnail.VTTName
+	}
+	return master, thumbnails
+}
+
+// hoursOn returns the start of each UTC hour in a day directory that
+// contains an archive playlist
+func (c *Catalog) hoursOn(ctx context.Context, dayPath string) ([]time.Time, error) {
+	dirs, err := c.numericDirs(ctx, dayPath)
+	if err != nil {
+		return nil, err
+	}
+
+	hours := []time.Time{}
+	for _, dir := range dirs {
+		if _, err := c.store.Stat(ctx, path.Join(dir, archiverepo.PlaylistName)); err != nil {
+			if errors.Is(err, objectstore.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		hour, err := time.Parse("2006/01/02/15", dir)
+		if err != nil {
+			continue
+		}
+		hours = append(hours, hour)
+	}
+	return hours, nil
+}
+
+// findGaps returns the stretches between consecutive segments that are not
+// covered by footage
+func findGaps(segments []playlist.Segment) []Gap {
+	gaps := []Gap{}
+	for i := 1; i < len(segments); i++ {
+		previous := segments[i-1]
+		end := Segment{DateTime: previous.DateTime, Duration: previous.Duration}.End()
+		start := segments[i].DateTime
+		if start.Sub(end) > gapTolerance {
+			gaps = append(gaps, Gap{
+				Start:    end.UTC(),
+				End:      start.UTC(),
+				Duration: start.Sub(end).Seconds(),
+			})
+		}
+	}
+	return gaps
+}
+
+// numericDirs returns the sorted keys of the numeric subdirectories of dir,
+// or nothing if dir does not exist
+func (c *Catalog) numericDirs(ctx context.Context, dir string) ([]string, error) {
+	infos, err := c.store.List(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, info := range infos {
+		if !info.IsDir {
+			continue
+		}
+		if _, err := strconv.Atoi(info.Name()); err != nil {
+			continue
+		}
+		keys = append(keys, info.Key)
+	}
+	return keys, nil
+}
@@ -0,0 +1,501 @@
+package catalog_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"archive/archiverepo"
+	"archive/objectstore"
+	"archive/playlist"
+	"videoserver/catalog"
+)
+
+const hourPlaylist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:61
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:60.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T00:27:00.000+0000
+segment_000.ts
+#EXTINF:60.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T00:28:00.000+0000
+segment_001.ts
+#EXTINF:60.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T00:34:00.000+0000
+segment_002.ts
+`
+
+func TestCatalog_Days(t *testing.T) {
+	// Setup
+	basePath := t.TempDir()
+	writeHour(t, basePath, "2025/04/11/00", hourPlaylist)
+	writeHour(t, basePath, "2025/04/11/13", hourPlaylist)
+	writeHour(t, basePath, "2025/04/12/09", hourPlaylist)
+	// An hour directory without a playlist is not listed
+	if err := os.MkdirAll(filepath.Join(basePath, "2025/04/13/10"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Execute
+	days, err := catalog.New(objectstore.NewFilesystem(basePath), time.UTC).Days(context.Background())
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Days failed: %v", err)
+	}
+	if len(days) != 2 {
+		t.Fatalf("Expected 2 days, got %d: %+v", len(days), days)
+	}
+	if days[0].Date != "2025-04-11" || len(days[0].Hours) != 2 || days[0].Hours[1] != 13 {
+		t.Errorf("Unexpected first day %+v", days[0])
+	}
+	if days[1].Date != "2025-04-12" || len(days[1].Hours) != 1 || days[1].Hours[0] != 9 {
+		t.Errorf("Unexpected second day %+v", days[1])
+	}
+}
+
+func TestCatalog_Days_EmptyArchive(t *testing.T) {
+	days, err := catalog.New(objectstore.NewFilesystem(filepath.Join(t.TempDir(), "missing")), time.UTC).Days(context.Background())
+	if err != nil {
+		t.Fatalf("Days failed: %v", err)
+	}
+	if len(days) != 0 {
+		t.Errorf("Expected no days, got %+v", days)
+	}
+}
+
+func TestCatalog_Hours(t *testing.T) {
+	// Setup
+	basePath := t.TempDir()
+	writeHour(t, basePath, "2025/04/11/00", hourPlaylist)
+
+	// Execute
+	hours, err := catalog.New(objectstore.NewFilesystem(basePath), time.UTC).Hours(context.Background(), time.Date(2025, 4, 11, 0, 0, 0, 0, time.UTC))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Hours failed: %v", err)
+	}
+	if len(hours) != 1 {
+		t.Fatalf("Expected 1 hour, got %d", len(hours))
+	}
+
+	hour := hours[0]
+	if hour.Path != "2025/04/11/00" {
+		t.Errorf("Path = %s, want 2025/04/11/00", hour.Path)
+	}
+	if hour.Playlist != "/archive/2025/04/11/00/playlist.m3u8" {
+		t.Errorf("Playlist = %s", hour.Playlist)
+	}
+	if hour.Segments != 3 {
+		t.Errorf("Segments = %d, want 3", hour.Segments)
+	}
+	if hour.Duration != 180 {
+		t.Errorf("Duration = %f, want 180", hour.Duration)
+	}
+	if hour.Size != int64(3*len("segment")) {
+		t.Errorf("Size = %d, want %d", hour.Size, 3*len("segment"))
+	}
+
+	if len(hour.Gaps) != 1 {
+		t.Fatalf("Expected 1 gap, got %+v", hour.Gaps)
+	}
+	expectedStart := time.Date(2025, 4, 11, 0, 29, 0, 0, time.UTC)
+	if !hour.Gaps[0].Start.Equal(expectedStart) {
+		t.Errorf("Gap start = %v, want %v", hour.Gaps[0].Start, expectedStart)
+	}
+	if hour.Gaps[0].Duration != 300 {
+		t.Errorf("Gap duration = %f, want 300", hour.Gaps[0].Duration)
+	}
+}
+
+func TestCatalog_Hour_NotArchived(t *testing.T) {
+	hour, err := catalog.New(objectstore.NewFilesystem(t.TempDir()), time.UTC).Hour(context.Background(), time.Date(2025, 4, 11, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Hour failed: %v", err)
+	}
+	if hour != nil {
+		t.Errorf("Expected nil hour, got %+v", hour)
+	}
+}
+
+// writeHour writes a playlist and a small file for each of its segments
+func writeHour(t *testing.T, basePath, hourPath, content string) {
+	t.Helper()
+	dir := filepath.Join(basePath, filepath.FromSlash(hourPath))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "playlist.m3u8"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"segment_000.ts", "segment_001.ts", "segment_002.ts"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("segment"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCatalog_ClubTimezone(t *testing.T) {
+	// Setup
+	location, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	basePath := t.TempDir()
+	// 16:00 UTC on Saturday is 09:00 local; 03:00 UTC on Sunday is still
+	// Saturday 20:00 local
+	writeHour(t, basePath, "2025/04/12/16", hourPlaylist)
+	writeHour(t, basePath, "2025/04/13/03", hourPlaylist)
+	c := catalog.New(objectstore.NewFilesystem(basePath), location)
+
+	// Execute
+	days, err := c.Days(context.Background())
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Days failed: %v", err)
+	}
+	if len(days) != 1 || days[0].Date != "2025-04-12" {
+		t.Fatalf("Expected a single local day 2025-04-12, got %+v", days)
+	}
+	if len(days[0].Hours) != 2 || days[0].Hours[0] != 9 || days[0].Hours[1] != 20 {
+		t.Errorf("Hours = %v, want [9 20]", days[0].Hours)
+	}
+
+	hours, err := c.Hours(context.Background(), time.Date(2025, 4, 12, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Hours failed: %v", err)
+	}
+	if len(hours) != 2 {
+		t.Fatalf("Expected 2 hours, got %d", len(hours))
+	}
+	if hours[0].Path != "2025/04/12/16" || hours[0].Local != "2025-04-12T09:00:00-07:00" {
+		t.Errorf("Unexpected first hour %s %s", hours[0].Path, hours[0].Local)
+	}
+	if hours[1].Path != "2025/04/13/03" {
+		t.Errorf("Unexpected second hour %s", hours[1].Path)
+	}
+}
+
+func TestCatalog_Hours_DSTDay(t *testing.T) {
+	// Setup
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	basePath := t.TempDir()
+	// The local day the clocks go back runs from 22:00 to 23:00 UTC the
+	// next day, 25 hours
+	start := time.Date(2025, 10, 25, 22, 0, 0, 0, time.UTC)
+	for i := range 26 {
+		writeHour(t, basePath, start.Add(time.Duration(i)*time.Hour).Format("2006/01/02/15"), hourPlaylist)
+	}
+
+	// Execute
+	hours, err := catalog.New(objectstore.NewFilesystem(basePath), location).Hours(context.Background(), time.Date(2025, 10, 26, 0, 0, 0, 0, time.UTC))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Hours failed: %v", err)
+	}
+	if len(hours) != 25 {
+		t.Fatalf("Expected 25 hours, got %d", len(hours))
+	}
+	if hours[0].Path != "2025/10/25/22" || hours[24].Path != "2025/10/26/22" {
+		t.Errorf("Unexpected range %s to %s", hours[0].Path, hours[24].Path)
+	}
+}
+
+func TestCatalog_Days_DSTDay(t *testing.T) {
+	// Setup
+	location, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	basePath := t.TempDir()
+	// 00:00 and 01:00 UTC on the day the clocks go back are both 02:00
+	// local, and 23:00 UTC is the next local day
+	for _, hourPath := range []string{"2025/10/26/00", "2025/10/26/01", "2025/10/26/02", "2025/10/26/23"} {
+		writeHour(t, basePath, hourPath, hourPlaylist)
+	}
+
+	// Execute
+	days, err := catalog.New(objectstore.NewFilesystem(basePath), location).Days(context.Background())
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Days failed: %v", err)
+	}
+	if len(days) != 2 || days[0].Date != "2025-10-26" || days[1].Date != "2025-10-27" {
+		t.Fatalf("Days = %+v, want 2025-10-26 and 2025-10-27", days)
+	}
+	if fmt.Sprint(days[0].Hours) != "[2 3]" {
+		t.Errorf("Hours = %v, want [2 3] with the repeated hour once", days[0].Hours)
+	}
+}
+
+func TestCatalog_ParseTime(t *testing.T) {
+	location, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	c := catalog.New(objectstore.NewFilesystem(t.TempDir()), location)
+
+	tests := []struct {
+		value    string
+		expected time.Time
+	}{
+		{"2025-04-12T09:00", time.Date(2025, 4, 12, 8, 0, 0, 0, time.UTC)},
+		{"2025-01-11 09:00:30", time.Date(2025, 1, 11, 9, 0, 30, 0, time.UTC)},
+		{"2025-04-12T09:00:00Z", time.Date(2025, 4, 12, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := c.ParseTime(tt.value)
+		if err != nil {
+			t.Errorf("ParseTime(%s) failed: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.expected) {
+			t.Errorf("ParseTime(%s) = %v, want %v", tt.value, got.UTC(), tt.expected)
+		}
+	}
+
+	if _, err := c.ParseTime("Saturday"); err == nil {
+		t.Error("Expected an error for an unparseable time")
+	}
+}
+
+func TestCatalog_At_ArchiveLayoutContract(t *testing.T) {
+	// Setup: write an hour with the archive daemon's repository, so the
+	// catalog is tested against the layout the archiver actually produces
+	store := objectstore.NewMemory()
+	repo := archiverepo.New(store, playlist.NewParser(slog.Default()), slog.Default())
+	start := time.Date(2025, 1, 5, 7, 59, 0, 0, time.FixedZone("", -5*60*60))
+	archivePlaylist := &playlist.Playlist{Version: 3, TargetDuration: 10}
+	for i := range 3 {
+		segmentTime := start.Add(time.Duration(i*10) * time.Second)
+		filename := fmt.Sprintf("segment_%03d.ts", i)
+		if err := repo.WriteSegment(segmentTime, filename, io.NopCloser(strings.NewReader("segment"))); err != nil {
+			t.Fatal(err)
+		}
+		archivePlaylist = playlist.Concat(archivePlaylist, playlist.Segment{
+			Filename:        filename,
+			Duration:        10,
+			DateTime:        segmentTime,
+			ProgramDateTime: segmentTime.Format("2006-01-02T15:04:05.000-0700"),
+		})
+	}
+	if err := repo.WritePlaylist(start, archivePlaylist); err != nil {
+		t.Fatal(err)
+	}
+	c := catalog.New(store, time.UTC)
+
+	// Execute
+	position, err := c.At(context.Background(), time.Date(2025, 1, 5, 12, 59, 25, 0, time.UTC))
+
+	// Assert: January is 01, not the zero-based 0 the site used to build
+	if err != nil {
+		t.Fatalf("At failed: %v", err)
+	}
+	if position.Playlist != "/archive/2025/01/05/12/playlist.m3u8" {
+		t.Errorf("Playlist = %s, want /archive/2025/01/05/12/playlist.m3u8", position.Playlist)
+	}
+	if position.Segment != "segment_002.ts" {
+		t.Errorf("Segment = %s, want segment_002.ts", position.Segment)
+	}
+	if position.Offset != 25 {
+		t.Errorf("Offset = %f, want 25", position.Offset)
+	}
+
+	hours, err := c.Hours(context.Background(), time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(hours) != 1 || hours[0].Segments != 3 {
+		t.Errorf("Hours = %+v, %v, want the single archived hour", hours, err)
+	}
+}
+
+func TestCatalog_At_Gap(t *testing.T) {
+	basePath := t.TempDir()
+	writeHour(t, basePath, "2025/04/11/00", hourPlaylist)
+	c := catalog.New(objectstore.NewFilesystem(basePath), time.UTC)
+
+	// A time in the gap resolves to the start of the next segment
+	position, err := c.At(context.Background(), time.Date(2025, 4, 11, 0, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("At failed: %v", err)
+	}
+	if position.Segment != "segment_002.ts" || position.Offset != 120 {
+		t.Errorf("Position = %+v, want segment_002.ts at 120", position)
+	}
+
+	// Times before or after the footage in an hour are not archived
+	if _, err := c.At(context.Background(), time.Date(2025, 4, 11, 0, 50, 0, 0, time.UTC)); !errors.Is(err, catalog.ErrNotArchived) {
+		t.Errorf("Expected ErrNotArchived after the last segment, got %v", err)
+	}
+	if _, err := c.At(context.Background(), time.Date(2025, 4, 11, 1, 0, 0, 0, time.UTC)); !errors.Is(err, catalog.ErrNotArchived) {
+		t.Errorf("Expected ErrNotArchived for a missing hour, got %v", err)
+	}
+}
+
+func TestCatalog_At_NextHour(t *testing.T) {
+	// Setup
+	basePath := t.TempDir()
+	writeHour(t, basePath, "2025/04/11/00", hourPlaylist)
+	writeHour(t, basePath, "2025/04/11/01", `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:61
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:60.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T01:05:00.000Z
+segment_000.ts
+`)
+	c := catalog.New(objectstore.NewFilesystem(basePath), time.UTC)
+
+	// Execute: a time after the hour's last segment
+	position, err := c.At(context.Background(), time.Date(2025, 4, 11, 0, 50, 0, 0, time.UTC))
+
+	// Assert: it resolves to the footage that follows in the next hour
+	if err != nil {
+		t.Fatalf("At failed: %v", err)
+	}
+	if position.Playlist != "/archive/2025/04/11/01/playlist.m3u8" || position.Segment != "segment_000.ts" || position.Offset != 0 {
+		t.Errorf("Position = %+v, want segment_000.ts of 01:00 at 0", position)
+	}
+}
+
+func TestCatalog_FilledGap(t *testing.T) {
+	// Setup: the archive covered the gap with EXT-X-GAP placeholders
+	basePath := t.TempDir()
+	writeHour(t, basePath, "2025/04/11/00", `#EXTM3U
+#EXT-X-VERSION:8
+#EXT-X-TARGETDURATION:61
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:60.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T00:27:00.000Z
+segment_000.ts
+#EXT-X-GAP
+#EXTINF:60.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T00:28:00.000Z
+gap.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:60.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T00:29:00.000Z
+segment_002.ts
+`)
+	c := catalog.New(objectstore.NewFilesystem(basePath), time.UTC)
+
+	// Execute
+	position, err := c.At(context.Background(), time.Date(2025, 4, 11, 0, 28, 30, 0, time.UTC))
+	hour, hourErr := c.Hour(context.Background(), time.Date(2025, 4, 11, 0, 0, 0, 0, time.UTC))
+
+	// Assert: the placeholder counts towards playlist time but not footage
+	if err != nil {
+		t.Fatalf("At failed: %v", err)
+	}
+	if position.Segment != "segment_002.ts" || position.Offset != 120 {
+		t.Errorf("Position = %+v, want segment_002.ts at 120", position)
+	}
+	if hourErr != nil {
+		t.Fatalf("Hour failed: %v", hourErr)
+	}
+	if hour.Segments != 2 || len(hour.Gaps) != 1 || hour.Gaps[0].Duration != 60 {
+		t.Errorf("Hour = %+v, want 2 segments and a minute's gap", hour)
+	}
+}
+
+func TestCatalog_Segments_FromThePreviousHour(t *testing.T) {
+	// Setup: the last segment of midnight's hour runs on past 01:00
+	basePath := t.TempDir()
+	writeHour(t, basePath, "2025/04/11/00", `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T00:59:45.000Z
+segment_000.ts
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T00:59:55.000Z
+segment_001.ts
+`)
+	writeHour(t, basePath, "2025/04/11/01", `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T01:00:05.000Z
+segment_000.ts
+`)
+	c := catalog.New(objectstore.NewFilesystem(basePath), time.UTC)
+
+	// Execute
+	segments, err := c.Segments(context.Background(), time.Date(2025, 4, 11, 1, 0, 2, 0, time.UTC), time.Date(2025, 4, 11, 1, 0, 10, 0, time.UTC))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Segments failed: %v", err)
+	}
+	var keys []string
+	for _, segment := range segments {
+		keys = append(keys, segment.Key)
+	}
+	if got, want := strings.Join(keys, " "), "2025/04/11/00/segment_001.ts 2025/04/11/01/segment_000.ts"; got != want {
+		t.Errorf("Segments = %s, want %s", got, want)
+	}
+}
+
+func TestCatalog_Extras(t *testing.T) {
+	// Setup: one hour has a master playlist and a thumbnail track and the
+	// next doesn't yet
+	basePath := t.TempDir()
+	writeHour(t, basePath, "2025/04/11/00", hourPlaylist)
+	writeHour(t, basePath, "2025/04/11/01", strings.ReplaceAll(hourPlaylist, "T00:", "T01:"))
+	for name, content := range map[string]string{"thumbnails.vtt": "WEBVTT\n", "master.m3u8": "#EXTM3U\n"} {
+		if err := os.WriteFile(filepath.Join(basePath, "2025", "04", "11", "00", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := catalog.New(objectstore.NewFilesystem(basePath), time.UTC)
+
+	// Execute
+	hours, err := c.Hours(context.Background(), time.Date(2025, 4, 11, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Hours failed: %v", err)
+	}
+	position, err := c.At(context.Background(), time.Date(2025, 4, 11, 0, 27, 30, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("At failed: %v", err)
+	}
+	later, err := c.At(context.Background(), time.Date(2025, 4, 11, 1, 27, 30, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("At failed: %v", err)
+	}
+
+	// Assert
+	if len(hours) != 2 {
+		t.Fatalf("Expected 2 hours, got %d", len(hours))
+	}
+	if got, want := hours[0].Thumbnails, "/archive/2025/04/11/00/thumbnails.vtt"; got != want {
+		t.Errorf("first hour Thumbnails = %q, want %q", got, want)
+	}
+	if got, want := hours[0].Master, "/archive/2025/04/11/00/master.m3u8"; got != want {
+		t.Errorf("first hour Master = %q, want %q", got, want)
+	}
+	if hours[1].Thumbnails != "" || hours[1].Master != "" {
+		t.Errorf("second hour Thumbnails, Master = %q, %q, want none", hours[1].Thumbnails, hours[1].Master)
+	}
+	if got, want := position.Thumbnails, "/archive/2025/04/11/00/thumbnails.vtt"; got != want {
+		t.Errorf("Position Thumbnails = %q, want %q", got, want)
+	}
+	if got, want := position.Master, "/archive/2025/04/11/00/master.m3u8"; got != want {
+		t.Errorf("Position Master = %q, want %q", got, want)
+	}
+	if later.Thumbnails != "" || later.Master != "" {
+		t.Errorf("later Position Thumbnails, Master = %q, %q, want none", later.Thumbnails, later.Master)
+	}
+}
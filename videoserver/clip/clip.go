@@ -0,0 +1,178 @@
+package clip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"archive/objectstore"
+	"videoserver/catalog"
+)
+
+// ErrEmpty is returned when no archived footage overlaps the requested range
+var ErrEmpty = errors.New("no archived footage in range")
+
+// Clip is a range of archived footage joined into a single transport stream.
+// The range is widened to the boundaries of the segments it overlaps.
+type Clip struct {
+	Start    time.Time
+	End      time.Time
+	Size     int64
+	Segments []catalog.Segment
+	store    objectstore.Store
+}
+
+// New creates a Clip of the archived footage between from and to
+func New(ctx context.Context, c *catalog.Catalog, from, to time.Time) (*Clip, error) {
+	if !to.After(from) {
+		return nil, fmt.Errorf("clip end %s is not after start %s", to.Format(time.RFC3339), from.Format(time.RFC3339))
+	}
+
+	segments, err := c.Segments(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find segments: %w", err)
+	}
+	if len(segments) == 0 {
+		return nil, ErrEmpty
+	}
+
+	clip := &Clip{
+		Start:    segments[0].DateTime,
+		End:      segments[len(segments)-1].End(),
+		Segments: segments,
+		store:    c.Store(),
+	}
+	for _, segment := range segments {
+		clip.Size += segment.Size
+	}
+	return clip, nil
+}
+
+// Filename returns a download filename naming the court and the clip's time
+// range, such as court6_20250411T002748Z-20250411T003049Z.ts
+func (c *Clip) Filename(court, extension string) string {
+	const layout = "20060102T150405Z"
+	return fmt.Sprintf("%s_%s-%s.%s", court, c.Start.UTC().Format(layout), c.End.UTC().Format(layout), extension)
+}
+
+// Open returns a reader over the bytes of every segment in the clip, in order
+func (c *Clip) Open(ctx context.Context) io.ReadSeekCloser {
+	return &segmentReader{ctx: ctx, store: c.store, segments: c.Segments, size: c.Size}
+}
+
+// Remux converts the clip to a fragmented MP4 with ffmpeg, writing the
+// result to w. The streams are copied without re-encoding.
+func (c *Clip) Remux(ctx context.Context, ffmpegPath string, w io.Writer) error {
+	input := c.Open(ctx)
+	defer input.Close()
+
+	cmd := exec.CommandContext(ctx, ffmpegPath,
+		"-hide_banner", "-loglevel", "error",
+		"-f", "mpegts", "-i", "pipe:0",
+		"-c", "copy",
+		"-movflags", "frag_keyframe+empty_moov",
+		"-f", "mp4", "pipe:1")
+	cmd.Stdin = input
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to remux clip: %w", err)
+	}
+	return nil
+}
+
+// segmentReader reads a list of segments as one continuous stream
+type segmentReader struct {
+	ctx      context.Context
+	store    objectstore.Store
+	segments []catalog.Segment
+	size     int64
+	offset   int64
+	file     io.ReadCloser
+	fileEnd  int64
+}
+
+func (r *segmentReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.file == nil {
+		if err := r.openAt(r.offset); err != nil {
+			return 0, err
+		}
+	}
+
+	// Never read past the size the segment had when the clip was planned
+	if remaining := r.fileEnd - r.offset; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := r.file.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.fileEnd {
+		// The segment shrank after the clip was planned
+		err = io.ErrUnexpectedEOF
+	} else if err == io.EOF {
+		err = nil
+	}
+	if r.offset >= r.fileEnd || err != nil {
+		// Move on to the next segment
+		r.file.Close()
+		r.file = nil
+	}
+	return n, err
+}
+
+func (r *segmentReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *segmentReader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// openAt opens the segment containing offset and positions it there
+func (r *segmentReader) openAt(offset int64) error {
+	start := int64(0)
+	for _, segment := range r.segments {
+		if offset < start+segment.Size {
+			file, err := r.store.GetRange(r.ctx, segment.Key, offset-start, start+segment.Size-offset)
+			if err != nil {
+				return err
+			}
+			r.file = file
+			r.fileEnd = start + segment.Size
+			return nil
+		}
+		start += segment.Size
+	}
+	return io.EOF
+}
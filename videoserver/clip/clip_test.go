@@ -0,0 +1,143 @@
+package clip_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"archive/objectstore"
+	"videoserver/catalog"
+	"videoserver/clip"
+)
+
+const hourPlaylist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T00:59:40.000+0000
+segment_000.ts
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T00:59:50.000+0000
+segment_001.ts
+`
+
+const nextHourPlaylist = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T01:00:00.000+0000
+segment_000.ts
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T01:00:10.000+0000
+segment_001.ts
+`
+
+func TestNew_SpansHours(t *testing.T) {
+	// Setup
+	basePath := t.TempDir()
+	writeHour(t, basePath, "2025/04/11/00", hourPlaylist, "aaaa", "bbbb")
+	writeHour(t, basePath, "2025/04/11/01", nextHourPlaylist, "cccc", "dddd")
+
+	// Execute
+	from := time.Date(2025, 4, 11, 0, 59, 55, 0, time.UTC)
+	to := time.Date(2025, 4, 11, 1, 0, 5, 0, time.UTC)
+	c, err := clip.New(context.Background(), catalog.New(objectstore.NewFilesystem(basePath), time.UTC), from, to)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if len(c.Segments) != 2 {
+		t.Fatalf("Expected 2 segments, got %d", len(c.Segments))
+	}
+	if !c.Start.Equal(time.Date(2025, 4, 11, 0, 59, 50, 0, time.UTC)) {
+		t.Errorf("Start = %v, want the start of the first overlapping segment", c.Start)
+	}
+	if !c.End.Equal(time.Date(2025, 4, 11, 1, 0, 10, 0, time.UTC)) {
+		t.Errorf("End = %v, want the end of the last overlapping segment", c.End)
+	}
+	if c.Size != 8 {
+		t.Errorf("Size = %d, want 8", c.Size)
+	}
+
+	content := c.Open(context.Background())
+	defer content.Close()
+	data, err := io.ReadAll(content)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if string(data) != "bbbbcccc" {
+		t.Errorf("Content = %q, want %q", data, "bbbbcccc")
+	}
+
+	if got := c.Filename("court6", "ts"); got != "court6_20250411T005950Z-20250411T010010Z.ts" {
+		t.Errorf("Filename = %s", got)
+	}
+}
+
+func TestOpen_Seek(t *testing.T) {
+	// Setup
+	basePath := t.TempDir()
+	writeHour(t, basePath, "2025/04/11/01", nextHourPlaylist, "0123", "4567")
+	c, err := clip.New(context.Background(), catalog.New(objectstore.NewFilesystem(basePath), time.UTC),
+		time.Date(2025, 4, 11, 1, 0, 0, 0, time.UTC),
+		time.Date(2025, 4, 11, 1, 0, 20, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	content := c.Open(context.Background())
+	defer content.Close()
+
+	// Execute
+	if _, err := content.Seek(3, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(content, 3))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if string(data) != "345" {
+		t.Errorf("Content = %q, want %q", data, "345")
+	}
+
+	end, err := content.Seek(0, io.SeekEnd)
+	if err != nil || end != 8 {
+		t.Errorf("Seek to end = %d, %v, want 8", end, err)
+	}
+}
+
+func TestNew_Empty(t *testing.T) {
+	_, err := clip.New(context.Background(), catalog.New(objectstore.NewFilesystem(t.TempDir()), time.UTC),
+		time.Date(2025, 4, 11, 1, 0, 0, 0, time.UTC),
+		time.Date(2025, 4, 11, 1, 0, 20, 0, time.UTC))
+	if !errors.Is(err, clip.ErrEmpty) {
+		t.Errorf("Expected ErrEmpty, got %v", err)
+	}
+}
+
+// writeHour writes a playlist and its two segment files
+func writeHour(t *testing.T, basePath, hourPath, content, first, second string) {
+	t.Helper()
+	dir := filepath.Join(basePath, filepath.FromSlash(hourPath))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"playlist.m3u8":  content,
+		"segment_000.ts": first,
+		"segment_001.ts": second,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"videoserver/audit"
+	"videoserver/catalog"
+	"videoserver/clip"
+)
+
+// maxClipDuration limits how much footage a single clip request may join
+const maxClipDuration = 4 * time.Hour
+
+// clipAPI serves ranges of archived footage as a single download
+type clipAPI struct {
+	catalog    *catalog.Catalog
+	court      string
+	ffmpegPath string
+}
+
+// export responds with the footage between the from and to query
+// parameters as one transport stream, or as an MP4 when format=mp4
+func (a clipAPI) export(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseRange(r, a.catalog)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: %v", err), http.StatusBadRequest)
+		return
+	}
+	audit.SetRange(r.Context(), from, to)
+
+	c, err := clip.New(r.Context(), a.catalog, from, to)
+	if errors.Is(err, clip.ErrEmpty) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to create clip: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "ts":
+		content := c.Open(r.Context())
+		defer content.Close()
+
+		w.Header().Set("Content-Type", "video/mp2t")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, c.Filename(a.court, "ts")))
+		http.ServeContent(w, r, "", c.End, content)
+	case "mp4":
+		if a.ffmpegPath == "" {
+			http.Error(w, "Not Implemented: MP4 export is not configured", http.StatusNotImplemented)
+			return
+		}
+
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, c.Filename(a.court, "mp4")))
+		if err := c.Remux(r.Context(), a.ffmpegPath, w); err != nil {
+			log.Printf("Failed to export MP4 clip: %v\n", err)
+		}
+	default:
+		http.Error(w, "Bad Request: format must be ts or mp4", http.StatusBadRequest)
+	}
+}
+
+// parseRange reads the from and to parameters of a request, from the query
+// or a posted form. Times without an offset are in the club's time zone.
+func parseRange(r *http.Request, c *catalog.Catalog) (time.Time, time.Time, error) {
+	from, err := c.ParseTime(r.FormValue("from"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("from: %w", err)
+	}
+	to, err := c.ParseTime(r.FormValue("to"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("to: %w", err)
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, errors.New("to must be after from")
+	}
+	if to.Sub(from) > maxClipDuration {
+		return time.Time{}, time.Time{}, fmt.Errorf("clips may be at most %s long", maxClipDuration)
+	}
+	return from, to, nil
+}
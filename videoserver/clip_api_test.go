@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"archive/objectstore"
+	"videoserver/catalog"
+)
+
+func TestClipAPI_Export_Range(t *testing.T) {
+	// Setup
+	basePath := t.TempDir()
+	dir := filepath.Join(basePath, "2025", "04", "11", "01")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"playlist.m3u8": `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T01:00:00.000+0000
+segment_000.ts
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T01:00:10.000+0000
+segment_001.ts
+`,
+		"segment_000.ts": "0123",
+		"segment_001.ts": "4567",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	api := clipAPI{catalog: catalog.New(objectstore.NewFilesystem(basePath), time.UTC), court: "court6"}
+
+	// Execute
+	request := httptest.NewRequest("GET", "/api/clip?from=2025-04-11T01:00:05Z&to=2025-04-11T01:00:15Z", nil)
+	request.Header.Set("Range", "bytes=2-5")
+	recorder := httptest.NewRecorder()
+	api.export(recorder, request)
+
+	// Assert
+	if recorder.Code != http.StatusPartialContent {
+		t.Fatalf("Status = %d, want %d", recorder.Code, http.StatusPartialContent)
+	}
+	if body := recorder.Body.String(); body != "2345" {
+		t.Errorf("Body = %q, want %q", body, "2345")
+	}
+	expectedDisposition := `attachment; filename="court6_20250411T010000Z-20250411T010020Z.ts"`
+	if got := recorder.Header().Get("Content-Disposition"); got != expectedDisposition {
+		t.Errorf("Content-Disposition = %s, want %s", got, expectedDisposition)
+	}
+	if got := recorder.Header().Get("Content-Type"); got != "video/mp2t" {
+		t.Errorf("Content-Type = %s, want video/mp2t", got)
+	}
+}
+
+func TestClipAPI_Export_BadRange(t *testing.T) {
+	api := clipAPI{catalog: catalog.New(objectstore.NewFilesystem(t.TempDir()), time.UTC), court: "court6"}
+
+	request := httptest.NewRequest("GET", "/api/clip?from=2025-04-11T01:00:05Z&to=2025-04-11T00:00:00Z", nil)
+	recorder := httptest.NewRecorder()
+	api.export(recorder, request)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Status = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+}
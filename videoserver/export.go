@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"archive/objectstore"
+	"videoserver/catalog"
+	"videoserver/clip"
+)
+
+// runExport writes the archived footage in a time range to a single file
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	archiveDir := flags.String("archive", archiveLocation(), "archive directory or object store location")
+	fromFlag := flags.String("from", "", "start of the clip (RFC3339, or local time such as 2006-01-02T15:04)")
+	toFlag := flags.String("to", "", "end of the clip (RFC3339, or local time such as 2006-01-02T15:04)")
+	output := flags.String("o", "", "output file (defaults to a name with the court and time)")
+	format := flags.String("format", "ts", "output format: ts, or mp4 to remux with ffmpeg")
+	ffmpegPath := flags.String("ffmpeg", "ffmpeg", "ffmpeg binary used for mp4 output")
+	flags.Parse(args)
+
+	archiveStore, err := objectstore.Open(*archiveDir)
+	if err != nil {
+		log.Fatalf("Error: Unable to open archive: %v\n", err)
+	}
+	archiveCatalog := catalog.New(archiveStore, clubLocation())
+	from, err := archiveCatalog.ParseTime(*fromFlag)
+	if err != nil {
+		log.Fatalf("Error: -from: %v\n", err)
+	}
+	to, err := archiveCatalog.ParseTime(*toFlag)
+	if err != nil {
+		log.Fatalf("Error: -to: %v\n", err)
+	}
+	if *format != "ts" && *format != "mp4" {
+		log.Fatalln("Error: -format must be ts or mp4")
+	}
+
+	c, err := clip.New(context.Background(), archiveCatalog, from, to)
+	if err != nil {
+		log.Fatalf("Error: Unable to create clip: %v\n", err)
+	}
+
+	filename := *output
+	if filename == "" {
+		filename = c.Filename(courtName(), *format)
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		log.Fatalf("Error: Unable to create %s: %v\n", filename, err)
+	}
+	defer file.Close()
+
+	if *format == "mp4" {
+		err = c.Remux(context.Background(), *ffmpegPath, file)
+	} else {
+		content := c.Open(context.Background())
+		defer content.Close()
+		_, err = io.Copy(file, content)
+	}
+	if err != nil {
+		log.Fatalf("Error: Unable to write %s: %v\n", filename, err)
+	}
+
+	fmt.Printf("Exported %d segments (%s to %s) to %s\n",
+		len(c.Segments), c.Start.Format(time.RFC3339), c.End.Format(time.RFC3339), filename)
+}
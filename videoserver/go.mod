@@ -1,3 +1,9 @@
 module videoserver
 
 go 1.23.3
+
+require archive v0.0.0
+
+require golang.org/x/crypto v0.36.0
+
+replace archive => ../archive
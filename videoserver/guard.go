@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"videoserver/auth"
+)
+
+// trustedProxies returns the proxies allowed to set X-Forwarded-For, from
+// TRUSTED_PROXIES, a comma separated list of IPs and CIDR ranges such as
+// 172.16.0.0/12 for Caddy on the compose network
+func trustedProxies() []netip.Prefix {
+	value, found := os.LookupEnv("TRUSTED_PROXIES")
+	if !found {
+		return nil
+	}
+	proxies, err := parsePrefixes(value)
+	if err != nil {
+		log.Fatalf("Error: invalid TRUSTED_PROXIES: %v\n", err)
+	}
+	return proxies
+}
+
+// parsePrefixes parses a comma separated list of IPs and CIDR ranges
+func parsePrefixes(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !strings.Contains(field, "/") {
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// clientIPs finds the client a request came from, looking through the
+// proxies it trusts
+type clientIPs struct {
+	trusted []netip.Prefix
+}
+
+// of returns the client IP of a request. X-Forwarded-For is only read when
+// the request came from a trusted proxy, and only as far back as the hops
+// added by trusted proxies, so clients can't pick their own address.
+func (c clientIPs) of(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	addr = addr.Unmap()
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0 && c.isTrusted(addr); i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+	}
+	return addr.String()
+}
+
+// isTrusted reports whether an address is a trusted proxy
+func (c clientIPs) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range c.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// signInGuard slows down password and token guessing by locking out client
+// IPs, and user names on the client that fail to sign in too often. User
+// names are only locked out on the client guessing at them, so others can't
+// lock people out of their accounts.
+type signInGuard struct {
+	clients clientIPs
+	byIP    *auth.Limiter
+	byUser  *auth.Limiter
+}
+
+// newSignInGuard returns a guard that locks a client IP out after 20
+// failures and a user name on a client after 5 failures in 15 minutes, for
+// 15 minutes
+func newSignInGuard(trusted []netip.Prefix) *signInGuard {
+	return &signInGuard{
+		clients: clientIPs{trusted: trusted},
+		byIP:    auth.NewLimiter(20, 15*time.Minute, 15*time.Minute),
+		byUser:  auth.NewLimiter(5, 15*time.Minute, 15*time.Minute),
+	}
+}
+
+// locked returns how long the request's client or a user name on it is
+// still locked out for
+func (g *signInGuard) locked(r *http.Request, name string) time.Duration {
+	ip := g.clients.of(r)
+	wait := g.byIP.Locked(ip)
+	if name != "" {
+		wait = max(wait, g.byUser.Locked(userOnClient(name, ip)))
+	}
+	return wait
+}
+
+// failed records and logs a failed sign in
+func (g *signInGuard) failed(r *http.Request, name, method string) {
+	ip := g.clients.of(r)
+	log.Printf("Failed %s sign in for %q from %s\n", method, name, ip)
+	if g.byIP.Fail(ip) {
+		log.Printf("Locked out %s after too many failed sign ins\n", ip)
+	}
+	if name != "" && g.byUser.Fail(userOnClient(name, ip)) {
+		log.Printf("Locked out user %q on %s after too many failed sign ins\n", name, ip)
+	}
+}
+
+// succeeded forgets the failures of a user name on the request's client. A
+// client's failures are left to expire, so signing in with one account
+// between guesses at others doesn't keep the client from being locked out.
+func (g *signInGuard) succeeded(r *http.Request, name string) {
+	g.byUser.Succeed(userOnClient(name, g.clients.of(r)))
+}
+
+// userOnClient returns the key of a user name on a client IP
+func userOnClient(name, ip string) string {
+	return ip + " " + name
+}
+
+// tooManyRequests responds that the client must wait before trying again
+func tooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, fmt.Sprintf("Too Many Requests: try again in %s", wait.Round(time.Second)), http.StatusTooManyRequests)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"videoserver/auth"
+)
+
+func TestClientIPs(t *testing.T) {
+	trusted, err := parsePrefixes("172.16.0.0/12, 10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := clientIPs{trusted: trusted}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		expected     string
+	}{
+		{"direct", "203.0.113.5:4321", "", "203.0.113.5"},
+		{"untrusted peer can't forward", "203.0.113.5:4321", "198.51.100.1", "203.0.113.5"},
+		{"trusted proxy", "172.18.0.3:4321", "198.51.100.1", "198.51.100.1"},
+		{"spoofed hop before the proxy", "172.18.0.3:4321", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.1:4321", "198.51.100.1, 172.18.0.3", "198.51.100.1"},
+		{"garbage hop", "172.18.0.3:4321", "not-an-ip", "172.18.0.3"},
+		{"ipv6", "[2001:db8::1]:4321", "", "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "/", nil)
+			request.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				request.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+
+			if got := clients.of(request); got != tt.expected {
+				t.Errorf("of = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_LocksOutPasswordGuessing(t *testing.T) {
+	// Setup
+	users, err := auth.OpenStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Add("coach", "coach password", auth.RoleCoach); err != nil {
+		t.Fatal(err)
+	}
+	requireRole := newAuthMiddleware(users, auth.NewSessions(time.Hour), openTestTokens(t), newSignInGuard(nil))
+	handler := requireRole(auth.RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	try := func(password string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", "/api/archive", nil)
+		request.SetBasicAuth("coach", password)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// Execute
+	for i := 0; i < 5; i++ {
+		if recorder := try("guess"); recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("guess %d = %d, want %d", i+1, recorder.Code, http.StatusUnauthorized)
+		}
+	}
+	recorder := try("coach password")
+
+	// Assert
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("Status = %d, want %d", recorder.Code, http.StatusTooManyRequests)
+	}
+	if recorder.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After is not set")
+	}
+}
+
+func TestAuthMiddleware_GuessesDontLockOutOtherClients(t *testing.T) {
+	// Setup
+	users, err := auth.OpenStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Add("coach", "coach password", auth.RoleCoach); err != nil {
+		t.Fatal(err)
+	}
+	requireRole := newAuthMiddleware(users, auth.NewSessions(time.Hour), openTestTokens(t), newSignInGuard(nil))
+	handler := requireRole(auth.RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	try := func(ip, password string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", "/api/archive", nil)
+		request.RemoteAddr = ip + ":1234"
+		request.SetBasicAuth("coach", password)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// Execute
+	for i := 0; i < 5; i++ {
+		try("203.0.113.7", "guess")
+	}
+	recorder := try("198.51.100.1", "coach password")
+
+	// Assert
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Status = %d, want %d from a client that didn't guess", recorder.Code, http.StatusOK)
+	}
+	if recorder := try("203.0.113.7", "coach password"); recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("Status = %d, want %d from the guessing client", recorder.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestAuthMiddleware_ValidSignInsDontResetClientLockout(t *testing.T) {
+	// Setup
+	users, err := auth.OpenStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Add("coach", "coach password", auth.RoleCoach); err != nil {
+		t.Fatal(err)
+	}
+	requireRole := newAuthMiddleware(users, auth.NewSessions(time.Hour), openTestTokens(t), newSignInGuard(nil))
+	handler := requireRole(auth.RoleViewer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	try := func(name, password string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", "/api/archive", nil)
+		request.SetBasicAuth(name, password)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// Execute: guess at a different account each time, signing in with a
+	// valid one in between
+	for i := 0; i < 20; i++ {
+		if recorder := try(fmt.Sprintf("player%d", i), "guess"); recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("guess %d = %d, want %d", i+1, recorder.Code, http.StatusUnauthorized)
+		}
+		if i < 19 {
+			if recorder := try("coach", "coach password"); recorder.Code != http.StatusOK {
+				t.Fatalf("sign in after guess %d = %d, want %d", i+1, recorder.Code, http.StatusOK)
+			}
+		}
+	}
+	recorder := try("coach", "coach password")
+
+	// Assert
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("Status = %d, want %d once the client made 20 wrong guesses", recorder.Code, http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,82 @@
+// Package jsonfile keeps data in JSON files shared between the server and
+// the management commands
+package jsonfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File is a JSON file that several processes may change, such as the
+// server and the management commands. It is read again whenever it changes
+// and replaced atomically when written.
+type File struct {
+	// Path is where the file is
+	Path    string
+	modTime time.Time
+	size    int64
+}
+
+// Load decodes the file into v if it changed since it was last loaded or
+// saved. A missing file is left unloaded.
+func (f *File) Load(v any) (bool, error) {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", f.Path, err)
+	}
+	f.modTime, f.size = info.ModTime(), info.Size()
+	return true, nil
+}
+
+// Save writes v to a temporary file and renames it into place. Each save
+// has its own temporary file, so processes saving at once don't write over
+// each other's.
+func (f *File) Save(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(f.Path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f.Path); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return err
+	}
+	f.modTime, f.size = info.ModTime(), info.Size()
+	return nil
+}
@@ -0,0 +1,85 @@
+package jsonfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFile_ConcurrentSaves(t *testing.T) {
+	// Setup
+	dir := t.TempDir()
+	path := filepath.Join(dir, "users.json")
+
+	// Execute: two processes' files saving at once
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			file := File{Path: path}
+			for j := 0; j < 50; j++ {
+				if err := file.Save([]int{i, j}); err != nil {
+					t.Errorf("Save failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	var saved []int
+	if _, err := (&File{Path: path}).Load(&saved); err != nil || len(saved) != 2 {
+		t.Errorf("Load = %v, %v, want one of the saves", saved, err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only %s", len(entries), filepath.Base(path))
+	}
+}
+
+func TestStore_PicksUpOtherProcessesChanges(t *testing.T) {
+	// Setup
+	path := filepath.Join(t.TempDir(), "values.json")
+	key := func(v string) string { return v }
+	server, err := Open(path, key, strings.Compare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	command, err := Open(path, key, strings.Compare)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Execute
+	for _, value := range []string{"b", "a"} {
+		err := command.Update(func(values map[string]string) error {
+			values[value] = value
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+
+	// Assert
+	if values := server.List(); strings.Join(values, ",") != "a,b" {
+		t.Errorf("List = %v, want [a b]", values)
+	}
+	if _, found := server.Get("b"); !found {
+		t.Error("Get(b) did not find the value")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"a",`) {
+		t.Errorf("file = %s, want the values sorted", data)
+	}
+}
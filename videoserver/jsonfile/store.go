@@ -0,0 +1,102 @@
+package jsonfile
+
+import (
+	"log"
+	"slices"
+	"sync"
+)
+
+// Store keeps values with a key, such as users by name, as a sorted list in
+// a File. Changes made to the file by other processes are picked up on the
+// next read.
+type Store[V any] struct {
+	file    File
+	key     func(V) string
+	compare func(a, b V) int
+	mu      sync.Mutex
+	values  map[string]V
+}
+
+// Open opens the store at path, whose values have key and are saved in the
+// order of compare. A missing file is an empty store.
+func Open[V any](path string, key func(V) string, compare func(a, b V) int) (*Store[V], error) {
+	s := &Store[V]{
+		file:    File{Path: path},
+		key:     key,
+		compare: compare,
+		values:  make(map[string]V),
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Path returns where the store's file is
+func (s *Store[V]) Path() string {
+	return s.file.Path
+}
+
+// Get returns the value with a key
+func (s *Store[V]) Get(key string) (V, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadOrLog()
+	value, found := s.values[key]
+	return value, found
+}
+
+// List returns every value, sorted
+func (s *Store[V]) List() []V {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadOrLog()
+	return s.sorted()
+}
+
+// Update applies a change to the latest values by key and saves them
+func (s *Store[V]) Update(change func(values map[string]V) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+	if err := change(s.values); err != nil {
+		return err
+	}
+	return s.file.Save(s.sorted())
+}
+
+// reload reads the file if it changed since it was last read
+func (s *Store[V]) reload() error {
+	var values []V
+	changed, err := s.file.Load(&values)
+	if err != nil || !changed {
+		return err
+	}
+
+	s.values = make(map[string]V, len(values))
+	for _, value := range values {
+		s.values[s.key(value)] = value
+	}
+	return nil
+}
+
+// reloadOrLog reloads the file, keeping the values that were loaded last if
+// that fails
+func (s *Store[V]) reloadOrLog() {
+	if err := s.reload(); err != nil {
+		log.Printf("Failed to reload %s: %v\n", s.file.Path, err)
+	}
+}
+
+// sorted returns the values in the order of compare
+func (s *Store[V]) sorted() []V {
+	values := make([]V, 0, len(s.values))
+	for _, value := range s.values {
+		values = append(values, value)
+	}
+	slices.SortFunc(values, s.compare)
+	return values
+}
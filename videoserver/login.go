@@ -0,0 +1,272 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"videoserver/audit"
+	"videoserver/auth"
+)
+
+// csrfHeader and csrfField carry a session's CSRF token on requests that
+// change state, from scripts and from forms respectively
+const (
+	csrfHeader = "X-CSRF-Token"
+	csrfField  = "csrf_token"
+)
+
+// sessionTTL returns how long a sign in lasts from SESSION_TTL, defaulting
+// to 30 days
+func sessionTTL() time.Duration {
+	value, found := os.LookupEnv("SESSION_TTL")
+	if !found {
+		return 30 * 24 * time.Hour
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Fatalf("Error: invalid SESSION_TTL %q\n", value)
+	}
+	return ttl
+}
+
+// secureCookies reports whether session cookies are only sent over HTTPS,
+// which COOKIE_SECURE=false turns off for development over plain HTTP
+func secureCookies() bool {
+	return os.Getenv("COOKIE_SECURE") != "false"
+}
+
+// login signs users in with a form and keeps them signed in with a cookie
+type login struct {
+	users    authenticator
+	sessions *auth.Sessions
+	guard    *signInGuard
+	page     string
+	secure   bool
+}
+
+// form handles GET /login by serving the login page
+func (l login) form(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, l.page)
+}
+
+// submit handles POST /login, redirecting to the page that was asked for
+// once signed in and back to the form otherwise
+func (l login) submit(w http.ResponseWriter, r *http.Request) {
+	// Keep other sites from signing people in as someone else
+	if crossSite(r) {
+		http.Error(w, "Forbidden: cross-site sign in", http.StatusForbidden)
+		return
+	}
+	next := safeRedirect(r.PostFormValue("next"))
+	name := r.PostFormValue("username")
+	if l.guard.locked(r, name) > 0 {
+		http.Redirect(w, r, "/login?locked=1&next="+url.QueryEscape(next), http.StatusSeeOther)
+		return
+	}
+	user, ok := l.users.Authenticate(name, r.PostFormValue("password"))
+	if !ok {
+		l.guard.failed(r, name, "form")
+		http.Redirect(w, r, "/login?failed=1&next="+url.QueryEscape(next), http.StatusSeeOther)
+		return
+	}
+	l.guard.succeeded(r, name)
+
+	session := l.sessions.Create(user.Name)
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.SessionCookie,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.Expires,
+		HttpOnly: true,
+		Secure:   l.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// logout handles POST /logout
+func (l login) logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(auth.SessionCookie); err == nil {
+		l.sessions.Delete(cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.SessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   l.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// session handles GET /api/session, telling the page who is signed in and
+// the CSRF token to send with requests that change state
+func (l login) session(w http.ResponseWriter, r *http.Request) {
+	user, _ := auth.UserFromContext(r.Context())
+	response := map[string]any{"user": user.Name, "role": user.Role}
+	if session, ok := sessionFromRequest(r, l.sessions); ok {
+		response["csrf_token"] = session.CSRFToken
+		response["expires"] = session.Expires
+	}
+	writeJSON(w, response)
+}
+
+// safeRedirect returns next if it is a path on this server, and / otherwise
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// sessionFromRequest returns the session of the request's cookie
+func sessionFromRequest(r *http.Request, sessions *auth.Sessions) (auth.Session, bool) {
+	cookie, err := r.Cookie(auth.SessionCookie)
+	if err != nil {
+		return auth.Session{}, false
+	}
+	return sessions.Get(cookie.Value)
+}
+
+// changesState reports whether a request's method may change state
+func changesState(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// crossSite reports whether a browser sent a request from another site,
+// going by its Sec-Fetch-Site header or, from browsers without it, by its
+// Origin header
+func crossSite(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "cross-site"
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err != nil || !strings.EqualFold(u.Host, r.Host)
+}
+
+// bearerToken returns the API token in the request's Authorization header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, secret, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return secret, true
+}
+
+// tokenUser returns the user an API token acts for, with the role of the
+// token's scope. A token never grants more than its user's own role, and
+// stops working when its user is removed.
+func tokenUser(users authenticator, tokens *auth.TokenStore, secret string) (auth.User, bool) {
+	token, ok := tokens.Authenticate(secret)
+	if !ok {
+		return auth.User{}, false
+	}
+	user, ok := users.Get(token.User)
+	if !ok {
+		return auth.User{}, false
+	}
+	if user.Role.Allows(token.Scope.Role()) {
+		user.Role = token.Scope.Role()
+	}
+	return user, true
+}
+
+// fromBrowser reports whether a request was made by a browser rather than a
+// script or media player, going by the headers browsers add
+func fromBrowser(r *http.Request) bool {
+	return r.Header.Get("Sec-Fetch-Mode") != "" || strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// newAuthMiddleware returns a middleware that signs users in with a session
+// cookie or, for scripts, an API token or basic auth, and only lets through
+// those whose role allows the required role. Requests that change state with
+// a session must carry its CSRF token, and ones with basic auth mustn't come
+// from another site. Clients that keep failing to sign in
+// are locked out for a while.
+func newAuthMiddleware(users authenticator, sessions *auth.Sessions, tokens *auth.TokenStore, guard *signInGuard) func(auth.Role, http.Handler) http.Handler {
+	return func(required auth.Role, handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var user auth.User
+			var ok bool
+			var via string
+			if session, found := sessionFromRequest(r, sessions); found {
+				via = "session"
+				// Look the user up again so removed users and role changes
+				// take effect straight away
+				user, ok = users.Get(session.User)
+				if ok && changesState(r) {
+					token := r.Header.Get(csrfHeader)
+					if token == "" {
+						token = r.PostFormValue(csrfField)
+					}
+					if !session.CheckCSRF(token) {
+						http.Error(w, "Forbidden: missing or invalid CSRF token", http.StatusForbidden)
+						return
+					}
+				}
+			} else if secret, found := bearerToken(r); found {
+				via = "token"
+				if wait := guard.locked(r, ""); wait > 0 {
+					tooManyRequests(w, wait)
+					return
+				}
+				if user, ok = tokenUser(users, tokens, secret); !ok {
+					guard.failed(r, "", "token")
+				}
+			} else if name, password, found := r.BasicAuth(); found {
+				via = "basic auth"
+				// Browsers send remembered basic auth credentials along
+				// with requests from other sites
+				if changesState(r) && crossSite(r) {
+					http.Error(w, "Forbidden: cross-site request", http.StatusForbidden)
+					return
+				}
+				if wait := guard.locked(r, name); wait > 0 {
+					tooManyRequests(w, wait)
+					return
+				}
+				if user, ok = users.Authenticate(name, password); ok {
+					guard.succeeded(r, name)
+				} else {
+					guard.failed(r, name, "basic auth")
+				}
+			}
+
+			if !ok {
+				audit.SetUser(r.Context(), "", via)
+				// Send people opening a page to the login form
+				if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+					http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+					return
+				}
+				// Only challenge scripts, so that the browser's basic auth
+				// prompt doesn't pop up over the login page
+				if !fromBrowser(r) {
+					w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+				}
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			audit.SetUser(r.Context(), user.Name, via)
+			if !user.Role.Allows(required) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			handler.ServeHTTP(w, r.WithContext(auth.WithUser(r.Context(), user)))
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"videoserver/auth"
+)
+
+func TestAuthMiddleware_BasicAuthEnforcesRoles(t *testing.T) {
+	// Setup
+	store, err := auth.OpenStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add("viewer", "viewer password", auth.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add("coach", "coach password", auth.RoleCoach); err != nil {
+		t.Fatal(err)
+	}
+	requireRole := newAuthMiddleware(store, auth.NewSessions(time.Hour), openTestTokens(t), newSignInGuard(nil))
+	handler := requireRole(auth.RoleCoach, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := auth.UserFromContext(r.Context())
+		w.Write([]byte(user.Name))
+	}))
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		expected int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"wrong password", "coach", "viewer password", http.StatusUnauthorized},
+		{"role too low", "viewer", "viewer password", http.StatusForbidden},
+		{"role allowed", "coach", "coach password", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Execute
+			request := httptest.NewRequest("GET", "/api/clip", nil)
+			if tt.user != "" {
+				request.SetBasicAuth(tt.user, tt.password)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			// Assert
+			if recorder.Code != tt.expected {
+				t.Errorf("Status = %d, want %d", recorder.Code, tt.expected)
+			}
+			if tt.expected == http.StatusOK && recorder.Body.String() != tt.user {
+				t.Errorf("Body = %q, want the signed in user %q", recorder.Body.String(), tt.user)
+			}
+		})
+	}
+}
+
+func TestLogin_SessionFlow(t *testing.T) {
+	// Setup
+	store, err := auth.OpenStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add("coach", "coach password", auth.RoleCoach); err != nil {
+		t.Fatal(err)
+	}
+	sessions := auth.NewSessions(time.Hour)
+	requireRole := newAuthMiddleware(store, sessions, openTestTokens(t), newSignInGuard(nil))
+	logins := login{users: store, sessions: sessions, guard: newSignInGuard(nil), secure: true}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", logins.submit)
+	mux.Handle("POST /logout", requireRole(auth.RoleViewer, http.HandlerFunc(logins.logout)))
+	mux.Handle("GET /api/session", requireRole(auth.RoleViewer, http.HandlerFunc(logins.session)))
+
+	// Execute a wrong password
+	form := url.Values{"username": {"coach"}, "password": {"wrong password"}, "next": {"/api/session"}}
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	mux.ServeHTTP(recorder, request)
+
+	// Assert
+	if location := recorder.Header().Get("Location"); !strings.HasPrefix(location, "/login?failed=1") {
+		t.Errorf("Location = %s, want the login form", location)
+	}
+
+	// Execute the right password
+	form.Set("password", "coach password")
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	mux.ServeHTTP(recorder, request)
+
+	// Assert
+	if location := recorder.Header().Get("Location"); location != "/api/session" {
+		t.Errorf("Location = %s, want /api/session", location)
+	}
+	cookies := recorder.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || !cookies[0].Secure || cookies[0].SameSite != http.SameSiteLaxMode {
+		t.Fatalf("Cookies = %+v, want one secure HttpOnly session cookie", cookies)
+	}
+	cookie := cookies[0]
+
+	// Execute a request with the session
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest("GET", "/api/session", nil)
+	request.AddCookie(cookie)
+	mux.ServeHTTP(recorder, request)
+
+	// Assert
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"user":"coach"`) {
+		t.Fatalf("GET /api/session = %d %s", recorder.Code, recorder.Body.String())
+	}
+	session, _ := sessions.Get(cookie.Value)
+
+	// Execute logout without the CSRF token
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest("POST", "/logout", nil)
+	request.AddCookie(cookie)
+	mux.ServeHTTP(recorder, request)
+
+	// Assert
+	if recorder.Code != http.StatusForbidden {
+		t.Errorf("Status = %d, want %d without a CSRF token", recorder.Code, http.StatusForbidden)
+	}
+
+	// Execute logout with the CSRF token
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest("POST", "/logout", nil)
+	request.Header.Set(csrfHeader, session.CSRFToken)
+	request.AddCookie(cookie)
+	mux.ServeHTTP(recorder, request)
+
+	// Assert
+	if recorder.Code != http.StatusSeeOther {
+		t.Errorf("Status = %d, want %d", recorder.Code, http.StatusSeeOther)
+	}
+	if _, ok := sessions.Get(cookie.Value); ok {
+		t.Error("session still exists after logout")
+	}
+}
+
+func TestAuthMiddleware_RejectsCrossSiteRequests(t *testing.T) {
+	// Setup
+	store, err := auth.OpenStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add("coach", "coach password", auth.RoleCoach); err != nil {
+		t.Fatal(err)
+	}
+	requireRole := newAuthMiddleware(store, auth.NewSessions(time.Hour), openTestTokens(t), newSignInGuard(nil))
+	logins := login{users: store, sessions: auth.NewSessions(time.Hour), guard: newSignInGuard(nil)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", logins.submit)
+	mux.Handle("/api/clip", requireRole(auth.RoleCoach, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		header   string
+		value    string
+		expected int
+	}{
+		{"script", "POST", "/api/clip", "", "", http.StatusOK},
+		{"same origin", "POST", "/api/clip", "Origin", "http://example.com", http.StatusOK},
+		{"same site fetch", "POST", "/api/clip", "Sec-Fetch-Site", "same-origin", http.StatusOK},
+		{"cross-site fetch", "POST", "/api/clip", "Sec-Fetch-Site", "cross-site", http.StatusForbidden},
+		{"other origin", "POST", "/api/clip", "Origin", "https://evil.example", http.StatusForbidden},
+		{"null origin", "DELETE", "/api/clip", "Origin", "null", http.StatusForbidden},
+		{"cross-site read", "GET", "/api/clip", "Sec-Fetch-Site", "cross-site", http.StatusOK},
+		{"login", "POST", "/login", "Origin", "http://example.com", http.StatusSeeOther},
+		{"cross-site login", "POST", "/login", "Origin", "https://evil.example", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Execute
+			form := url.Values{"username": {"coach"}, "password": {"coach password"}}
+			request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(form.Encode()))
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			request.SetBasicAuth("coach", "coach password")
+			if tt.header != "" {
+				request.Header.Set(tt.header, tt.value)
+			}
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, request)
+
+			// Assert
+			if recorder.Code != tt.expected {
+				t.Errorf("Status = %d, want %d", recorder.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_RedirectsPagesToLogin(t *testing.T) {
+	// Setup
+	store, err := auth.OpenStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	requireRole := newAuthMiddleware(store, auth.NewSessions(time.Hour), openTestTokens(t), newSignInGuard(nil))
+	handler := requireRole(auth.RoleViewer, http.NotFoundHandler())
+
+	// Execute a page
+	request := httptest.NewRequest("GET", "/?day=2025-04-11", nil)
+	request.Header.Set("Accept", "text/html,application/xhtml+xml")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	// Assert
+	if location := recorder.Header().Get("Location"); location != "/login?next=%2F%3Fday%3D2025-04-11" {
+		t.Errorf("Location = %s", location)
+	}
+
+	// Execute a script
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/archive", nil))
+
+	// Assert
+	if recorder.Code != http.StatusUnauthorized || recorder.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Status = %d, want a basic auth challenge", recorder.Code)
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	tests := map[string]string{
+		"/archive/":           "/archive/",
+		"":                    "/",
+		"https://example.com": "/",
+		"//example.com":       "/",
+		"/\\example.com":      "/",
+	}
+
+	for next, expected := range tests {
+		if got := safeRedirect(next); got != expected {
+			t.Errorf("safeRedirect(%q) = %q, want %q", next, got, expected)
+		}
+	}
+}
+
+// openTestTokens returns an empty API token store
+func openTestTokens(t *testing.T) *auth.TokenStore {
+	tokens, err := auth.OpenTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
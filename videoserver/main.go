@@ -1,53 +1,200 @@
 package main
 
 import (
-	"crypto/subtle"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
+	_ "time/tzdata"
+
+	"archive/health"
+	"archive/metrics"
+	"archive/objectstore"
+	"videoserver/audit"
+	"videoserver/auth"
+	"videoserver/catalog"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "users":
+			runUsers(os.Args[2:])
+			return
+		case "share":
+			runShare(os.Args[2:])
+			return
+		case "tokens":
+			runTokens(os.Args[2:])
+			return
+		case "audit":
+			runAudit(os.Args[2:])
+			return
+		case "bookings":
+			runBookings(os.Args[2:])
+			return
+		default:
+			log.Fatalf("Error: unknown command %q\n", os.Args[1])
+		}
+	}
+
+	serve()
+}
+
+// serve runs the web server
+func serve() {
 	port, found := os.LookupEnv("PORT")
 	if !found {
 		log.Fatalln("Error: PORT environment variable is not set")
 	}
 
-	// Get basic auth credentials from environment
-	authUser, found := os.LookupEnv("AUTH_USER")
-	if !found {
-		log.Fatalln("Error: AUTH_USER environment variable is not set")
-	}
-	authPass, found := os.LookupEnv("AUTH_PASSWORD")
-	if !found {
-		log.Fatalln("Error: AUTH_PASSWORD environment variable is not set")
+	// Sign users in from the user file, enforcing each route's role
+	users, userStore := loadAuthenticator()
+	sessions := auth.NewSessions(sessionTTL())
+	tokens := openTokens()
+	guard := newSignInGuard(trustedProxies())
+	requireRole := newAuthMiddleware(newSignInCache(users, signInCacheTTL), sessions, tokens, guard)
+
+	// Record who requested which footage
+	auditLog, err := audit.Open(auditDir(), auditMaxSize, auditMaxFiles)
+	if err != nil {
+		log.Fatalf("Error: Unable to open AUDIT_DIR: %v\n", err)
 	}
-
-	// Initialize the basic auth middleware
-	basicAuth := newBasicAuthMiddleware(authUser, authPass)
+	defer auditLog.Close()
+	audited := newAuditMiddleware(auditLog, guard.clients)
 
 	mux := http.NewServeMux()
+	registry := metrics.NewRegistry()
+	instrument := newMetricsMiddleware(registry)
 
-	// Serve site files from root with basic auth
+	// Sign in with a form and a session cookie; scripts can use basic auth
+	logins := login{users: users, sessions: sessions, guard: guard, page: "/site/login.html", secure: secureCookies()}
+	mux.Handle("GET /login", noCache(http.HandlerFunc(logins.form)))
+	mux.HandleFunc("POST /login", logins.submit)
+	mux.Handle("POST /logout", requireRole(auth.RoleViewer, http.HandlerFunc(logins.logout)))
+	mux.Handle("GET /api/session", requireRole(auth.RoleViewer, noCache(http.HandlerFunc(logins.session))))
+
+	// Serve site files from root to viewers
 	siteServer := http.FileServer(http.Dir("/site"))
-	mux.Handle("GET /", basicAuth(noCache(siteServer)))
+	mux.Handle("GET /", requireRole(auth.RoleViewer, noCache(siteServer)))
 
-	// Serve archive files with basic auth
-	archiveServer := http.FileServer(http.Dir("/archive"))
-	mux.Handle("GET /archive/", basicAuth(http.StripPrefix("/archive", archiveServer)))
+	// Serve archive files to viewers from the archive's object store
+	archiveStore, err := objectstore.Open(archiveLocation())
+	if err != nil {
+		log.Fatalf("Error: Unable to open ARCHIVE_DIR: %v\n", err)
+	}
+	archiveServer := archiveFiles{store: archiveStore}
+	api := archiveAPI{catalog: catalog.New(archiveStore, clubLocation())}
+
+	// Label footage with the court's bookings, importing BOOKINGS_URL when
+	// it is set, and limit viewers to their own bookings when
+	// RESTRICT_TO_BOOKINGS is on
+	bookingStore := openBookings()
+	if source, found := os.LookupEnv("BOOKINGS_URL"); found {
+		go refreshBookings(bookingStore, source, bookingsCourt(), api.catalog.Location(), bookingsRefresh())
+	}
+	access := bookingAccess{enabled: restrictToBookings(), bookings: bookingStore, catalog: api.catalog, now: time.Now}
+	api.access = access
+	mux.Handle("GET /archive/", audited(requireRole(auth.RoleViewer, http.StripPrefix("/archive", access.archive(archiveServer)))))
 
-	// Serve stream files with no-cache and basic auth
+	// Serve stream files with no-cache to viewers
 	streamServer := http.FileServer(http.Dir("/stream"))
-	mux.Handle("GET /stream/", basicAuth(noCache(http.StripPrefix("/stream", streamServer))))
+	mux.Handle("GET /stream/", audited(liveFootage(requireRole(auth.RoleViewer, access.live(noCache(http.StripPrefix("/stream", streamServer)))))))
+
+	// Serve the archive catalog with no-cache to viewers
+	mux.Handle("GET /api/archive", requireRole(auth.RoleViewer, noCache(http.HandlerFunc(api.listDays))))
+	mux.Handle("GET /api/archive/{date}", requireRole(auth.RoleViewer, noCache(http.HandlerFunc(api.listHours))))
+	mux.Handle("GET /api/archive/at", requireRole(auth.RoleViewer, noCache(http.HandlerFunc(api.resolve))))
+
+	// Serve clip downloads to coaches; MP4 output needs FFMPEG_PATH
+	ffmpegPath, _ := os.LookupEnv("FFMPEG_PATH")
+	clips := clipAPI{catalog: api.catalog, court: courtName(), ffmpegPath: ffmpegPath}
+	mux.Handle("GET /api/clip", audited(requireRole(auth.RoleCoach, http.HandlerFunc(clips.export))))
+
+	// Let viewers bookmark moments and play the footage around them
+	bookmarks := bookmarksAPI{bookmarks: openBookmarks(), catalog: api.catalog, access: access, now: time.Now}
+	mux.Handle("GET /api/bookmarks", requireRole(auth.RoleViewer, noCache(http.HandlerFunc(bookmarks.list))))
+	mux.Handle("POST /api/bookmarks", requireRole(auth.RoleViewer, http.HandlerFunc(bookmarks.create)))
+	mux.Handle("DELETE /api/bookmarks/{id}", requireRole(auth.RoleViewer, http.HandlerFunc(bookmarks.delete)))
+	mux.Handle("GET /api/bookmarks/{id}/clip.m3u8", audited(requireRole(auth.RoleViewer, noCache(http.HandlerFunc(bookmarks.clip)))))
+
+	// Let viewers find recordings by booking
+	bookings := bookingsAPI{bookings: bookingStore, catalog: api.catalog, access: access, now: time.Now}
+	mux.Handle("GET /api/bookings", requireRole(auth.RoleViewer, noCache(http.HandlerFunc(bookings.list))))
+	mux.Handle("GET /api/bookings/{id}/playlist.m3u8", audited(requireRole(auth.RoleViewer, noCache(http.HandlerFunc(bookings.playlist)))))
+
+	// Let coaches share footage with people who can't sign in, when
+	// SHARE_SECRET is set
+	if signer := shareSigner(); signer != nil {
+		shares := shareAPI{signer: signer, catalog: api.catalog, files: archiveServer, page: "/site/share.html", now: time.Now}
+		mux.Handle("POST /api/share", requireRole(auth.RoleCoach, http.HandlerFunc(shares.create)))
+		mux.Handle("GET /share/{token}/{$}", noCache(http.HandlerFunc(shares.player)))
+		mux.Handle("GET /share/{token}/playlist.m3u8", audited(noCache(http.HandlerFunc(shares.playlist))))
+		mux.Handle("GET /share/{token}/archive/{key...}", audited(http.HandlerFunc(shares.segment)))
+	}
+
+	// Let admins see who can sign in
+	accounts := usersAPI{store: userStore}
+	mux.Handle("GET /api/users", requireRole(auth.RoleAdmin, noCache(http.HandlerFunc(accounts.list))))
+
+	// Let admins manage the API tokens scripts use
+	apiTokens := tokensAPI{tokens: tokens, users: users}
+	mux.Handle("GET /api/tokens", requireRole(auth.RoleAdmin, noCache(http.HandlerFunc(apiTokens.list))))
+	mux.Handle("POST /api/tokens", requireRole(auth.RoleAdmin, http.HandlerFunc(apiTokens.create)))
+	mux.Handle("DELETE /api/tokens/{id}", requireRole(auth.RoleAdmin, http.HandlerFunc(apiTokens.revoke)))
+
+	// Let admins look up who accessed footage
+	audits := auditAPI{dir: auditDir(), location: clubLocation()}
+	mux.Handle("GET /api/audit", requireRole(auth.RoleAdmin, noCache(http.HandlerFunc(audits.query))))
+
+	// Report health to Docker and metrics to Prometheus, which scrapes with
+	// an API token
+	mux.Handle("GET /healthz", health.Live())
+	mux.Handle("GET /readyz", health.Ready(readinessChecks(archiveStore, "/stream")...))
+	mux.Handle("GET /metrics", requireRole(auth.RoleViewer, registry))
 
 	serverAddr := fmt.Sprintf(":%s", port)
 	log.Printf("Starting server on %s\n", serverAddr)
-	if err := http.ListenAndServe(serverAddr, mux); err != nil {
+	if err := http.ListenAndServe(serverAddr, instrument(mux)); err != nil {
 		log.Fatalf("Error: Unable to start server: %+v\n", err)
 	}
 }
 
+// archiveLocation returns the archive's directory or object store location
+// from ARCHIVE_DIR, defaulting to /archive
+func archiveLocation() string {
+	if location, found := os.LookupEnv("ARCHIVE_DIR"); found {
+		return location
+	}
+	return "/archive"
+}
+
+// clubLocation returns the club's time zone from CLUB_TIMEZONE, an IANA
+// name such as Europe/London, defaulting to UTC
+func clubLocation() *time.Location {
+	name, found := os.LookupEnv("CLUB_TIMEZONE")
+	if !found {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Fatalf("Error: invalid CLUB_TIMEZONE: %v\n", err)
+	}
+	return location
+}
+
+// courtName returns the court name used in download filenames
+func courtName() string {
+	if court, found := os.LookupEnv("COURT_NAME"); found {
+		return court
+	}
+	return "court6"
+}
+
 type noCacheMiddleware struct {
 	handler http.Handler
 }
@@ -65,27 +212,3 @@ func (m noCacheMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func noCache(handler http.Handler) http.Handler {
 	return noCacheMiddleware{handler: handler}
 }
-
-func newBasicAuthMiddleware(authUser, authPass string) func(http.Handler) http.Handler {
-	return func(handler http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
-
-			if username, password, ok := r.BasicAuth(); !ok {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			} else {
-				// Use constant-time comparison for both username and password
-				userMatch := subtle.ConstantTimeCompare([]byte(username), []byte(authUser)) == 1
-				passMatch := subtle.ConstantTimeCompare([]byte(password), []byte(authPass)) == 1
-
-				if !userMatch || !passMatch {
-					http.Error(w, "Unauthorized", http.StatusUnauthorized)
-					return
-				}
-			}
-
-			handler.ServeHTTP(w, r)
-		})
-	}
-}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"videoserver/catalog"
+	"videoserver/share"
+)
+
+// runShare prints a link that plays the footage in a time range without
+// signing in, until it expires
+func runShare(args []string) {
+	flags := flag.NewFlagSet("share", flag.ExitOnError)
+	fromFlag := flags.String("from", "", "start of the footage (RFC3339, or local time such as 2006-01-02T15:04)")
+	toFlag := flags.String("to", "", "end of the footage (RFC3339, or local time such as 2006-01-02T15:04)")
+	ttl := flags.Duration("ttl", defaultShareTTL, "how long the link lasts")
+	baseURL := flags.String("base", "", "URL of the videoserver, such as https://court6.example.com")
+	flags.Parse(args)
+
+	signer := shareSigner()
+	if signer == nil {
+		log.Fatalln("Error: SHARE_SECRET environment variable is not set")
+	}
+	if *ttl <= 0 || *ttl > maxShareTTL {
+		log.Fatalf("Error: -ttl must be positive and at most %s\n", maxShareTTL)
+	}
+
+	location := clubLocation()
+	from, err := catalog.ParseTime(*fromFlag, location)
+	if err != nil {
+		log.Fatalf("Error: -from: %v\n", err)
+	}
+	to, err := catalog.ParseTime(*toFlag, location)
+	if err != nil {
+		log.Fatalf("Error: -to: %v\n", err)
+	}
+	if !to.After(from) {
+		log.Fatalln("Error: -to must be after -from")
+	}
+
+	grant := share.Grant{From: from, To: to, Expires: time.Now().Add(*ttl)}
+	fmt.Println(strings.TrimSuffix(*baseURL, "/") + shareLink(signer.Sign(grant)))
+	fmt.Printf("Expires %s\n", grant.Expires.In(location).Format(time.RFC3339))
+}
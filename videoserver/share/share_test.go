@@ -0,0 +1,90 @@
+package share
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestSigner_RoundTrip(t *testing.T) {
+	// Setup
+	signer, err := NewSigner(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grant := Grant{
+		From:    time.Date(2025, 4, 11, 18, 0, 0, 0, time.UTC),
+		To:      time.Date(2025, 4, 11, 19, 30, 0, 0, time.UTC),
+		Expires: time.Date(2025, 4, 18, 0, 0, 0, 0, time.UTC),
+	}
+
+	// Execute
+	token := signer.Sign(grant)
+	verified, err := signer.Verify(token, grant.From)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if verified != grant {
+		t.Errorf("Verify = %+v, want %+v", verified, grant)
+	}
+	if _, err := signer.Verify(token, grant.Expires); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify at expiry = %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestSigner_RejectsTampering(t *testing.T) {
+	// Setup
+	signer, err := NewSigner(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewSigner(strings.Repeat("x", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 4, 11, 0, 0, 0, 0, time.UTC)
+	token := signer.Sign(Grant{From: now, To: now.Add(time.Hour), Expires: now.Add(24 * time.Hour)})
+	payload, signature, _ := strings.Cut(token, ".")
+
+	// Widening the range to the next hour must break the signature
+	widened := strings.Replace(payload, "-", "-9", 1) + "." + signature
+
+	tests := map[string]string{
+		"widened":       widened,
+		"other secret":  other.Sign(Grant{From: now, To: now.Add(time.Hour), Expires: now.Add(24 * time.Hour)}),
+		"no signature":  payload,
+		"garbage":       "not-a-token",
+		"empty payload": "." + signature,
+	}
+
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := signer.Verify(token, now); !errors.Is(err, ErrInvalid) {
+				t.Errorf("Verify = %v, want %v", err, ErrInvalid)
+			}
+		})
+	}
+}
+
+func TestNewSigner_RejectsShortSecrets(t *testing.T) {
+	if _, err := NewSigner("short"); err == nil {
+		t.Error("NewSigner accepted a short secret")
+	}
+}
+
+func TestGrant_Covers(t *testing.T) {
+	from := time.Date(2025, 4, 11, 18, 0, 0, 0, time.UTC)
+	grant := Grant{From: from, To: from.Add(time.Hour)}
+
+	if !grant.Covers(from.Add(-5*time.Second), from.Add(5*time.Second)) {
+		t.Error("Covers rejected a segment overlapping the start")
+	}
+	if grant.Covers(from.Add(time.Hour), from.Add(time.Hour+10*time.Second)) {
+		t.Error("Covers accepted a segment after the end")
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"archive/archiverepo"
+	"archive/playlist"
+	"videoserver/audit"
+	"videoserver/catalog"
+	"videoserver/share"
+)
+
+const (
+	// defaultShareTTL is how long share links last unless asked otherwise
+	defaultShareTTL = 7 * 24 * time.Hour
+	// maxShareTTL limits how long a share link may last
+	maxShareTTL = 90 * 24 * time.Hour
+)
+
+// shareSigner returns the signer for share links from SHARE_SECRET, or nil
+// when sharing is not configured
+func shareSigner() *share.Signer {
+	secret, found := os.LookupEnv("SHARE_SECRET")
+	if !found {
+		return nil
+	}
+	signer, err := share.NewSigner(secret)
+	if err != nil {
+		log.Fatalf("Error: invalid SHARE_SECRET: %v\n", err)
+	}
+	return signer
+}
+
+// shareAPI creates share links and serves the footage they grant access to,
+// without signing in
+type shareAPI struct {
+	signer  *share.Signer
+	catalog *catalog.Catalog
+	files   archiveFiles
+	page    string
+	now     func() time.Time
+}
+
+// create handles POST /api/share, signing a link to the footage between the
+// from and to parameters that lasts for the ttl parameter
+func (a shareAPI) create(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseRange(r, a.catalog)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: %v", err), http.StatusBadRequest)
+		return
+	}
+	ttl := defaultShareTTL
+	if value := r.FormValue("ttl"); value != "" {
+		ttl, err = time.ParseDuration(value)
+		if err != nil || ttl <= 0 || ttl > maxShareTTL {
+			http.Error(w, fmt.Sprintf("Bad Request: ttl must be a duration up to %s", maxShareTTL), http.StatusBadRequest)
+			return
+		}
+	}
+
+	grant := share.Grant{From: from, To: to, Expires: a.now().Add(ttl)}
+	link := shareLink(a.signer.Sign(grant))
+	writeJSON(w, map[string]any{
+		"url":      link,
+		"playlist": link + "playlist.m3u8",
+		"from":     from.UTC(),
+		"to":       to.UTC(),
+		"expires":  grant.Expires.UTC(),
+	})
+}
+
+// shareLink returns the path of the page that plays a share token's footage
+func shareLink(token string) string {
+	return "/share/" + token + "/"
+}
+
+// verify returns the grant of the request's share token, responding with
+// an error if it is invalid or expired
+func (a shareAPI) verify(w http.ResponseWriter, r *http.Request) (share.Grant, bool) {
+	grant, err := a.signer.Verify(r.PathValue("token"), a.now())
+	if errors.Is(err, share.ErrExpired) {
+		http.Error(w, "Gone: this link has expired", http.StatusGone)
+		return share.Grant{}, false
+	}
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return share.Grant{}, false
+	}
+	audit.SetUser(r.Context(), "", "share link")
+	audit.SetRange(r.Context(), grant.From, grant.To)
+	return grant, true
+}
+
+// player handles GET /share/{token}/ with a page playing the footage
+func (a shareAPI) player(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.verify(w, r); !ok {
+		return
+	}
+	http.ServeFile(w, r, a.page)
+}
+
+// playlist handles GET /share/{token}/playlist.m3u8 with a playlist of the
+// segments the grant covers. Segment URIs are relative, so they stay under
+// the same token.
+func (a shareAPI) playlist(w http.ResponseWriter, r *http.Request) {
+	grant, ok := a.verify(w, r)
+	if !ok {
+		return
+	}
+
+	segments, err := a.catalog.Segments(r.Context(), grant.From, grant.To)
+	if err != nil {
+		log.Printf("Failed to list shared segments: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypes[".m3u8"])
+	w.Write([]byte(segmentPlaylist(segments, "archive/", !grant.To.After(a.now()))))
+}
+
+// segmentPlaylist returns a playlist of archived segments, whose URIs are
+// their keys after prefix. Playlists of ranges that are still recording are
+// left open, so players keep polling until the end.
+func segmentPlaylist(segments []catalog.Segment, prefix string, complete bool) string {
+	joined := &playlist.Playlist{Version: 3, TargetDuration: 1}
+	for i, segment := range segments {
+		joined.TargetDuration = max(joined.TargetDuration, int(math.Ceil(segment.Duration)))
+		joined.Segments = append(joined.Segments, playlist.Segment{
+			Filename:        prefix + segment.Key,
+			Duration:        segment.Duration,
+			DateTime:        segment.DateTime,
+			ProgramDateTime: segment.DateTime.UTC().Format(playlist.DateTimeLayout),
+			// Players stall on a restarted recording unless they are told
+			Discontinuity: i > 0 && segment.Discontinuity,
+		})
+	}
+	content := joined.String()
+	if complete {
+		content += "#EXT-X-ENDLIST\n"
+	}
+	return content
+}
+
+// segment handles GET /share/{token}/archive/{key...}, serving an archived
+// segment only if the grant covers it
+func (a shareAPI) segment(w http.ResponseWriter, r *http.Request) {
+	grant, ok := a.verify(w, r)
+	if !ok {
+		return
+	}
+
+	key := r.PathValue("key")
+	hour, err := hourOfKey(key)
+	if err != nil || path.Ext(key) != ".ts" {
+		http.NotFound(w, r)
+		return
+	}
+	// Only the hour the key is in needs to be listed
+	from, to := grant.From, grant.To
+	if hour.After(from) {
+		from = hour
+	}
+	if hour.Add(time.Hour).Before(to) {
+		to = hour.Add(time.Hour)
+	}
+	if !to.After(from) {
+		http.NotFound(w, r)
+		return
+	}
+	segments, err := a.catalog.Segments(r.Context(), from, to)
+	if err != nil {
+		log.Printf("Failed to list shared segments: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	for _, segment := range segments {
+		if segment.Key == key && grant.Covers(segment.DateTime, segment.End()) {
+			files := r.Clone(r.Context())
+			files.URL.Path = "/" + key
+			a.files.ServeHTTP(w, files)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// hourOfKey returns the UTC hour an archive object key is stored under
+func hourOfKey(key string) (time.Time, error) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 5 {
+		return time.Time{}, fmt.Errorf("not a segment key: %s", key)
+	}
+	hour, err := time.Parse("2006/01/02/15", strings.Join(parts[:4], "/"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if archiverepo.HourPath(hour) != strings.Join(parts[:4], "/") {
+		return time.Time{}, fmt.Errorf("not a segment key: %s", key)
+	}
+	return hour, nil
+}
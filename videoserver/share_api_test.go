@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"archive/objectstore"
+	"videoserver/catalog"
+	"videoserver/share"
+)
+
+func TestShareAPI_GrantsOnlyTheSharedRange(t *testing.T) {
+	// Setup
+	basePath := t.TempDir()
+	dir := filepath.Join(basePath, "2025", "04", "11", "18")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"playlist.m3u8": `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:00.000+0000
+segment_000.ts
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:10.000+0000
+segment_001.ts
+#EXTINF:10.000000,
+#EXT-X-PROGRAM-DATE-TIME:2025-04-11T18:00:20.000+0000
+segment_002.ts
+`,
+		"segment_000.ts": "0",
+		"segment_001.ts": "1",
+		"segment_002.ts": "2",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := objectstore.NewFilesystem(basePath)
+	signer, err := share.NewSigner("0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 4, 12, 9, 0, 0, 0, time.UTC)
+	shares := shareAPI{
+		signer:  signer,
+		catalog: catalog.New(store, time.UTC),
+		files:   archiveFiles{store: store},
+		now:     func() time.Time { return now },
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/share", shares.create)
+	mux.HandleFunc("GET /share/{token}/playlist.m3u8", shares.playlist)
+	mux.HandleFunc("GET /share/{token}/archive/{key...}", shares.segment)
+
+	// Execute
+	form := url.Values{"from": {"2025-04-11T18:00:12Z"}, "to": {"2025-04-11T18:00:18Z"}, "ttl": {"24h"}}
+	request := httptest.NewRequest("POST", "/api/share", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("POST /api/share = %d %s", recorder.Code, recorder.Body.String())
+	}
+	token := signer.Sign(share.Grant{
+		From:    time.Date(2025, 4, 11, 18, 0, 12, 0, time.UTC),
+		To:      time.Date(2025, 4, 11, 18, 0, 18, 0, time.UTC),
+		Expires: now.Add(24 * time.Hour),
+	})
+	if !strings.Contains(recorder.Body.String(), "/share/"+token+"/") {
+		t.Fatalf("Body = %s, want a link with %s", recorder.Body.String(), token)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		return recorder
+	}
+
+	// Assert
+	playlist := get("/share/" + token + "/playlist.m3u8")
+	if playlist.Code != http.StatusOK {
+		t.Fatalf("playlist = %d", playlist.Code)
+	}
+	if body := playlist.Body.String(); !strings.Contains(body, "archive/2025/04/11/18/segment_001.ts") ||
+		strings.Contains(body, "segment_000.ts") || strings.Contains(body, "segment_002.ts") ||
+		!strings.Contains(body, "#EXT-X-ENDLIST") {
+		t.Errorf("playlist =\n%s\nwant only segment_001.ts", body)
+	}
+	if segment := get("/share/" + token + "/archive/2025/04/11/18/segment_001.ts"); segment.Body.String() != "1" {
+		t.Errorf("shared segment = %d %q", segment.Code, segment.Body.String())
+	}
+	if segment := get("/share/" + token + "/archive/2025/04/11/18/segment_002.ts"); segment.Code != http.StatusNotFound {
+		t.Errorf("segment outside the range = %d, want %d", segment.Code, http.StatusNotFound)
+	}
+	if segment := get("/share/" + token + "/archive/2025/04/11/18/playlist.m3u8"); segment.Code != http.StatusNotFound {
+		t.Errorf("archive playlist = %d, want %d", segment.Code, http.StatusNotFound)
+	}
+
+	// Execute after expiry
+	now = now.Add(25 * time.Hour)
+
+	// Assert
+	if expired := get("/share/" + token + "/playlist.m3u8"); expired.Code != http.StatusGone {
+		t.Errorf("expired playlist = %d, want %d", expired.Code, http.StatusGone)
+	}
+}
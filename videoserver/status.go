@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"archive/health"
+	"archive/metrics"
+	"archive/objectstore"
+)
+
+// newMetricsMiddleware returns a middleware that counts requests and times
+// them by route. It wraps the whole mux, which records the matched route
+// on the request.
+func newMetricsMiddleware(registry *metrics.Registry) func(http.Handler) http.Handler {
+	requests := registry.Counter("videoserver_requests_total", "Requests served by route, method and status code.", "route", "method", "code")
+	durations := registry.Histogram("videoserver_request_duration_seconds", "How long requests take by route.", metrics.DefaultBuckets, "route")
+	bytes := registry.Counter("videoserver_response_bytes_total", "Bytes served by route.", "route")
+
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started := time.Now()
+			counter := &countingWriter{ResponseWriter: w}
+			handler.ServeHTTP(counter, r)
+
+			route := r.Pattern
+			if route == "" {
+				route = "unmatched"
+			}
+			status := counter.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			requests.With(route, r.Method, strconv.Itoa(status)).Inc()
+			durations.With(route).Observe(time.Since(started).Seconds())
+			bytes.With(route).Add(float64(counter.bytes))
+		})
+	}
+}
+
+// readinessChecks returns the checks /readyz runs: that the archive store
+// can be listed and that the recorder has written a live playlist
+func readinessChecks(archiveStore objectstore.Store, streamDir string) []health.Check {
+	return []health.Check{
+		{Name: "archive", Check: func(ctx context.Context) error {
+			_, err := archiveStore.List(ctx, "")
+			return err
+		}},
+		{Name: "stream", Check: func(ctx context.Context) error {
+			_, err := os.Stat(filepath.Join(streamDir, "playlist.m3u8"))
+			return err
+		}},
+	}
+}
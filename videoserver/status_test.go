@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"archive/metrics"
+)
+
+func TestMetricsMiddleware_CountsByRoute(t *testing.T) {
+	// Setup
+	registry := metrics.NewRegistry()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /archive/{key...}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("segment"))
+	})
+	handler := newMetricsMiddleware(registry)(mux)
+
+	// Execute
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/archive/2025/04/11/18/segment_000.ts", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	// Assert
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		`videoserver_requests_total{route="GET /archive/{key...}",method="GET",code="200"} 1`,
+		`videoserver_requests_total{route="unmatched",method="GET",code="404"} 1`,
+		`videoserver_response_bytes_total{route="GET /archive/{key...}"} 7`,
+		`videoserver_request_duration_seconds_count{route="GET /archive/{key...}"} 1`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("metrics are missing %s:\n%s", expected, body)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"videoserver/auth"
+)
+
+// tokensFile returns the API token file from TOKENS_FILE, defaulting to
+// /data/tokens.json
+func tokensFile() string {
+	if path, found := os.LookupEnv("TOKENS_FILE"); found {
+		return path
+	}
+	return "/data/tokens.json"
+}
+
+// openTokens opens the API token file
+func openTokens() *auth.TokenStore {
+	tokens, err := auth.OpenTokenStore(tokensFile())
+	if err != nil {
+		log.Fatalf("Error: Unable to open TOKENS_FILE: %v\n", err)
+	}
+	return tokens
+}
+
+// runTokens manages the API tokens scripts use instead of a password
+func runTokens(args []string) {
+	flags := flag.NewFlagSet("tokens", flag.ExitOnError)
+	file := flags.String("file", tokensFile(), "API token file")
+	user := flags.String("user", "", "user new tokens act for")
+	usersPath := flags.String("users", usersFile(), "user file new tokens' users must be in")
+	scope := flags.String("scope", string(auth.ScopeRead), "scope for new tokens: read, export or admin")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: videoserver tokens [flags] list|create NAME|revoke ID")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	tokens, err := auth.OpenTokenStore(*file)
+	if err != nil {
+		log.Fatalf("Error: Unable to open tokens: %v\n", err)
+	}
+
+	command, names := flags.Arg(0), flags.Args()[min(1, flags.NArg()):]
+	switch {
+	case command == "list" && len(names) == 0:
+		for _, token := range tokens.List() {
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\n", token.ID, token.User, token.Scope, token.Name, tokenStatus(token))
+		}
+	case command == "create" && len(names) == 1:
+		if *user == "" {
+			log.Fatalln("Error: -user is required")
+		}
+		newScope, err := auth.ParseScope(*scope)
+		if err != nil {
+			log.Fatalf("Error: -scope: %v\n", err)
+		}
+		users, err := auth.OpenStore(*usersPath)
+		if err != nil {
+			log.Fatalf("Error: Unable to open users: %v\n", err)
+		}
+		if _, found := users.Get(*user); !found {
+			log.Fatalf("Error: -user: %v: %s\n", auth.ErrUnknownUser, *user)
+		}
+		secret, token, err := tokens.Create(*user, names[0], newScope)
+		if err != nil {
+			log.Fatalf("Error: Unable to create token: %v\n", err)
+		}
+		fmt.Fprintf(os.Stderr, "Created token %s for %s with %s scope; it can't be shown again\n", token.ID, token.User, token.Scope)
+		fmt.Println(secret)
+	case command == "revoke" && len(names) == 1:
+		if err := tokens.Revoke(names[0]); err != nil {
+			log.Fatalf("Error: Unable to revoke %s: %v\n", names[0], err)
+		}
+		fmt.Printf("Revoked %s\n", names[0])
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// tokenStatus describes when a token was created, last used and revoked
+func tokenStatus(token auth.Token) string {
+	status := "created " + token.Created.Format(time.RFC3339)
+	if token.LastUsed != nil {
+		status += ", last used " + token.LastUsed.Format(time.RFC3339)
+	}
+	if token.Revoked != nil {
+		status += ", revoked " + token.Revoked.Format(time.RFC3339)
+	}
+	return status
+}
+
+// tokensAPI lets admins manage API tokens
+type tokensAPI struct {
+	tokens *auth.TokenStore
+	users  authenticator
+}
+
+// tokenSummary is a token without its hash
+type tokenSummary struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	User     string     `json:"user"`
+	Scope    auth.Scope `json:"scope"`
+	Created  time.Time  `json:"created"`
+	LastUsed *time.Time `json:"last_used,omitempty"`
+	Revoked  *time.Time `json:"revoked,omitempty"`
+}
+
+// summarize returns a token without its hash
+func summarize(token auth.Token) tokenSummary {
+	return tokenSummary{
+		ID:       token.ID,
+		Name:     token.Name,
+		User:     token.User,
+		Scope:    token.Scope,
+		Created:  token.Created,
+		LastUsed: token.LastUsed,
+		Revoked:  token.Revoked,
+	}
+}
+
+// list handles GET /api/tokens
+func (a tokensAPI) list(w http.ResponseWriter, r *http.Request) {
+	summaries := []tokenSummary{}
+	for _, token := range a.tokens.List() {
+		summaries = append(summaries, summarize(token))
+	}
+	writeJSON(w, summaries)
+}
+
+// create handles POST /api/tokens, making a token with the name and scope
+// parameters for the user parameter, or for the signed in admin
+func (a tokensAPI) create(w http.ResponseWriter, r *http.Request) {
+	scope, err := auth.ParseScope(r.FormValue("scope"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Bad Request: scope: %v", err), http.StatusBadRequest)
+		return
+	}
+	user := r.FormValue("user")
+	if user == "" {
+		signedIn, _ := auth.UserFromContext(r.Context())
+		user = signedIn.Name
+	}
+	if _, found := a.users.Get(user); !found {
+		http.Error(w, fmt.Sprintf("Bad Request: unknown user %q", user), http.StatusBadRequest)
+		return
+	}
+
+	secret, token, err := a.tokens.Create(user, r.FormValue("name"), scope)
+	if err != nil {
+		log.Printf("Failed to create token: %v\n", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]any{"token": secret, "details": summarize(token)})
+}
+
+// revoke handles DELETE /api/tokens/{id}
+func (a tokensAPI) revoke(w http.ResponseWriter, r *http.Request) {
+	err := a.tokens.Revoke(r.PathValue("id"))
+	if errors.Is(err, auth.ErrUnknownToken) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to revoke token %s: %v\n", r.PathValue("id"), err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
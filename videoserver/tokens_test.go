@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"videoserver/auth"
+)
+
+func TestAuthMiddleware_BearerTokens(t *testing.T) {
+	// Setup
+	users, err := auth.OpenStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Add("coach", "coach password", auth.RoleCoach); err != nil {
+		t.Fatal(err)
+	}
+	tokens := openTestTokens(t)
+	readSecret, _, err := tokens.Create("coach", "archive sync", auth.ScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminSecret, _, err := tokens.Create("coach", "too much", auth.ScopeAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedSecret, revoked, err := tokens.Create("coach", "old laptop", auth.ScopeExport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tokens.Revoke(revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	requireRole := newAuthMiddleware(users, auth.NewSessions(time.Hour), tokens, newSignInGuard(nil))
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name     string
+		secret   string
+		required auth.Role
+		expected int
+	}{
+		{"read token reads", readSecret, auth.RoleViewer, http.StatusOK},
+		{"read token can't export", readSecret, auth.RoleCoach, http.StatusForbidden},
+		{"admin token capped at the user's role", adminSecret, auth.RoleAdmin, http.StatusForbidden},
+		{"admin token acts as the user", adminSecret, auth.RoleCoach, http.StatusOK},
+		{"revoked token", revokedSecret, auth.RoleViewer, http.StatusUnauthorized},
+		{"unknown token", "c6_00000000_nothing", auth.RoleViewer, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Execute
+			request := httptest.NewRequest("GET", "/api/archive", nil)
+			request.Header.Set("Authorization", "Bearer "+tt.secret)
+			recorder := httptest.NewRecorder()
+			requireRole(tt.required, ok).ServeHTTP(recorder, request)
+
+			// Assert
+			if recorder.Code != tt.expected {
+				t.Errorf("Status = %d, want %d", recorder.Code, tt.expected)
+			}
+		})
+	}
+}
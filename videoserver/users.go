@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"videoserver/auth"
+)
+
+// usersFile returns the user file from USERS_FILE, defaulting to
+// /data/users.json
+func usersFile() string {
+	if path, found := os.LookupEnv("USERS_FILE"); found {
+		return path
+	}
+	return "/data/users.json"
+}
+
+// runUsers manages the users who can sign in to the videoserver
+func runUsers(args []string) {
+	flags := flag.NewFlagSet("users", flag.ExitOnError)
+	file := flags.String("file", usersFile(), "user file")
+	role := flags.String("role", string(auth.RoleViewer), "role for new users: viewer, coach or admin")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: videoserver users [flags] list|add NAME|remove NAME|reset NAME|role NAME ROLE|email NAME [EMAIL]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	store, err := auth.OpenStore(*file)
+	if err != nil {
+		log.Fatalf("Error: Unable to open users: %v\n", err)
+	}
+
+	command, names := flags.Arg(0), flags.Args()[min(1, flags.NArg()):]
+	switch {
+	case command == "list" && len(names) == 0:
+		for _, user := range store.List() {
+			fmt.Printf("%s\t%s\t%s\t%s\n", user.Name, user.Role, user.Email, user.Created.Format(time.RFC3339))
+		}
+	case command == "add" && len(names) == 1:
+		newRole, err := auth.ParseRole(*role)
+		if err != nil {
+			log.Fatalf("Error: -role: %v\n", err)
+		}
+		err = store.Add(names[0], readPassword(), newRole)
+		if err != nil {
+			log.Fatalf("Error: Unable to add %s: %v\n", names[0], err)
+		}
+		fmt.Printf("Added %s as %s\n", names[0], newRole)
+	case command == "remove" && len(names) == 1:
+		if err := store.Remove(names[0]); err != nil {
+			log.Fatalf("Error: Unable to remove %s: %v\n", names[0], err)
+		}
+		fmt.Printf("Removed %s\n", names[0])
+	case command == "reset" && len(names) == 1:
+		if err := store.SetPassword(names[0], readPassword()); err != nil {
+			log.Fatalf("Error: Unable to reset %s: %v\n", names[0], err)
+		}
+		fmt.Printf("Reset the password of %s\n", names[0])
+	case command == "role" && len(names) == 2:
+		newRole, err := auth.ParseRole(names[1])
+		if err != nil {
+			log.Fatalf("Error: %v\n", err)
+		}
+		if err := store.SetRole(names[0], newRole); err != nil {
+			log.Fatalf("Error: Unable to change the role of %s: %v\n", names[0], err)
+		}
+		fmt.Printf("Changed the role of %s to %s\n", names[0], newRole)
+	case command == "email" && (len(names) == 1 || len(names) == 2):
+		email := ""
+		if len(names) == 2 {
+			email = names[1]
+		}
+		if err := store.SetEmail(names[0], email); err != nil {
+			log.Fatalf("Error: Unable to change the email address of %s: %v\n", names[0], err)
+		}
+		if email == "" {
+			fmt.Printf("Cleared the email address of %s\n", names[0])
+		} else {
+			fmt.Printf("Changed the email address of %s to %s\n", names[0], email)
+		}
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// readPassword reads a password from the first line of standard input, so
+// that it can be typed at a prompt or piped in by a script
+func readPassword() string {
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		log.Fatalf("Error: Unable to read password: %v\n", err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+// authenticator checks a user name and password, and looks up the users of
+// signed in sessions
+type authenticator interface {
+	Authenticate(name, password string) (auth.User, bool)
+	Get(name string) (auth.User, bool)
+}
+
+// legacyUser is the single admin configured with AUTH_USER and AUTH_PASSWORD,
+// used while there is no user file until the first user is added to it
+type legacyUser struct {
+	store    *auth.Store
+	name     string
+	password string
+}
+
+func (l legacyUser) Authenticate(name, password string) (auth.User, bool) {
+	if len(l.store.List()) > 0 {
+		return l.store.Authenticate(name, password)
+	}
+
+	// Use constant-time comparison for both username and password
+	userMatch := subtle.ConstantTimeCompare([]byte(name), []byte(l.name)) == 1
+	passMatch := subtle.ConstantTimeCompare([]byte(password), []byte(l.password)) == 1
+	if !userMatch || !passMatch {
+		return auth.User{}, false
+	}
+	return auth.User{Name: l.name, Role: auth.RoleAdmin}, true
+}
+
+func (l legacyUser) Get(name string) (auth.User, bool) {
+	if len(l.store.List()) > 0 {
+		return l.store.Get(name)
+	}
+	if name != l.name {
+		return auth.User{}, false
+	}
+	return auth.User{Name: l.name, Role: auth.RoleAdmin}, true
+}
+
+// signInCacheTTL is how long a checked name and password are remembered
+const signInCacheTTL = time.Minute
+
+// signInCache remembers successful sign ins for a short while, so players
+// that send basic auth with every segment request don't cost a bcrypt
+// comparison each. Only a keyed hash of the password is kept, and users are
+// looked up again on every hit, so removed users, role changes and changed
+// passwords still take effect straight away.
+type signInCache struct {
+	users   authenticator
+	ttl     time.Duration
+	now     func() time.Time
+	key     []byte
+	mu      sync.Mutex
+	entries map[string]cachedSignIn
+}
+
+// cachedSignIn is a remembered sign in
+type cachedSignIn struct {
+	digest []byte
+	// passwordHash is the user's password hash at the time, so the sign in
+	// is forgotten once the password changes
+	passwordHash string
+	expires      time.Time
+}
+
+// newSignInCache returns a cache in front of users that remembers sign ins
+// for ttl
+func newSignInCache(users authenticator, ttl time.Duration) *signInCache {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("Error: Unable to generate a sign in cache key: %v\n", err)
+	}
+	return &signInCache{users: users, ttl: ttl, now: time.Now, key: key, entries: make(map[string]cachedSignIn)}
+}
+
+func (c *signInCache) Authenticate(name, password string) (auth.User, bool) {
+	digest := c.digest(name, password)
+	c.mu.Lock()
+	entry, found := c.entries[name]
+	c.mu.Unlock()
+	if found && c.now().Before(entry.expires) && hmac.Equal(entry.digest, digest) {
+		user, ok := c.users.Get(name)
+		if !ok || user.PasswordHash == entry.passwordHash {
+			return user, ok
+		}
+	}
+
+	user, ok := c.users.Authenticate(name, password)
+	if !ok {
+		return user, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for cached, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, cached)
+		}
+	}
+	c.entries[name] = cachedSignIn{digest: digest, passwordHash: user.PasswordHash, expires: now.Add(c.ttl)}
+	return user, true
+}
+
+func (c *signInCache) Get(name string) (auth.User, bool) {
+	return c.users.Get(name)
+}
+
+// digest returns the keyed hash of a name and password
+func (c *signInCache) digest(name, password string) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+// loadAuthenticator opens the user file, falling back to AUTH_USER and
+// AUTH_PASSWORD only while there is no user file at all
+func loadAuthenticator() (authenticator, *auth.Store) {
+	path := usersFile()
+	_, statErr := os.Stat(path)
+	store, err := auth.OpenStore(path)
+	if err != nil {
+		log.Fatalf("Error: Unable to open USERS_FILE: %v\n", err)
+	}
+
+	authUser, userFound := os.LookupEnv("AUTH_USER")
+	authPass, passFound := os.LookupEnv("AUTH_PASSWORD")
+	legacyFound := userFound && passFound
+	if !errors.Is(statErr, fs.ErrNotExist) {
+		if legacyFound {
+			log.Printf("Warning: ignoring AUTH_USER and AUTH_PASSWORD because %s exists\n", path)
+		}
+		if len(store.List()) == 0 {
+			log.Fatalf("Error: %s has no users; add one with `videoserver users add`\n", path)
+		}
+		return store, store
+	}
+	if !legacyFound {
+		log.Fatalf("Error: %s does not exist; add a user with `videoserver users add`\n", path)
+	}
+	log.Printf("Warning: %s does not exist, signing in with AUTH_USER as an admin until a user is added\n", path)
+	return legacyUser{store: store, name: authUser, password: authPass}, store
+}
+
+// usersAPI lets admins see who can sign in
+type usersAPI struct {
+	store *auth.Store
+}
+
+// userSummary is a user without their password hash
+type userSummary struct {
+	Name    string    `json:"name"`
+	Role    auth.Role `json:"role"`
+	Email   string    `json:"email,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// list handles GET /api/users
+func (a usersAPI) list(w http.ResponseWriter, r *http.Request) {
+	summaries := []userSummary{}
+	for _, user := range a.store.List() {
+		summaries = append(summaries, userSummary{Name: user.Name, Role: user.Role, Email: user.Email, Created: user.Created})
+	}
+	writeJSON(w, summaries)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"videoserver/auth"
+)
+
+func TestLegacyUser_GivesWayToTheUserFile(t *testing.T) {
+	// Setup
+	store, err := auth.OpenStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := legacyUser{store: store, name: "admin", password: "secret"}
+
+	// Assert
+	if user, ok := legacy.Authenticate("admin", "secret"); !ok || user.Role != auth.RoleAdmin {
+		t.Errorf("Authenticate = %+v, %v, want the legacy admin", user, ok)
+	}
+
+	// Execute
+	if err := store.Add("owner", "owner password", auth.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	// Assert
+	if _, ok := legacy.Authenticate("admin", "secret"); ok {
+		t.Error("Authenticate accepted the legacy admin after users were added")
+	}
+	if _, ok := legacy.Authenticate("owner", "owner password"); !ok {
+		t.Error("Authenticate rejected a user from the user file")
+	}
+}
+
+func TestLoadAuthenticator_OnlyFallsBackWithoutAUserFile(t *testing.T) {
+	// Setup
+	path := filepath.Join(t.TempDir(), "users.json")
+	t.Setenv("USERS_FILE", path)
+	t.Setenv("AUTH_USER", "admin")
+	t.Setenv("AUTH_PASSWORD", "secret")
+
+	// Execute
+	users, _ := loadAuthenticator()
+
+	// Assert
+	if _, ok := users.Authenticate("admin", "secret"); !ok {
+		t.Error("Authenticate rejected AUTH_USER without a user file")
+	}
+
+	// Execute
+	store, err := auth.OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add("owner", "owner password", auth.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	users, _ = loadAuthenticator()
+
+	// Assert
+	if _, isLegacy := users.(legacyUser); isLegacy {
+		t.Error("loadAuthenticator fell back to AUTH_USER although the user file exists")
+	}
+	if _, ok := users.Authenticate("admin", "secret"); ok {
+		t.Error("Authenticate accepted AUTH_USER although the user file exists")
+	}
+}
+
+// countingUsers counts the password checks made against a store
+type countingUsers struct {
+	*auth.Store
+	checks int
+}
+
+func (c *countingUsers) Authenticate(name, password string) (auth.User, bool) {
+	c.checks++
+	return c.Store.Authenticate(name, password)
+}
+
+func TestSignInCache(t *testing.T) {
+	// Setup
+	store, err := auth.OpenStore(filepath.Join(t.TempDir(), "users.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add("player", "player password", auth.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	users := &countingUsers{Store: store}
+	cache := newSignInCache(users, time.Minute)
+	now := time.Date(2025, 4, 11, 19, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	// Execute
+	for i := 0; i < 3; i++ {
+		if _, ok := cache.Authenticate("player", "player password"); !ok {
+			t.Fatalf("Authenticate %d rejected the right password", i+1)
+		}
+	}
+
+	// Assert
+	if users.checks != 1 {
+		t.Errorf("checked the password %d times, want once", users.checks)
+	}
+	if _, ok := cache.Authenticate("player", "wrong password"); ok {
+		t.Error("Authenticate accepted a wrong password")
+	}
+	now = now.Add(time.Minute)
+	if _, ok := cache.Authenticate("player", "player password"); !ok || users.checks != 3 {
+		t.Errorf("Authenticate after the cache expired = %v with %d checks, want the password checked again", ok, users.checks)
+	}
+	if err := store.SetPassword("player", "new password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Authenticate("player", "player password"); ok {
+		t.Error("Authenticate accepted the old password after it was reset")
+	}
+	if _, ok := cache.Authenticate("player", "new password"); !ok {
+		t.Error("Authenticate rejected the new password")
+	}
+	if err := store.Remove("player"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Authenticate("player", "new password"); ok {
+		t.Error("Authenticate accepted a removed user")
+	}
+}